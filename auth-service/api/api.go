@@ -0,0 +1,8 @@
+// Package api: kontrak OpenAPI auth-service, disajikan di /openapi.json dan
+// digabung gateway ke spec publik.
+package api
+
+import _ "embed"
+
+//go:embed openapi.json
+var Spec []byte
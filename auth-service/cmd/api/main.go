@@ -1,41 +1,108 @@
 package main
 
 import (
+	"auth-service/api"
 	"auth-service/internal/database"
 	"auth-service/internal/handler"
 	"auth-service/internal/middleware"
-	"log"
+	"auth-service/internal/utils"
+	"context"
+	"log/slog"
+	"net/http"
+	"os"
+	"shared/logging"
+	"shared/metrics"
+	"shared/server"
+	"shared/svcauth"
+	"shared/tracing"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
 )
 
 func main() {
-	// 1. Load Env
-	if err := godotenv.Load(); err != nil {
-		log.Println("⚠️  Warning: .env file not found")
+	// 1. Load Env (sebelum logging.Setup supaya LOG_LEVEL dari .env terbaca)
+	envErr := godotenv.Load()
+	logging.Setup("auth-service")
+	if envErr != nil {
+		slog.Warn(".env file not found")
 	}
 
+	shutdownTracing, err := tracing.Setup(context.Background(), "auth-service")
+	if err != nil {
+		logging.Fatal("gagal setup tracing", "error", err)
+	}
+	defer shutdownTracing(context.Background())
+
 	// 2. Connect DB
 	database.InitDB()
 	database.InitRedis()
+	utils.InitKeys()
+	utils.InitSecretKey()
 
 	// 3. Setup Router
-	r := gin.Default()
+	r := gin.New()
+	// ClientIP dipakai throttling login/resend per IP, jadi X-Forwarded-For
+	// hanya dipercaya dari gateway (TRUSTED_PROXIES, default localhost)
+	trusted := []string{"127.0.0.1", "::1"}
+	if v := os.Getenv("TRUSTED_PROXIES"); v != "" {
+		trusted = strings.Split(v, ",")
+	}
+	if err := r.SetTrustedProxies(trusted); err != nil {
+		logging.Fatal("TRUSTED_PROXIES tidak valid", "error", err)
+	}
+	r.Use(gin.Recovery(), tracing.Middleware("auth-service"), logging.Middleware(), metrics.Middleware())
 	r.Use(middleware.CORS())
 
+	r.GET("/metrics", metrics.Handler())
+	r.GET("/openapi.json", func(c *gin.Context) { c.Data(http.StatusOK, "application/json", api.Spec) })
+	server.Probes(r, map[string]server.Check{
+		"postgres": database.DB.PingContext,
+		"redis":    func(ctx context.Context) error { return database.RDB.Ping(ctx).Err() },
+	})
+
 	// 4. Routes
 	auth := r.Group("/auth")
 	{
 		auth.POST("/register", handler.Register)
 		auth.POST("/verify", handler.Verify)
+		auth.POST("/resend-verification", handler.ResendVerification)
 		auth.POST("/login", handler.Login)
+		auth.POST("/login/2fa", handler.Login2FA)
 		auth.POST("/refresh", handler.Refresh)
 		auth.POST("/logout", handler.Logout)
-		auth.POST("/internal/send-receipt", handler.SendReceipt)
+		auth.POST("/forgot-password", handler.ForgotPassword)
+		auth.POST("/reset-password", handler.ResetPassword)
+		auth.GET("/.well-known/jwks.json", handler.JWKS)
+		auth.GET("/me", middleware.RequireUser(), handler.Me)
+
+		// 2FA TOTP milik user yang sedang login
+		twoFactor := auth.Group("/2fa", middleware.RequireUser())
+		twoFactor.POST("/enroll", handler.EnrollTOTP)
+		twoFactor.POST("/confirm", handler.ConfirmTOTP)
+		twoFactor.POST("/disable", handler.DisableTOTP)
+
+		// API key untuk client mesin, dikelola user yang sedang login
+		keys := auth.Group("/api-keys", middleware.RequireUser())
+		keys.POST("", handler.CreateAPIKey)
+		keys.GET("", handler.ListAPIKeys)
+		keys.DELETE("/:id", handler.RevokeAPIKey)
+
+		// Internal: hanya untuk service lain, wajib request bertanda tangan HMAC
+		internal := auth.Group("/internal", svcauth.Middleware(svcauth.KeyFromEnv()))
+		internal.POST("/send-receipt", handler.SendReceipt)
+		internal.POST("/api-keys/resolve", handler.ResolveAPIKey)
+
+	}
 
+	srv := &http.Server{Addr: ":8080", Handler: r}
+	slog.Info("Auth Service running", "addr", srv.Addr)
+	if err := server.Run(srv); err != nil {
+		logging.Fatal("Auth Service berhenti", "error", err)
 	}
 
-	log.Println("🚀 Auth Service running on http://localhost:8080")
-	r.Run(":8080")
+	// Koneksi ditutup setelah request & email yang tertunda selesai
+	database.DB.Close()
+	database.RDB.Close()
 }
\ No newline at end of file
@@ -0,0 +1,61 @@
+// keygen membuat signing key baru untuk access token.
+//
+//	go run ./cmd/keygen -alg EdDSA -dir keys
+//
+// File ditulis ke <dir>/<kid>.pem. Lihat internal/utils/keys.go untuk
+// langkah rotasi kunci.
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+func main() {
+	alg := flag.String("alg", "EdDSA", "EdDSA atau RS256")
+	dir := flag.String("dir", "keys", "folder JWT_KEYS_DIR")
+	kid := flag.String("kid", time.Now().Format("20060102-150405"), "key id (nama file)")
+	flag.Parse()
+
+	var key any
+	var err error
+	switch *alg {
+	case "EdDSA":
+		_, key, err = ed25519.GenerateKey(rand.Reader)
+	case "RS256":
+		key, err = rsa.GenerateKey(rand.Reader, 3072)
+	default:
+		fail("alg %q tidak didukung", *alg)
+	}
+	if err != nil {
+		fail("gagal membuat kunci: %v", err)
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		fail("gagal encode kunci: %v", err)
+	}
+
+	if err := os.MkdirAll(*dir, 0o700); err != nil {
+		fail("%v", err)
+	}
+	path := filepath.Join(*dir, *kid+".pem")
+	pemBytes := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	if err := os.WriteFile(path, pemBytes, 0o600); err != nil {
+		fail("%v", err)
+	}
+	fmt.Printf("kunci %s (%s) ditulis ke %s\n", *kid, *alg, path)
+}
+
+func fail(format string, args ...any) {
+	fmt.Fprintf(os.Stderr, "keygen: "+format+"\n", args...)
+	os.Exit(1)
+}
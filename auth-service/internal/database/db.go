@@ -4,11 +4,15 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
+	"shared/logging"
 
+	"github.com/XSAM/otelsql"
 	_ "github.com/lib/pq"
+	"github.com/redis/go-redis/extra/redisotel/v9"
 	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 var DB *sql.DB
@@ -20,25 +24,29 @@ func InitDB() {
 		os.Getenv("DB_NAME"), os.Getenv("DB_PORT"))
 
 	var err error
-	DB, err = sql.Open("postgres", dsn)
+	// otelsql: setiap query jadi span (child dari span request lewat ctx)
+	DB, err = otelsql.Open("postgres", dsn, otelsql.WithAttributes(attribute.String("db.system.name", "postgresql")))
 	if err != nil {
-		log.Fatal("❌ Failed to open DB:", err)
+		logging.Fatal("failed to open DB", "error", err)
 	}
 
 	if err = DB.Ping(); err != nil {
-		log.Fatal("❌ DB not reachable:", err)
+		logging.Fatal("DB not reachable", "error", err)
 	} // <--- health connection
 	
 	// Redis Ping <-> Pong
 
-	log.Println("✅ Connected to PostgreSQL")
+	slog.Info("connected to PostgreSQL")
 }
 func InitRedis() {
 	RDB = redis.NewClient(&redis.Options{
 		Addr: os.Getenv("REDIS_ADDR"),
 	})
+	if err := redisotel.InstrumentTracing(RDB); err != nil {
+		logging.Fatal("failed to instrument Redis", "error", err)
+	}
 	if _, err := RDB.Ping(context.Background()).Result(); err != nil {
-		log.Fatal("❌ Failed to connect to Redis:", err)
+		logging.Fatal("failed to connect to Redis", "error", err)
 	}	
-	log.Println("✅ Connected to Redis")
+	slog.Info("connected to Redis")
 }
\ No newline at end of file
@@ -0,0 +1,105 @@
+package handler
+
+import (
+	"auth-service/internal/service"
+	"auth-service/internal/utils"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CreateAPIKeyRequest struct {
+	Name          string   `json:"name" binding:"required,max=100"`
+	Scopes        []string `json:"scopes" binding:"required,min=1,dive,required"`
+	RateLimit     int      `json:"rate_limit" binding:"min=0,max=6000"`     // request per menit, 0 = default gateway
+	ExpiresInDays int      `json:"expires_in_days" binding:"min=0,max=365"` // 0 = tidak kedaluwarsa
+}
+
+type ResolveAPIKeyRequest struct {
+	Key string `json:"key" binding:"required"`
+}
+
+func currentUser(c *gin.Context) *utils.AccessClaims {
+	return c.MustGet("claims").(*utils.AccessClaims)
+}
+
+// POST /auth/api-keys. Key mentah hanya ada di response ini.
+func CreateAPIKey(c *gin.Context) {
+	var req CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Input tidak valid"})
+		return
+	}
+	ttl := time.Duration(req.ExpiresInDays) * 24 * time.Hour
+	raw, key, err := service.CreateAPIKey(c.Request.Context(), currentUser(c).UserID, req.Name, req.Scopes, req.RateLimit, ttl)
+	if errors.Is(err, service.ErrInvalidScope) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, service.ErrScopeNotAllowed) {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal membuat API key"})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"api_key": raw, "key": key})
+}
+
+// GET /auth/api-keys, termasuk yang sudah dicabut / kedaluwarsa.
+func ListAPIKeys(c *gin.Context) {
+	keys, err := service.ListAPIKeys(c.Request.Context(), currentUser(c).UserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil API key"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"api_keys": keys})
+}
+
+// DELETE /auth/api-keys/:id
+func RevokeAPIKey(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Input tidak valid"})
+		return
+	}
+	ok, err := service.RevokeAPIKey(c.Request.Context(), currentUser(c).UserID, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mencabut API key"})
+		return
+	}
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "API key tidak ditemukan"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "API key dicabut"})
+}
+
+// Handler Internal: Dipanggil oleh API Gateway untuk header X-API-Key
+func ResolveAPIKey(c *gin.Context) {
+	var req ResolveAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid data"})
+		return
+	}
+	key, user, err := service.ResolveAPIKey(c.Request.Context(), req.Key)
+	if errors.Is(err, service.ErrAPIKeyInvalid) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "API key not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal error"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"key_id":     key.ID,
+		"user_id":    strconv.FormatInt(user.ID, 10),
+		"username":   user.Username,
+		"scopes":     key.Scopes,
+		"rate_limit": key.RateLimit,
+	})
+}
@@ -2,39 +2,71 @@
 package handler
 
 import (
+	"auth-service/internal/metrics"
 	"auth-service/internal/repository" // Pastikan import ini ada
 	"auth-service/internal/service"
 	"auth-service/internal/utils"      // Pastikan import ini ada
-	"fmt"
+	"context"
+	"errors"
+	"math"
+	"shared/logging"
+	"shared/server"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
-// Struct untuk Login/Register/Verify
-type AuthRequest struct {
-	Username string `json:"username"`
-	Email    string `json:"email"`
-	Password string `json:"password"`
-	Code     string `json:"code"`
+// Request body per endpoint. Aturan binding harus sama dengan skema di
+// api/openapi.json (gateway memvalidasi request pakai spec itu). Password
+// di-hash dengan argon2 (tanpa batas panjang); max=128 hanya batas wajar
+// ukuran input.
+type RegisterRequest struct {
+	Username string `json:"username" binding:"required,min=3,max=50"`
+	Email    string `json:"email" binding:"required,email"`
+	Password string `json:"password" binding:"required,min=8,max=128"`
+}
+
+type VerifyRequest struct {
+	Email string `json:"email" binding:"required,email"`
+	Code  string `json:"code" binding:"required,len=6,numeric"`
+}
+
+type ResendVerificationRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type LoginRequest struct {
+	Email    string `json:"email" binding:"required,email"`
+	Password string `json:"password" binding:"required"`
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type ResetPasswordRequest struct {
+	Email       string `json:"email" binding:"required,email"`
+	Code        string `json:"code" binding:"required,len=6,numeric"`
+	NewPassword string `json:"new_password" binding:"required,min=8,max=128"`
 }
 
-// --- TAMBAHKAN STRUCT INI (YANG HILANG) ---
 type ReceiptRequest struct {
-	UserID   int64   `json:"user_id"`
-	OrderID  string  `json:"order_id"`
-	Amount   float64 `json:"amount"`
+	UserID   int64   `json:"user_id" binding:"required,gt=0"`
+	OrderID  string  `json:"order_id" binding:"required"`
+	Amount   float64 `json:"amount" binding:"required,gt=0"`
 	ItemName string  `json:"item_name"`
 }
-// ------------------------------------------
 
 func Register(c *gin.Context) {
-	var req AuthRequest
+	var req RegisterRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Input tidak valid"})
 		return
 	}
-	if err := service.Register(req.Username, req.Email, req.Password); err != nil {
+	if err := service.Register(c.Request.Context(), req.Username, req.Email, req.Password); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -42,20 +74,47 @@ func Register(c *gin.Context) {
 }
 
 func Verify(c *gin.Context) {
-	var req AuthRequest
+	var req VerifyRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Input tidak valid"})
 		return
 	}
-	if err := service.VerifyEmail(req.Email, req.Code); err != nil {
+	if err := service.VerifyEmail(c.Request.Context(), req.Email, req.Code); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "Akun terverifikasi, silakan login"})
 }
 
+// Response sama untuk email terdaftar atau tidak; retry_after (detik) &
+// next_resend_at memberi tahu kapan boleh minta kode lagi.
+func ResendVerification(c *gin.Context) {
+	var req ResendVerificationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Input tidak valid"})
+		return
+	}
+	wait, err := service.ResendVerification(c.Request.Context(), req.Email, c.ClientIP())
+	if err != nil && !errors.Is(err, service.ErrResendLimited) {
+		logging.FromContext(c.Request.Context()).Error("kirim ulang verifikasi gagal", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengirim ulang kode verifikasi"})
+		return
+	}
+
+	retryAfter := int(math.Ceil(wait.Seconds()))
+	next := gin.H{"retry_after": retryAfter, "next_resend_at": time.Now().Add(wait).UTC().Format(time.RFC3339)}
+	if err != nil {
+		c.Header("Retry-After", strconv.Itoa(retryAfter))
+		next["error"] = err.Error()
+		c.JSON(http.StatusTooManyRequests, next)
+		return
+	}
+	next["message"] = "Jika akun belum terverifikasi, kode baru sudah dikirim ke email"
+	c.JSON(http.StatusOK, next)
+}
+
 func Login(c *gin.Context) {
-	var req AuthRequest
+	var req LoginRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Input tidak valid"})
 		return
@@ -66,7 +125,20 @@ func Login(c *gin.Context) {
 		deviceID = "unknown"
 	}
 
-	at, rt, err := service.Login(req.Email, req.Password, deviceID)
+	at, rt, err := service.Login(c.Request.Context(), req.Email, req.Password, deviceID, c.ClientIP())
+	if throttledResponse(c, err) {
+		return
+	}
+	// 2FA aktif: token baru diberikan di POST /auth/login/2fa
+	var twoFactor *service.TwoFactorRequiredError
+	if errors.As(err, &twoFactor) {
+		c.JSON(http.StatusOK, gin.H{
+			"two_factor_required": true,
+			"challenge_token":     twoFactor.ChallengeToken,
+			"expires_in":          int(service.ChallengeTTL.Seconds()),
+		})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
 		return
@@ -78,6 +150,27 @@ func Login(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"access_token": at})
 }
 
+// throttledResponse: 429 (backoff) / 423 (akun dikunci) dengan Retry-After
+// kalau err adalah LoginThrottledError.
+func throttledResponse(c *gin.Context, err error) bool {
+	var throttled *service.LoginThrottledError
+	if !errors.As(err, &throttled) {
+		return false
+	}
+	status := http.StatusTooManyRequests
+	if throttled.Locked {
+		status = http.StatusLocked
+	}
+	retryAfter := int(math.Ceil(throttled.RetryAfter.Seconds()))
+	c.Header("Retry-After", strconv.Itoa(retryAfter))
+	c.JSON(status, gin.H{
+		"error":       err.Error(),
+		"retry_after": retryAfter,
+		"retry_at":    time.Now().Add(throttled.RetryAfter).UTC().Format(time.RFC3339),
+	})
+	return true
+}
+
 func Refresh(c *gin.Context) {
 	rt, err := c.Cookie("refresh_token")
 	if err != nil {
@@ -86,7 +179,7 @@ func Refresh(c *gin.Context) {
 	}
 	deviceID := c.GetHeader("X-Device-ID")
 
-	newAt, newRt, err := service.RotateRefreshToken(rt, deviceID)
+	newAt, newRt, err := service.RotateRefreshToken(c.Request.Context(), rt, deviceID)
 	if err != nil {
 		c.SetCookie("refresh_token", "", -1, "/auth/refresh", "localhost", false, true)
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Session expired"})
@@ -98,13 +191,78 @@ func Refresh(c *gin.Context) {
 }
 
 func Logout(c *gin.Context) {
+	rt, _ := c.Cookie("refresh_token")
+	at := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+
+	if err := service.Logout(c.Request.Context(), rt, at); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal logout"})
+		return
+	}
+
 	c.SetCookie("refresh_token", "", -1, "/auth/refresh", "localhost", false, true)
 	c.JSON(http.StatusOK, gin.H{"message": "Logged out"})
 }
 
+// Response selalu sama, terdaftar atau tidak (anti enumerasi akun)
+func ForgotPassword(c *gin.Context) {
+	var req ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Input tidak valid"})
+		return
+	}
+	service.ForgotPassword(c.Request.Context(), req.Email)
+	c.JSON(http.StatusOK, gin.H{"message": "Jika email terdaftar, kode reset password sudah dikirim"})
+}
+
+func ResetPassword(c *gin.Context) {
+	var req ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Input tidak valid"})
+		return
+	}
+	err := service.ResetPassword(c.Request.Context(), req.Email, req.Code, req.NewPassword)
+	if errors.Is(err, service.ErrResetCodeInvalid) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		logging.FromContext(c.Request.Context()).Error("reset password gagal", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal reset password"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Password berhasil diubah, silakan login ulang"})
+}
+
+// Profil user yang sedang login
+func Me(c *gin.Context) {
+	claims := currentUser(c)
+	user, err := repository.GetUserByID(c.Request.Context(), claims.UserID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User tidak ditemukan"})
+		return
+	}
+	roles, err := repository.GetUserRoles(c.Request.Context(), user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil profil"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"id":       user.ID,
+		"username": user.Username,
+		"email":    user.Email,
+		"roles":    roles,
+	})
+}
+
+// Public key untuk verifikasi access token (dipakai API Gateway)
+func JWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, utils.Keys.JWKS())
+}
+
 // Handler Internal: Dipanggil oleh Payment Service
 func SendReceipt(c *gin.Context) {
-	var req ReceiptRequest // Sekarang Struct ini sudah ada definisinya di atas
+	var req ReceiptRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid data"})
 		return
@@ -112,22 +270,26 @@ func SendReceipt(c *gin.Context) {
 
 	// 1. Ambil Data User (Email & Username) dari DB Auth
 	// Pastikan function GetUserByID sudah ada di repository/auth_repo.go
-	user, err := repository.GetUserByID(req.UserID)
+	user, err := repository.GetUserByID(c.Request.Context(), req.UserID)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
 		return
 	}
 
-	// 2. Kirim Email secara Asynchronous (Go Routine) agar tidak blocking
-	go func() {
+	// 2. Kirim Email secara Asynchronous (Go Routine) agar tidak blocking,
+	// tetap ditunggu saat shutdown
+	ctx := context.WithoutCancel(c.Request.Context())
+	logger := logging.FromContext(ctx)
+	server.Go(func() {
 		// Pastikan function SendReceiptEmail sudah ada di utils/email.go
-		err := utils.SendReceiptEmail(user.Email, user.Username, req.OrderID, req.Amount, req.ItemName)
+		err := utils.SendReceiptEmail(ctx, user.Email, user.Username, req.OrderID, req.Amount, req.ItemName)
 		if err != nil {
-			fmt.Println("❌ Gagal kirim email receipt:", err)
+			metrics.ReceiptEmailFailures.Inc()
+			logger.Error("gagal kirim email receipt", "user_id", req.UserID, "order_id", req.OrderID, "error", err)
 		} else {
-			fmt.Println("✅ Email receipt terkirim ke:", user.Email)
+			logger.Info("email receipt terkirim", "user_id", req.UserID, "order_id", req.OrderID)
 		}
-	}()
+	})
 
 	c.JSON(http.StatusOK, gin.H{"message": "Receipt processed"})
 }
\ No newline at end of file
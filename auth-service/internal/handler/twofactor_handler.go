@@ -0,0 +1,127 @@
+package handler
+
+import (
+	"auth-service/internal/service"
+	"errors"
+	"net/http"
+	"shared/logging"
+
+	"github.com/gin-gonic/gin"
+)
+
+type TOTPCodeRequest struct {
+	Code string `json:"code" binding:"required,len=6,numeric"`
+}
+
+// CurrentPasswordRequest: password dicek ulang untuk enroll & disable 2FA.
+type CurrentPasswordRequest struct {
+	Password string `json:"password" binding:"required"`
+}
+
+type Login2FARequest struct {
+	ChallengeToken string `json:"challenge_token" binding:"required,max=64"`
+	Code           string `json:"code" binding:"required,max=20"` // TOTP 6 digit atau recovery code
+}
+
+// POST /auth/2fa/enroll, wajib password. Secret & URI otpauth:// untuk QR
+// code; 2FA baru aktif setelah confirm.
+func EnrollTOTP(c *gin.Context) {
+	var req CurrentPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Input tidak valid"})
+		return
+	}
+	secret, uri, err := service.EnrollTOTP(c.Request.Context(), currentUser(c).UserID, req.Password, c.ClientIP())
+	if throttledResponse(c, err) {
+		return
+	}
+	switch {
+	case errors.Is(err, service.ErrPasswordWrong):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrTwoFactorEnabled):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrTwoFactorUnavailable):
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+	case err != nil:
+		logging.FromContext(c.Request.Context()).Error("enroll 2FA gagal", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memulai 2FA"})
+	default:
+		c.JSON(http.StatusOK, gin.H{"secret": secret, "otpauth_url": uri})
+	}
+}
+
+// POST /auth/2fa/confirm. Recovery code hanya ada di response ini.
+func ConfirmTOTP(c *gin.Context) {
+	var req TOTPCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Input tidak valid"})
+		return
+	}
+	codes, err := service.ConfirmTOTP(c.Request.Context(), currentUser(c).UserID, req.Code)
+	switch {
+	case errors.Is(err, service.ErrTwoFactorCode), errors.Is(err, service.ErrTwoFactorNotEnrolled),
+		errors.Is(err, service.ErrEnrollmentExpired):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrTwoFactorEnabled):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrTwoFactorUnavailable):
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+	case err != nil:
+		logging.FromContext(c.Request.Context()).Error("konfirmasi 2FA gagal", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengaktifkan 2FA"})
+	default:
+		c.JSON(http.StatusOK, gin.H{"message": "2FA aktif, simpan recovery code di tempat aman", "recovery_codes": codes})
+	}
+}
+
+// POST /auth/2fa/disable, wajib password.
+func DisableTOTP(c *gin.Context) {
+	var req CurrentPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Input tidak valid"})
+		return
+	}
+	err := service.DisableTOTP(c.Request.Context(), currentUser(c).UserID, req.Password, c.ClientIP())
+	if throttledResponse(c, err) {
+		return
+	}
+	switch {
+	case errors.Is(err, service.ErrPasswordWrong):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrTwoFactorNotEnabled):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case err != nil:
+		logging.FromContext(c.Request.Context()).Error("mematikan 2FA gagal", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mematikan 2FA"})
+	default:
+		c.JSON(http.StatusOK, gin.H{"message": "2FA dimatikan"})
+	}
+}
+
+// POST /auth/login/2fa: langkah kedua login untuk user dengan 2FA.
+func Login2FA(c *gin.Context) {
+	var req Login2FARequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Input tidak valid"})
+		return
+	}
+	at, rt, err := service.Login2FA(c.Request.Context(), req.ChallengeToken, req.Code, c.ClientIP())
+	if throttledResponse(c, err) {
+		return
+	}
+	switch {
+	case errors.Is(err, service.ErrTwoFactorCode), errors.Is(err, service.ErrChallengeInvalid):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, service.ErrTwoFactorUnavailable):
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		logging.FromContext(c.Request.Context()).Error("login 2FA gagal", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal login"})
+		return
+	}
+
+	c.SetCookie("refresh_token", rt, 3600*24*28, "/auth/refresh", "localhost", false, true)
+	c.JSON(http.StatusOK, gin.H{"access_token": at})
+}
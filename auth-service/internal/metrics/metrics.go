@@ -0,0 +1,49 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Metrik domain auth-service. Metrik HTTP per route ada di shared/metrics.
+var (
+	Registrations = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "auth_registrations_total",
+		Help: "Registrasi user per hasil (success, duplicate, error).",
+	}, []string{"result"})
+
+	OTPVerifications = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "auth_otp_verifications_total",
+		Help: "Verifikasi OTP email per hasil (success, invalid, expired, locked, error).",
+	}, []string{"result"})
+
+	VerificationResends = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "auth_verification_resends_total",
+		Help: "Kirim ulang kode verifikasi per hasil (sent, skipped, cooldown, daily_limit, error).",
+	}, []string{"result"})
+
+	Logins = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "auth_logins_total",
+		Help: "Percobaan login per hasil (success, 2fa_required, invalid_credentials, unverified, throttled, locked, error).",
+	}, []string{"result"})
+
+	PasswordResets = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "auth_password_resets_total",
+		Help: "Lupa/reset password per hasil (requested, unknown_email, success, invalid, error).",
+	}, []string{"result"})
+
+	TwoFactor = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "auth_two_factor_total",
+		Help: "Event 2FA per aksi & hasil (enroll, confirm, disable, login; success, invalid, recovery, expired, error).",
+	}, []string{"action", "result"})
+
+	RefreshTokenReuse = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "auth_refresh_token_reuse_total",
+		Help: "Refresh token yang sudah di-revoke dipakai lagi (indikasi token dicuri).",
+	})
+
+	ReceiptEmailFailures = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "auth_receipt_email_failures_total",
+		Help: "Email struk pembayaran yang gagal dikirim.",
+	})
+)
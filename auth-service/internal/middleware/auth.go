@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"auth-service/internal/service"
+	"auth-service/internal/utils"
+	"net/http"
+	"shared/logging"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequireUser: endpoint yang butuh login (Bearer access token). Route /auth di
+// gateway tidak memakai AuthMiddleware, jadi token dicek di sini. Klaimnya
+// disimpan di context dengan key "claims".
+func RequireUser() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tokenString, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !ok || tokenString == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+		claims, err := utils.ParseAccessToken(tokenString)
+		if err != nil || claims.TokenID == "" || claims.SessionID == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Token tidak valid"})
+			return
+		}
+		revoked, err := service.AccessTokenRevoked(c.Request.Context(), claims)
+		if err != nil {
+			// Beda dengan gateway: endpoint ini mengelola kredensial, jadi fail closed
+			logging.FromContext(c.Request.Context()).Error("cek denylist gagal", "error", err)
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "Layanan sedang bermasalah"})
+			return
+		}
+		if revoked {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Token sudah dicabut"})
+			return
+		}
+		c.Set("claims", claims)
+		c.Next()
+	}
+}
@@ -15,9 +15,33 @@ type RefreshToken struct {
 	ID                int64      `json:"id"`
 	UserID            int64      `json:"user_id"`
 	TokenHash         string     `json:"-"`
+	SessionID         string     `json:"session_id"`
 	DeviceID          string     `json:"device_id"`
 	ExpiresAt         time.Time  `json:"expires_at"` 
 	AbsoluteExpiresAt time.Time  `json:"absolute_expires_at"`
 	RevokedAt         *time.Time `json:"revoked_at"`
 }
 
+
+// APIKey: key mentah tidak pernah disimpan, hanya hash-nya.
+type APIKey struct {
+	ID         int64      `json:"id"`
+	UserID     int64      `json:"user_id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	KeyHash    string     `json:"-"`
+	Scopes     []string   `json:"scopes"`
+	RateLimit  int        `json:"rate_limit"` // request per menit, 0 = default gateway
+	ExpiresAt  *time.Time `json:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// TOTP: secret 2FA milik user, SecretEnc terenkripsi (lihat utils.EncryptSecret).
+type TOTP struct {
+	UserID    int64
+	SecretEnc string
+	EnabledAt *time.Time // nil = belum dikonfirmasi
+	LastStep  int64
+}
@@ -3,65 +3,265 @@ package repository
 import (
 	"auth-service/internal/database"
 	"auth-service/internal/models"
+	"context"
 	"database/sql"
+	"fmt"
+
+	"github.com/lib/pq"
 )
 
-func CreateUser(user *models.User) error {
+func CreateUser(ctx context.Context, user *models.User) error {
 	query := `INSERT INTO users (username, email, password_hash, is_verified) 
               VALUES ($1, $2, $3, $4) RETURNING id, created_at`
-	return database.DB.QueryRow(query, user.Username, user.Email, user.PasswordHash, user.IsVerified).
+	return database.DB.QueryRowContext(ctx, query, user.Username, user.Email, user.PasswordHash, user.IsVerified).
 		Scan(&user.ID, &user.CreatedAt)
 }
 
-func GetUserByEmail(email string) (*models.User, error) {
+func GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
 	user := &models.User{}
 	query := `SELECT id, username, email, password_hash, is_verified FROM users WHERE email = $1`
-	err := database.DB.QueryRow(query, email).Scan(&user.ID, &user.Username, &user.Email, &user.PasswordHash, &user.IsVerified)
+	err := database.DB.QueryRowContext(ctx, query, email).Scan(&user.ID, &user.Username, &user.Email, &user.PasswordHash, &user.IsVerified)
 	if err != nil {
 		return nil, err
 	}
 	return user, nil
 }
 
-func UpdateUserVerified(email string) error {
-	_, err := database.DB.Exec("UPDATE users SET is_verified = TRUE WHERE email = $1", email)
+func UpdateUserVerified(ctx context.Context, email string) error {
+	_, err := database.DB.ExecContext(ctx, "UPDATE users SET is_verified = TRUE WHERE email = $1", email)
 	return err
 }
 
-func CreateRefreshToken(rt models.RefreshToken) error {
-	query := `INSERT INTO refresh_tokens (user_id, token_hash, device_id, expires_at, absolute_expires_at, created_at) 
-              VALUES ($1, $2, $3, $4, $5, NOW())`
-	_, err := database.DB.Exec(query, rt.UserID, rt.TokenHash, rt.DeviceID, rt.ExpiresAt, rt.AbsoluteExpiresAt)
+func UpdateUserPassword(ctx context.Context, userID int64, passwordHash string) error {
+	_, err := database.DB.ExecContext(ctx, "UPDATE users SET password_hash = $1 WHERE id = $2", passwordHash, userID)
 	return err
 }
 
-func GetRefreshTokenByHash(hash string) (*models.RefreshToken, error) {
+func CreateRefreshToken(ctx context.Context, rt models.RefreshToken) error {
+	query := `INSERT INTO refresh_tokens (user_id, token_hash, session_id, device_id, expires_at, absolute_expires_at, created_at) 
+              VALUES ($1, $2, $3, $4, $5, $6, NOW())`
+	_, err := database.DB.ExecContext(ctx, query, rt.UserID, rt.TokenHash, rt.SessionID, rt.DeviceID, rt.ExpiresAt, rt.AbsoluteExpiresAt)
+	return err
+}
+
+func GetRefreshTokenByHash(ctx context.Context, hash string) (*models.RefreshToken, error) {
 	rt := &models.RefreshToken{}
-	query := `SELECT id, user_id, token_hash, expires_at, absolute_expires_at, revoked_at, device_id 
+	query := `SELECT id, user_id, token_hash, session_id, expires_at, absolute_expires_at, revoked_at, device_id 
               FROM refresh_tokens WHERE token_hash = $1`
-	err := database.DB.QueryRow(query, hash).Scan(&rt.ID, &rt.UserID, &rt.TokenHash, &rt.ExpiresAt, &rt.AbsoluteExpiresAt, &rt.RevokedAt, &rt.DeviceID)
+	err := database.DB.QueryRowContext(ctx, query, hash).Scan(&rt.ID, &rt.UserID, &rt.TokenHash, &rt.SessionID, &rt.ExpiresAt, &rt.AbsoluteExpiresAt, &rt.RevokedAt, &rt.DeviceID)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
 	return rt, err
 }
 
-func RevokeRefreshToken(id int64) error {
-	_, err := database.DB.Exec("UPDATE refresh_tokens SET revoked_at = NOW() WHERE id = $1", id)
+func RevokeRefreshToken(ctx context.Context, id int64) error {
+	_, err := database.DB.ExecContext(ctx, "UPDATE refresh_tokens SET revoked_at = NOW() WHERE id = $1", id)
+	return err
+}
+
+func RevokeSessionTokens(ctx context.Context, sessionID string) error {
+	_, err := database.DB.ExecContext(ctx, "UPDATE refresh_tokens SET revoked_at = NOW() WHERE session_id = $1 AND revoked_at IS NULL", sessionID)
 	return err
 }
 
-func RevokeAllUserTokens(userID int64) error {
-	_, err := database.DB.Exec("UPDATE refresh_tokens SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL", userID)
+func RevokeAllUserTokens(ctx context.Context, userID int64) error {
+	_, err := database.DB.ExecContext(ctx, "UPDATE refresh_tokens SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL", userID)
 	return err
 }
 
-func GetUserByID(id int64) (*models.User, error) {
+func GetUserByID(ctx context.Context, id int64) (*models.User, error) {
 	user := &models.User{}
 	query := `SELECT id, username, email FROM users WHERE id = $1`
-	err := database.DB.QueryRow(query, id).Scan(&user.ID, &user.Username, &user.Email)
+	err := database.DB.QueryRowContext(ctx, query, id).Scan(&user.ID, &user.Username, &user.Email)
 	if err != nil {
 		return nil, err
 	}
 	return user, nil
-}
\ No newline at end of file
+}
+
+// --- ROLES ---
+
+func GetUserRoles(ctx context.Context, userID int64) ([]string, error) {
+	rows, err := database.DB.QueryContext(ctx, `SELECT r.name FROM user_roles ur JOIN roles r ON r.id = ur.role_id
+              WHERE ur.user_id = $1 ORDER BY r.name`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	roles := []string{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		roles = append(roles, name)
+	}
+	return roles, rows.Err()
+}
+
+// AssignRole memberi role ke user. Role harus sudah ada di tabel roles.
+func AssignRole(ctx context.Context, userID int64, role string) error {
+	res, err := database.DB.ExecContext(ctx, `INSERT INTO user_roles (user_id, role_id)
+              SELECT $1, id FROM roles WHERE name = $2 ON CONFLICT DO NOTHING`, userID, role)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		var exists bool
+		database.DB.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM roles WHERE name = $1)", role).Scan(&exists)
+		if !exists {
+			return fmt.Errorf("role %q tidak ada", role)
+		}
+	}
+	return nil
+}
+
+// --- API KEYS ---
+
+const apiKeyColumns = `id, user_id, name, prefix, key_hash, scopes, rate_limit, expires_at, revoked_at, last_used_at, created_at`
+
+func scanAPIKey(row interface{ Scan(...any) error }) (*models.APIKey, error) {
+	k := &models.APIKey{}
+	err := row.Scan(&k.ID, &k.UserID, &k.Name, &k.Prefix, &k.KeyHash, pq.Array(&k.Scopes), &k.RateLimit,
+		&k.ExpiresAt, &k.RevokedAt, &k.LastUsedAt, &k.CreatedAt)
+	return k, err
+}
+
+func CreateAPIKey(ctx context.Context, k *models.APIKey) error {
+	query := `INSERT INTO api_keys (user_id, name, prefix, key_hash, scopes, rate_limit, expires_at)
+              VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id, created_at`
+	return database.DB.QueryRowContext(ctx, query, k.UserID, k.Name, k.Prefix, k.KeyHash, pq.Array(k.Scopes), k.RateLimit, k.ExpiresAt).
+		Scan(&k.ID, &k.CreatedAt)
+}
+
+func ListAPIKeys(ctx context.Context, userID int64) ([]*models.APIKey, error) {
+	rows, err := database.DB.QueryContext(ctx, `SELECT `+apiKeyColumns+` FROM api_keys WHERE user_id = $1 ORDER BY id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []*models.APIKey{}
+	for rows.Next() {
+		k, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+	return keys, rows.Err()
+}
+
+// GetAPIKeyByHash: nil tanpa error kalau key tidak ada.
+func GetAPIKeyByHash(ctx context.Context, hash string) (*models.APIKey, error) {
+	k, err := scanAPIKey(database.DB.QueryRowContext(ctx, `SELECT `+apiKeyColumns+` FROM api_keys WHERE key_hash = $1`, hash))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return k, err
+}
+
+// RevokeAPIKey: false kalau key tidak ada, bukan milik user, atau sudah dicabut.
+func RevokeAPIKey(ctx context.Context, userID, id int64) (bool, error) {
+	res, err := database.DB.ExecContext(ctx, "UPDATE api_keys SET revoked_at = NOW() WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL", id, userID)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func TouchAPIKey(ctx context.Context, id int64) error {
+	_, err := database.DB.ExecContext(ctx, "UPDATE api_keys SET last_used_at = NOW() WHERE id = $1", id)
+	return err
+}
+
+// --- 2FA (TOTP) ---
+
+// GetTOTP: nil tanpa error kalau user belum pernah enroll.
+func GetTOTP(ctx context.Context, userID int64) (*models.TOTP, error) {
+	t := &models.TOTP{}
+	err := database.DB.QueryRowContext(ctx, `SELECT user_id, secret_enc, enabled_at, last_step FROM user_totp WHERE user_id = $1`, userID).
+		Scan(&t.UserID, &t.SecretEnc, &t.EnabledAt, &t.LastStep)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return t, err
+}
+
+// SaveTOTPSecret menyimpan secret enrollment baru. Secret yang sudah aktif
+// tidak ditimpa (false).
+func SaveTOTPSecret(ctx context.Context, userID int64, secretEnc string) (bool, error) {
+	query := `INSERT INTO user_totp (user_id, secret_enc) VALUES ($1, $2)
+              ON CONFLICT (user_id) DO UPDATE SET secret_enc = EXCLUDED.secret_enc, last_step = 0, created_at = NOW()
+              WHERE user_totp.enabled_at IS NULL`
+	res, err := database.DB.ExecContext(ctx, query, userID, secretEnc)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// UseTOTPStep mencatat time step kode yang dipakai. false kalau step itu
+// (atau yang lebih baru) sudah pernah dipakai: kode di-replay.
+func UseTOTPStep(ctx context.Context, userID, step int64) (bool, error) {
+	res, err := database.DB.ExecContext(ctx, "UPDATE user_totp SET last_step = $2 WHERE user_id = $1 AND last_step < $2", userID, step)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// EnableTOTP mengaktifkan 2FA dan mengganti semua recovery code dalam satu
+// transaksi.
+func EnableTOTP(ctx context.Context, userID int64, recoveryHashes []string) error {
+	tx, err := database.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "UPDATE user_totp SET enabled_at = NOW() WHERE user_id = $1", userID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM recovery_codes WHERE user_id = $1", userID); err != nil {
+		return err
+	}
+	for _, h := range recoveryHashes {
+		if _, err := tx.ExecContext(ctx, "INSERT INTO recovery_codes (user_id, code_hash) VALUES ($1, $2)", userID, h); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// DisableTOTP menghapus secret & recovery code user.
+func DisableTOTP(ctx context.Context, userID int64) error {
+	tx, err := database.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM recovery_codes WHERE user_id = $1", userID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM user_totp WHERE user_id = $1", userID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// UseRecoveryCode: false kalau code tidak ada atau sudah dipakai.
+func UseRecoveryCode(ctx context.Context, userID int64, codeHash string) (bool, error) {
+	res, err := database.DB.ExecContext(ctx, "UPDATE recovery_codes SET used_at = NOW() WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL", userID, codeHash)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
@@ -0,0 +1,169 @@
+package service
+
+import (
+	"auth-service/internal/models"
+	"auth-service/internal/repository"
+	"auth-service/internal/utils"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"shared/logging"
+	"slices"
+	"time"
+)
+
+// --- API KEY ---
+// Untuk client mesin (kiosk partner, batch job). Format key:
+//
+//	gk_<43 karakter base64url>
+//
+// Key punya entropi 256 bit, jadi cukup disimpan sebagai SHA-256 (sama
+// seperti refresh token), tidak perlu Argon2. Gateway me-resolve key lewat
+// /auth/internal/api-keys/resolve dan memakai scopes & rate_limit-nya.
+
+const (
+	apiKeyPrefix    = "gk_"
+	apiKeyShownLen  = len(apiKeyPrefix) + 8 // bagian key yang boleh ditampilkan di daftar
+	MaxAPIKeyRate   = 6000                  // request per menit
+	maxAPIKeyLength = 128
+)
+
+// APIKeyScopes: scope yang boleh diberikan ke API key beserta role yang
+// boleh memberikannya. Harus sama dengan policy.yaml gateway: scope hanya
+// boleh diberikan oleh role yang di aturan yang sama sudah punya akses itu,
+// supaya API key tidak lebih kuat dari pemiliknya.
+var APIKeyScopes = map[string][]string{
+	"orders:read":    {"customer", "admin"},
+	"orders:write":   {"customer", "admin"},
+	"payments:read":  {"customer", "admin"},
+	"payments:write": {"customer"},
+}
+
+var (
+	ErrInvalidScope    = errors.New("scope tidak dikenal")
+	ErrScopeNotAllowed = errors.New("scope melebihi akses role user")
+	ErrAPIKeyInvalid   = errors.New("api key tidak valid")
+)
+
+// scopeAllowed: apakah salah satu roles boleh memberikan scope.
+func scopeAllowed(scope string, roles []string) bool {
+	return slices.ContainsFunc(APIKeyScopes[scope], func(r string) bool {
+		return slices.Contains(roles, r)
+	})
+}
+
+// checkScopes: semua scope harus dikenal dan boleh diberikan oleh roles.
+func checkScopes(scopes, roles []string) error {
+	for _, s := range scopes {
+		if _, ok := APIKeyScopes[s]; !ok {
+			return fmt.Errorf("%w: %s", ErrInvalidScope, s)
+		}
+		if !scopeAllowed(s, roles) {
+			return fmt.Errorf("%w: %s", ErrScopeNotAllowed, s)
+		}
+	}
+	return nil
+}
+
+func generateAPIKey() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return apiKeyPrefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// CreateAPIKey membuat key baru milik user. Key mentah hanya dikembalikan di
+// sini, setelah itu tidak bisa dilihat lagi. ttl 0 = tidak kedaluwarsa.
+func CreateAPIKey(ctx context.Context, userID int64, name string, scopes []string, rateLimit int, ttl time.Duration) (string, *models.APIKey, error) {
+	ctx, span := tracer.Start(ctx, "service.CreateAPIKey")
+	defer span.End()
+
+	// Role diambil dari DB, bukan dari claim access token yang bisa basi
+	roles, err := repository.GetUserRoles(ctx, userID)
+	if err != nil {
+		return "", nil, err
+	}
+	if err := checkScopes(scopes, roles); err != nil {
+		return "", nil, err
+	}
+	slices.Sort(scopes)
+
+	raw, err := generateAPIKey()
+	if err != nil {
+		return "", nil, err
+	}
+	key := &models.APIKey{
+		UserID:    userID,
+		Name:      name,
+		Prefix:    raw[:apiKeyShownLen],
+		KeyHash:   utils.HashToken(raw),
+		Scopes:    slices.Compact(scopes),
+		RateLimit: rateLimit,
+	}
+	if ttl > 0 {
+		exp := time.Now().Add(ttl)
+		key.ExpiresAt = &exp
+	}
+	if err := repository.CreateAPIKey(ctx, key); err != nil {
+		return "", nil, err
+	}
+	logging.FromContext(ctx).Info("api key dibuat", "user_id", userID, "key_id", key.ID, "scopes", key.Scopes)
+	return raw, key, nil
+}
+
+func ListAPIKeys(ctx context.Context, userID int64) ([]*models.APIKey, error) {
+	ctx, span := tracer.Start(ctx, "service.ListAPIKeys")
+	defer span.End()
+	return repository.ListAPIKeys(ctx, userID)
+}
+
+// RevokeAPIKey: false kalau key tidak ditemukan (atau milik user lain).
+// Gateway menyimpan hasil resolve sebentar, jadi key yang dicabut masih bisa
+// lolos paling lama selama api_keys.cache_ttl gateway.
+func RevokeAPIKey(ctx context.Context, userID, keyID int64) (bool, error) {
+	ctx, span := tracer.Start(ctx, "service.RevokeAPIKey")
+	defer span.End()
+
+	ok, err := repository.RevokeAPIKey(ctx, userID, keyID)
+	if ok {
+		logging.FromContext(ctx).Info("api key dicabut", "user_id", userID, "key_id", keyID)
+	}
+	return ok, err
+}
+
+// ResolveAPIKey mencari key aktif beserta pemiliknya. ErrAPIKeyInvalid kalau
+// key tidak ada, sudah dicabut, atau kedaluwarsa.
+func ResolveAPIKey(ctx context.Context, raw string) (*models.APIKey, *models.User, error) {
+	ctx, span := tracer.Start(ctx, "service.ResolveAPIKey")
+	defer span.End()
+
+	if len(raw) > maxAPIKeyLength || len(raw) <= apiKeyShownLen || raw[:len(apiKeyPrefix)] != apiKeyPrefix {
+		return nil, nil, ErrAPIKeyInvalid
+	}
+	key, err := repository.GetAPIKeyByHash(ctx, utils.HashToken(raw))
+	if err != nil {
+		return nil, nil, err
+	}
+	if key == nil || key.RevokedAt != nil || (key.ExpiresAt != nil && time.Now().After(*key.ExpiresAt)) {
+		return nil, nil, ErrAPIKeyInvalid
+	}
+	user, err := repository.GetUserByID(ctx, key.UserID)
+	if err != nil {
+		return nil, nil, err
+	}
+	// Role pemilik bisa dicabut setelah key dibuat: scope yang sudah tidak
+	// boleh diberikan role-nya ikut hilang
+	roles, err := repository.GetUserRoles(ctx, key.UserID)
+	if err != nil {
+		return nil, nil, err
+	}
+	key.Scopes = slices.DeleteFunc(key.Scopes, func(s string) bool { return !scopeAllowed(s, roles) })
+
+	if err := repository.TouchAPIKey(ctx, key.ID); err != nil {
+		logging.FromContext(ctx).Warn("gagal update last_used_at api key", "key_id", key.ID, "error", err)
+	}
+	return key, user, nil
+}
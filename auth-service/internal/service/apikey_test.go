@@ -0,0 +1,32 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckScopes(t *testing.T) {
+	cases := []struct {
+		name   string
+		scopes []string
+		roles  []string
+		want   error
+	}{
+		{"customer semua scope", []string{"orders:read", "orders:write", "payments:read", "payments:write"}, []string{"customer"}, nil},
+		{"admin tidak bisa bayar", []string{"payments:write"}, []string{"admin"}, ErrScopeNotAllowed},
+		{"admin baca order", []string{"orders:read"}, []string{"admin"}, nil},
+		{"merchant tanpa scope order", []string{"orders:read"}, []string{"merchant"}, ErrScopeNotAllowed},
+		{"tanpa role", []string{"orders:read"}, []string{}, ErrScopeNotAllowed},
+		{"salah satu role cukup", []string{"payments:write"}, []string{"merchant", "customer"}, nil},
+		{"scope tidak dikenal", []string{"orders:delete"}, []string{"admin"}, ErrInvalidScope},
+	}
+	for _, tc := range cases {
+		err := checkScopes(tc.scopes, tc.roles)
+		if tc.want == nil {
+			assert.NoError(t, err, tc.name)
+		} else {
+			assert.ErrorIs(t, err, tc.want, tc.name)
+		}
+	}
+}
@@ -2,30 +2,40 @@ package service
 
 import (
 	"auth-service/internal/database"
+	"auth-service/internal/metrics"
 	"auth-service/internal/models"
 	"auth-service/internal/repository"
 	"auth-service/internal/utils"
 	"context"
 	"errors"
-	"math/rand"
-	"strconv"
+	"shared/logging"
+	"shared/server"
 	"time"
 
-	"github.com/redis/go-redis/v9"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel"
 )
 
-func generateOTP() string {
-	return strconv.Itoa(100000 + rand.Intn(900000))
-}
+// tracer: span per fungsi service; query Postgres & Redis di dalamnya jadi
+// child span (lihat database.InitDB / InitRedis).
+var tracer = otel.Tracer("auth-service/internal/service")
+
+// DefaultRole: role yang didapat setiap user baru saat register.
+const DefaultRole = "customer"
 
 // 1. REGISTER
-func Register(username, email, password string) error {
-	if u, _ := repository.GetUserByEmail(email); u != nil {
+func Register(ctx context.Context, username, email, password string) error {
+	ctx, span := tracer.Start(ctx, "service.Register")
+	defer span.End()
+
+	if u, _ := repository.GetUserByEmail(ctx, email); u != nil {
+		metrics.Registrations.WithLabelValues("duplicate").Inc()
 		return errors.New("email sudah terdaftar")
 	}
 
 	hashedPwd, err := utils.HashPassword(password)
 	if err != nil {
+		metrics.Registrations.WithLabelValues("error").Inc()
 		return err
 	}
 
@@ -36,75 +46,151 @@ func Register(username, email, password string) error {
 		IsVerified:   false,
 	}
 
-	if err := repository.CreateUser(&newUser); err != nil {
+	if err := repository.CreateUser(ctx, &newUser); err != nil {
+		metrics.Registrations.WithLabelValues("error").Inc()
+		return err
+	}
+	if err := repository.AssignRole(ctx, newUser.ID, DefaultRole); err != nil {
+		metrics.Registrations.WithLabelValues("error").Inc()
 		return err
 	}
 
 	otp := generateOTP()
-	err = database.RDB.Set(context.Background(), "verif:"+email, otp, 15*time.Minute).Err()
-	if err != nil {
+	if err := storeOTP(ctx, "verif", email, otp, VerificationTTL); err != nil {
+		metrics.Registrations.WithLabelValues("error").Inc()
 		return err
 	}
+	// Kirim ulang baru boleh setelah cooldown (lihat ResendVerification)
+	database.RDB.Set(ctx, "verif:cooldown:"+email, 1, ResendCooldown)
+	metrics.Registrations.WithLabelValues("success").Inc()
 
-	go utils.SendVerificationEmail(email, otp)
+	emailCtx := context.WithoutCancel(ctx)
+	server.Go(func() { utils.SendVerificationEmail(emailCtx, email, otp) })
 	return nil
 }
 
 // 2. VERIFY
-func VerifyEmail(email, code string) error {
-	val, err := database.RDB.Get(context.Background(), "verif:"+email).Result()
-	if err == redis.Nil {
-		return errors.New("kode verifikasi kadaluarsa")
-	}
-	if val != code {
+func VerifyEmail(ctx context.Context, email, code string) error {
+	ctx, span := tracer.Start(ctx, "service.VerifyEmail")
+	defer span.End()
+
+	switch err := checkOTP(ctx, "verif", email, code); {
+	case errors.Is(err, errOTPExpired):
+		metrics.OTPVerifications.WithLabelValues("expired").Inc()
+		return errors.New("kode verifikasi kadaluarsa, minta kode baru lewat kirim ulang verifikasi")
+	case errors.Is(err, errOTPLocked):
+		metrics.OTPVerifications.WithLabelValues("locked").Inc()
+		return errors.New("terlalu banyak percobaan, minta kode baru lewat kirim ulang verifikasi")
+	case errors.Is(err, errOTPInvalid):
+		metrics.OTPVerifications.WithLabelValues("invalid").Inc()
 		return errors.New("kode salah")
+	case err != nil:
+		metrics.OTPVerifications.WithLabelValues("error").Inc()
+		return err
 	}
 
-	if err := repository.UpdateUserVerified(email); err != nil {
+	if err := repository.UpdateUserVerified(ctx, email); err != nil {
+		metrics.OTPVerifications.WithLabelValues("error").Inc()
 		return err
 	}
+	metrics.OTPVerifications.WithLabelValues("success").Inc()
 
-	database.RDB.Del(context.Background(), "verif:"+email)
+	database.RDB.Del(ctx, "verif:"+email, "verif:attempts:"+email)
 	return nil
 }
 
 // 3. LOGIN
-func Login(email, password, deviceID string) (string, string, error) {
-	user, err := repository.GetUserByEmail(email)
-	if err != nil {
-		return "", "", errors.New("email atau password salah")
+func Login(ctx context.Context, email, password, deviceID, ip string) (string, string, error) {
+	ctx, span := tracer.Start(ctx, "service.Login")
+	defer span.End()
+
+	if err := checkLoginThrottle(ctx, email, ip); err != nil {
+		if err.(*LoginThrottledError).Locked {
+			metrics.Logins.WithLabelValues("locked").Inc()
+		} else {
+			metrics.Logins.WithLabelValues("throttled").Inc()
+		}
+		return "", "", err
 	}
 
-	if !utils.CheckPassword(password, user.PasswordHash) {
+	// Email tidak terdaftar tetap menghitung hash (dummy) supaya waktunya
+	// sama dengan akun yang ada
+	user, err := repository.GetUserByEmail(ctx, email)
+	passwordHash := dummyHash()
+	if err == nil {
+		passwordHash = user.PasswordHash
+	}
+	if !utils.CheckPassword(password, passwordHash) || err != nil {
+		if lockFor := recordLoginFailure(ctx, email, ip); lockFor > 0 {
+			metrics.Logins.WithLabelValues("locked").Inc()
+			return "", "", &LoginThrottledError{Locked: true, RetryAfter: lockFor}
+		}
+		metrics.Logins.WithLabelValues("invalid_credentials").Inc()
 		return "", "", errors.New("email atau password salah")
 	}
-
 	if !user.IsVerified {
+		metrics.Logins.WithLabelValues("unverified").Inc()
 		return "", "", errors.New("akun belum diverifikasi, cek email anda")
 	}
 
-	accessToken, _ := utils.GenerateAccessToken(user.ID, user.Username)
+	// 2FA aktif: token baru diberikan setelah kode dicek di Login2FA. Counter
+	// login gagal belum direset, supaya password yang bocor tidak bisa dipakai
+	// minta challenge baru terus untuk menebak kode 2FA.
+	challenge, err := startTwoFactor(ctx, user.ID, deviceID)
+	if err != nil {
+		metrics.Logins.WithLabelValues("error").Inc()
+		return "", "", err
+	}
+	if challenge != "" {
+		metrics.Logins.WithLabelValues("2fa_required").Inc()
+		return "", "", &TwoFactorRequiredError{ChallengeToken: challenge}
+	}
+
+	accessToken, rawRefreshToken, err := issueTokens(ctx, user, deviceID)
+	if err != nil {
+		metrics.Logins.WithLabelValues("error").Inc()
+		return "", "", err
+	}
+	if err := resetLoginThrottle(ctx, email); err != nil {
+		logging.FromContext(ctx).Error("gagal reset counter login", "error", err)
+	}
+
+	metrics.Logins.WithLabelValues("success").Inc()
+	return accessToken, rawRefreshToken, nil
+}
+
+// issueTokens membuat sesi login baru: access token + refresh token.
+func issueTokens(ctx context.Context, user *models.User, deviceID string) (string, string, error) {
+	roles, err := repository.GetUserRoles(ctx, user.ID)
+	if err != nil {
+		return "", "", err
+	}
+
+	sessionID := uuid.New().String()
+	accessToken, _ := utils.GenerateAccessToken(user.ID, user.Username, sessionID, roles)
 	rawRefreshToken := utils.GenerateRefreshToken()
 
 	rt := models.RefreshToken{
 		UserID:            user.ID,
 		TokenHash:         utils.HashToken(rawRefreshToken),
+		SessionID:         sessionID,
 		DeviceID:          deviceID,
 		ExpiresAt:         time.Now().Add(28 * 24 * time.Hour),
 		AbsoluteExpiresAt: time.Now().Add(90 * 24 * time.Hour),
 	}
-	
-	if err := repository.CreateRefreshToken(rt); err != nil {
+	if err := repository.CreateRefreshToken(ctx, rt); err != nil {
 		return "", "", err
 	}
-
 	return accessToken, rawRefreshToken, nil
 }
 
 // 4. ROTATE REFRESH TOKEN
-func RotateRefreshToken(rawToken, deviceID string) (string, string, error) {
+func RotateRefreshToken(ctx context.Context, rawToken, deviceID string) (string, string, error) {
+	ctx, span := tracer.Start(ctx, "service.RotateRefreshToken")
+	defer span.End()
+
 	tokenHash := utils.HashToken(rawToken)
-	stored, err := repository.GetRefreshTokenByHash(tokenHash)
+	stored, err := repository.GetRefreshTokenByHash(ctx, tokenHash)
 	
 	if err != nil || stored == nil {
 		return "", "", errors.New("invalid token")
@@ -112,7 +198,9 @@ func RotateRefreshToken(rawToken, deviceID string) (string, string, error) {
 
 	// SECURITY: Token Reuse Detection
 	if stored.RevokedAt != nil {
-		repository.RevokeAllUserTokens(stored.UserID)
+		metrics.RefreshTokenReuse.Inc()
+		repository.RevokeAllUserTokens(ctx, stored.UserID)
+		revokeUser(ctx, stored.UserID) // access token yang masih beredar ikut ditolak gateway
 		return "", "", errors.New("security alert: token reuse detected")
 	}
 
@@ -120,19 +208,68 @@ func RotateRefreshToken(rawToken, deviceID string) (string, string, error) {
 		return "", "", errors.New("token expired")
 	}
 
-	repository.RevokeRefreshToken(stored.ID)
+	// Username & roles diambil ulang supaya perubahan role berlaku di refresh berikutnya
+	user, err := repository.GetUserByID(ctx, stored.UserID)
+	if err != nil {
+		return "", "", err
+	}
+	roles, err := repository.GetUserRoles(ctx, stored.UserID)
+	if err != nil {
+		return "", "", err
+	}
+
+	repository.RevokeRefreshToken(ctx, stored.ID)
 
-	newAccess, _ := utils.GenerateAccessToken(stored.UserID, "User") // Ideally fetch username
+	newAccess, _ := utils.GenerateAccessToken(stored.UserID, user.Username, stored.SessionID, roles)
 	newRefresh := utils.GenerateRefreshToken()
 
 	newRt := models.RefreshToken{
 		UserID:            stored.UserID,
 		TokenHash:         utils.HashToken(newRefresh),
+		SessionID:         stored.SessionID,
 		DeviceID:          stored.DeviceID,
 		ExpiresAt:         time.Now().Add(28 * 24 * time.Hour),
 		AbsoluteExpiresAt: stored.AbsoluteExpiresAt,
 	}
-	repository.CreateRefreshToken(newRt)
+	repository.CreateRefreshToken(ctx, newRt)
 
 	return newAccess, newRefresh, nil
+}
+
+// 5. LOGOUT
+// Mencabut sesi milik access token (dan/atau refresh token) yang dikirim:
+// refresh token sesi itu di-revoke di DB, access token-nya masuk denylist.
+func Logout(ctx context.Context, rawRefreshToken, accessToken string) error {
+	ctx, span := tracer.Start(ctx, "service.Logout")
+	defer span.End()
+
+	sessions := map[string]bool{}
+
+	if rawRefreshToken != "" {
+		stored, err := repository.GetRefreshTokenByHash(ctx, utils.HashToken(rawRefreshToken))
+		if err == nil && stored != nil {
+			sessions[stored.SessionID] = true
+		}
+	}
+
+	if accessToken != "" {
+		if claims, err := utils.ParseAccessToken(accessToken); err == nil {
+			if err := revokeAccessToken(ctx, claims.TokenID, claims.ExpiresAt); err != nil {
+				return err
+			}
+			if claims.SessionID != "" {
+				sessions[claims.SessionID] = true
+			}
+		}
+	}
+
+	for sid := range sessions {
+		if err := repository.RevokeSessionTokens(ctx, sid); err != nil {
+			return err
+		}
+		if err := revokeSession(ctx, sid); err != nil {
+			return err
+		}
+	}
+	return nil
 }
\ No newline at end of file
@@ -0,0 +1,115 @@
+package service
+
+import (
+	"auth-service/internal/database"
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"shared/logging"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// MaxOTPAttempts: jumlah percobaan sebelum kode dihapus dan user harus
+// minta kode baru. Dengan 6 digit, peluang tebakan acak lolos 5/1.000.000.
+const MaxOTPAttempts = 5
+
+var (
+	errOTPExpired = errors.New("otp kadaluarsa")
+	errOTPInvalid = errors.New("otp salah")
+	errOTPLocked  = errors.New("otp dikunci, terlalu banyak percobaan")
+)
+
+func generateOTP() string {
+	n, _ := rand.Int(rand.Reader, big.NewInt(1_000_000))
+	return fmt.Sprintf("%06d", n)
+}
+
+// securityLog: log kejadian keamanan (percobaan gagal, lockout, dll.), bisa
+// difilter lewat field log=security.
+func securityLog(ctx context.Context) *slog.Logger {
+	return logging.FromContext(ctx).With("log", "security")
+}
+
+// storeOTP menyimpan kode baru di <purpose>:<email> (purpose: verif, reset)
+// dan mereset counter salah tebaknya.
+func storeOTP(ctx context.Context, purpose, email, code string, ttl time.Duration) error {
+	pipe := database.RDB.TxPipeline()
+	pipe.Set(ctx, purpose+":"+email, code, ttl)
+	pipe.Del(ctx, purpose+":attempts:"+email)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// attemptScript menambah counter percobaan <purpose>:attempts:<id> sebelum
+// kode dibandingkan, dalam satu langkah atomik, supaya request paralel tidak
+// bisa mencoba lebih dari batas. Counter hanya dibuat kalau <purpose>:<id>
+// (kode, challenge, dll.) masih ada, dengan TTL mengikuti sisa umurnya.
+// Hasil 0 = <purpose>:<id> tidak ada; di atas ARGV[1] = dikunci, keduanya
+// dihapus.
+var attemptScript = redis.NewScript(`
+if redis.call("EXISTS", KEYS[1]) == 0 then
+  return 0
+end
+local n = redis.call("INCR", KEYS[2])
+if n == 1 then
+  redis.call("PEXPIRE", KEYS[2], redis.call("PTTL", KEYS[1]))
+end
+if n > tonumber(ARGV[1]) then
+  redis.call("DEL", KEYS[1], KEYS[2])
+end
+return n
+`)
+
+// countAttempt mencatat satu percobaan untuk <purpose>:<id> (lihat
+// attemptScript). Dipakai OTP email, konfirmasi 2FA, dan challenge login 2FA.
+func countAttempt(ctx context.Context, purpose, id string, limit int) (int64, error) {
+	return attemptScript.Run(ctx, database.RDB, []string{purpose + ":" + id, purpose + ":attempts:" + id}, limit).Int64()
+}
+
+// clearAttempts menghapus <purpose>:<id> beserta counter-nya. Jumlah key
+// yang terhapus 0 berarti sudah didahului request lain.
+func clearAttempts(ctx context.Context, purpose, id string) (int64, error) {
+	return database.RDB.Del(ctx, purpose+":"+id, purpose+":attempts:"+id).Result()
+}
+
+// checkOTP membandingkan code dengan kode di <purpose>:<email> (constant
+// time). Semua percobaan dihitung di <purpose>:attempts:<email>; salah tebak
+// ke-MaxOTPAttempts menghapus kode. Kode yang cocok tidak dihapus di sini,
+// itu tugas caller (bersama counter-nya) setelah aksinya berhasil.
+func checkOTP(ctx context.Context, purpose, email, code string) error {
+	attempts, err := countAttempt(ctx, purpose, email, MaxOTPAttempts)
+	if err != nil {
+		return err
+	}
+	switch {
+	case attempts == 0:
+		return errOTPExpired
+	case attempts > MaxOTPAttempts:
+		securityLog(ctx).Warn("otp sudah dikunci, percobaan ditolak", "purpose", purpose, "email", email, "attempts", attempts)
+		return errOTPLocked
+	}
+	stored, err := database.RDB.Get(ctx, purpose+":"+email).Result()
+	if err == redis.Nil {
+		return errOTPExpired
+	}
+	if err != nil {
+		return err
+	}
+	if subtle.ConstantTimeCompare([]byte(stored), []byte(code)) == 1 {
+		return nil
+	}
+
+	if attempts >= MaxOTPAttempts {
+		clearAttempts(ctx, purpose, email)
+		securityLog(ctx).Warn("otp dikunci setelah terlalu banyak percobaan", "purpose", purpose, "email", email, "attempts", attempts)
+		return errOTPLocked
+	}
+	securityLog(ctx).Info("percobaan otp gagal", "purpose", purpose, "email", email, "attempts", attempts)
+	return errOTPInvalid
+}
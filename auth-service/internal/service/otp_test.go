@@ -0,0 +1,72 @@
+package service
+
+import (
+	"auth-service/internal/database"
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// useMiniredis mengganti database.RDB dengan Redis di memori selama test.
+func useMiniredis(t *testing.T) *miniredis.Miniredis {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	old := database.RDB
+	database.RDB = redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() {
+		database.RDB.Close()
+		database.RDB = old
+	})
+	return mr
+}
+
+func TestCheckOTPLockout(t *testing.T) {
+	mr := useMiniredis(t)
+	ctx := context.Background()
+	email := "otp@example.com"
+	require.NoError(t, storeOTP(ctx, "reset", email, "123456", time.Minute))
+
+	for i := 1; i < MaxOTPAttempts; i++ {
+		assert.ErrorIs(t, checkOTP(ctx, "reset", email, "000000"), errOTPInvalid, "percobaan ke-%d", i)
+	}
+	assert.ErrorIs(t, checkOTP(ctx, "reset", email, "000000"), errOTPLocked)
+	assert.False(t, mr.Exists("reset:"+email), "kode harus dihapus setelah dikunci")
+	assert.False(t, mr.Exists("reset:attempts:"+email))
+
+	// Kode yang benar pun sudah tidak berlaku
+	assert.ErrorIs(t, checkOTP(ctx, "reset", email, "123456"), errOTPExpired)
+}
+
+func TestCheckOTPCorrectCode(t *testing.T) {
+	mr := useMiniredis(t)
+	ctx := context.Background()
+	email := "otp@example.com"
+	require.NoError(t, storeOTP(ctx, "verif", email, "123456", time.Minute))
+
+	for i := 1; i < MaxOTPAttempts; i++ {
+		assert.ErrorIs(t, checkOTP(ctx, "verif", email, "000000"), errOTPInvalid)
+	}
+	assert.NoError(t, checkOTP(ctx, "verif", email, "123456"), "percobaan terakhir dalam batas masih boleh")
+	assert.True(t, mr.Exists("verif:"+email), "kode dihapus caller, bukan checkOTP")
+	ttl := mr.TTL("verif:attempts:" + email)
+	assert.True(t, ttl > 0 && ttl <= time.Minute, "TTL counter mengikuti kode, got %v", ttl)
+}
+
+// Request paralel sudah menghabiskan jatah: ditolak sebelum dibandingkan,
+// walau kodenya benar.
+func TestCheckOTPRejectsOverLimitBeforeCompare(t *testing.T) {
+	mr := useMiniredis(t)
+	ctx := context.Background()
+	email := "otp@example.com"
+	require.NoError(t, storeOTP(ctx, "verif", email, "123456", time.Minute))
+	mr.Set("verif:attempts:"+email, strconv.Itoa(MaxOTPAttempts))
+
+	assert.ErrorIs(t, checkOTP(ctx, "verif", email, "123456"), errOTPLocked)
+	assert.False(t, mr.Exists("verif:"+email))
+}
@@ -0,0 +1,110 @@
+package service
+
+import (
+	"auth-service/internal/database"
+	"auth-service/internal/metrics"
+	"auth-service/internal/repository"
+	"auth-service/internal/utils"
+	"context"
+	"errors"
+	"shared/logging"
+	"shared/server"
+	"time"
+)
+
+// ResetCodeTTL: umur kode reset password di Redis (key reset:<email>).
+const ResetCodeTTL = 15 * time.Minute
+
+// ErrResetCodeInvalid: kode reset salah, kadaluarsa, atau sudah dipakai.
+// Sengaja satu error untuk semua kasus supaya tidak membocorkan email mana
+// yang punya kode aktif.
+var ErrResetCodeInvalid = errors.New("kode reset tidak valid atau kadaluarsa")
+
+// 6. FORGOT PASSWORD
+// Selalu sukses dari sisi caller: email yang tidak terdaftar (atau gagal
+// diproses) hanya dicatat di log, supaya endpoint tidak bisa dipakai
+// mengecek email mana yang punya akun. Email dikirim di background, jadi
+// waktu response juga tidak jauh beda.
+func ForgotPassword(ctx context.Context, email string) {
+	ctx, span := tracer.Start(ctx, "service.ForgotPassword")
+	defer span.End()
+	logger := logging.FromContext(ctx)
+
+	if _, err := repository.GetUserByEmail(ctx, email); err != nil {
+		metrics.PasswordResets.WithLabelValues("unknown_email").Inc()
+		return
+	}
+
+	// Permintaan baru menimpa kode lama
+	code := generateOTP()
+	if err := storeOTP(ctx, "reset", email, code, ResetCodeTTL); err != nil {
+		metrics.PasswordResets.WithLabelValues("error").Inc()
+		logger.Error("gagal simpan kode reset password", "error", err)
+		return
+	}
+	metrics.PasswordResets.WithLabelValues("requested").Inc()
+
+	emailCtx := context.WithoutCancel(ctx)
+	server.Go(func() {
+		if err := utils.SendPasswordResetEmail(emailCtx, email, code); err != nil {
+			logger.Error("gagal kirim email reset password", "error", err)
+		}
+	})
+}
+
+// 7. RESET PASSWORD
+// Kode hanya bisa dipakai sekali. Setelah password diganti semua sesi user
+// dicabut: refresh token di DB dan access token lewat denylist.
+func ResetPassword(ctx context.Context, email, code, newPassword string) error {
+	ctx, span := tracer.Start(ctx, "service.ResetPassword")
+	defer span.End()
+
+	err := checkOTP(ctx, "reset", email, code)
+	if errors.Is(err, errOTPExpired) || errors.Is(err, errOTPInvalid) || errors.Is(err, errOTPLocked) {
+		metrics.PasswordResets.WithLabelValues("invalid").Inc()
+		return ErrResetCodeInvalid
+	}
+	if err != nil {
+		metrics.PasswordResets.WithLabelValues("error").Inc()
+		return err
+	}
+	// Del = 0 berarti request lain sudah lebih dulu memakai kode ini
+	if n, err := database.RDB.Del(ctx, "reset:"+email).Result(); err != nil || n == 0 {
+		metrics.PasswordResets.WithLabelValues("invalid").Inc()
+		return ErrResetCodeInvalid
+	}
+	database.RDB.Del(ctx, "reset:attempts:"+email)
+
+	user, err := repository.GetUserByEmail(ctx, email)
+	if err != nil {
+		metrics.PasswordResets.WithLabelValues("error").Inc()
+		return err
+	}
+	hashedPwd, err := utils.HashPassword(newPassword)
+	if err != nil {
+		metrics.PasswordResets.WithLabelValues("error").Inc()
+		return err
+	}
+	if err := repository.UpdateUserPassword(ctx, user.ID, hashedPwd); err != nil {
+		metrics.PasswordResets.WithLabelValues("error").Inc()
+		return err
+	}
+
+	if err := repository.RevokeAllUserTokens(ctx, user.ID); err != nil {
+		metrics.PasswordResets.WithLabelValues("error").Inc()
+		return err
+	}
+	if err := revokeUser(ctx, user.ID); err != nil {
+		metrics.PasswordResets.WithLabelValues("error").Inc()
+		return err
+	}
+	// Akun yang dikunci karena login gagal bisa langsung dipakai lagi
+	if err := resetLoginThrottle(ctx, email); err != nil {
+		metrics.PasswordResets.WithLabelValues("error").Inc()
+		return err
+	}
+
+	metrics.PasswordResets.WithLabelValues("success").Inc()
+	logging.FromContext(ctx).Info("password direset", "user_id", user.ID)
+	return nil
+}
@@ -0,0 +1,57 @@
+package service
+
+import (
+	"auth-service/internal/database"
+	"auth-service/internal/utils"
+	"context"
+	"strconv"
+	"time"
+)
+
+// --- ACCESS TOKEN DENYLIST ---
+// Dibaca oleh API Gateway (AuthMiddleware) sebelum meneruskan request.
+// Format key di Redis:
+//
+//	denylist:jti:<jti>   satu access token dicabut (TTL = sisa umur token)
+//	denylist:sid:<sid>   semua access token dari satu sesi login dicabut
+//	denylist:user:<id>   unix time (milidetik); access token user ini dengan
+//	                     iat_ms < nilai ini dicabut (dipakai saat semua sesi
+//	                     di-revoke)
+//
+// TTL sid & user cukup selama umur access token: setelah itu tidak ada lagi
+// access token lama yang masih valid, dan refresh token-nya sudah di-revoke di DB.
+
+func revokeAccessToken(ctx context.Context, jti string, expiresAt time.Time) error {
+	ttl := time.Until(expiresAt)
+	if jti == "" || ttl <= 0 {
+		return nil
+	}
+	return database.RDB.Set(ctx, "denylist:jti:"+jti, 1, ttl).Err()
+}
+
+func revokeSession(ctx context.Context, sessionID string) error {
+	return database.RDB.Set(ctx, "denylist:sid:"+sessionID, 1, utils.AccessTokenTTL).Err()
+}
+
+func revokeUser(ctx context.Context, userID int64) error {
+	key := "denylist:user:" + strconv.FormatInt(userID, 10)
+	return database.RDB.Set(ctx, key, time.Now().UnixMilli(), utils.AccessTokenTTL).Err()
+}
+
+// AccessTokenRevoked: cek yang sama dengan Denylist di gateway, untuk endpoint
+// auth-service yang menerima access token langsung (misal /auth/api-keys).
+func AccessTokenRevoked(ctx context.Context, claims *utils.AccessClaims) (bool, error) {
+	userKey := "denylist:user:" + strconv.FormatInt(claims.UserID, 10)
+	vals, err := database.RDB.MGet(ctx, "denylist:jti:"+claims.TokenID, "denylist:sid:"+claims.SessionID, userKey).Result()
+	if err != nil {
+		return false, err
+	}
+	if vals[0] != nil || vals[1] != nil {
+		return true, nil
+	}
+	if since, ok := vals[2].(string); ok {
+		revokedAt, _ := strconv.ParseInt(since, 10, 64)
+		return claims.IssuedAt.UnixMilli() < revokedAt, nil
+	}
+	return false, nil
+}
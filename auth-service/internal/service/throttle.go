@@ -0,0 +1,156 @@
+package service
+
+import (
+	"auth-service/internal/database"
+	"auth-service/internal/repository"
+	"auth-service/internal/utils"
+	"context"
+	"fmt"
+	"shared/server"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Throttling login. Gagal login dihitung per email (terdaftar atau tidak,
+// supaya hasilnya tidak membedakan akun yang ada) dan per IP:
+//
+//	login:fail:email:<email>      jumlah gagal dalam LoginFailWindow
+//	login:wait:email:<email>      ada = masih backoff, TTL = sisa tunggu
+//	login:lock:email:<email>      ada = akun dikunci, TTL = sisa kunci
+//	login:lockouts:email:<email>  jumlah kunci dalam 24 jam (lama kunci naik)
+//	login:fail:ip:<ip> / login:wait:ip:<ip>  sama, tanpa lockout
+//
+// Login sukses hanya mereset counter email, bukan IP: kalau IP ikut direset,
+// penyerang cukup punya satu akun valid untuk menghapus jejak tebakannya ke
+// akun lain.
+const (
+	LoginFailWindow    = 15 * time.Minute
+	LoginFreeAttempts  = 3  // per email, sebelum backoff mulai
+	LoginLockThreshold = 10 // per email, dalam LoginFailWindow
+	LoginLockDuration  = 15 * time.Minute
+	LoginMaxLock       = 24 * time.Hour
+	LoginMaxBackoff    = time.Minute
+
+	LoginIPFreeAttempts = 20
+	LoginIPMaxBackoff   = 5 * time.Minute
+)
+
+// LoginThrottledError: login ditolak sebelum password dicek. Locked = akun
+// dikunci (423), selain itu masih backoff (429).
+type LoginThrottledError struct {
+	Locked     bool
+	RetryAfter time.Duration
+}
+
+func (e *LoginThrottledError) Error() string {
+	if e.Locked {
+		return "akun dikunci sementara karena terlalu banyak percobaan login"
+	}
+	return "terlalu banyak percobaan login, coba lagi nanti"
+}
+
+// dummyHash: dipakai CheckPassword untuk email yang tidak terdaftar, supaya
+// waktu response sama dengan akun yang ada (argon2 yang makan waktu).
+var dummyHash = sync.OnceValue(func() string {
+	h, _ := utils.HashPassword("password-dummy-untuk-timing")
+	return h
+})
+
+// throttleKey: email di key dinormalisasi (huruf kecil, tanpa spasi) supaya
+// User@x dan user@x berbagi counter.
+func throttleKey(kind, email string) string {
+	return fmt.Sprintf("login:%s:email:%s", kind, strings.ToLower(strings.TrimSpace(email)))
+}
+
+// checkLoginThrottle: error kalau email sedang dikunci/backoff atau IP sedang
+// backoff. Redis error tidak memblokir login.
+func checkLoginThrottle(ctx context.Context, email, ip string) error {
+	pipe := database.RDB.Pipeline()
+	lock := pipe.PTTL(ctx, throttleKey("lock", email))
+	waitEmail := pipe.PTTL(ctx, throttleKey("wait", email))
+	waitIP := pipe.PTTL(ctx, "login:wait:ip:"+ip)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil
+	}
+	if d := lock.Val(); d > 0 {
+		return &LoginThrottledError{Locked: true, RetryAfter: d}
+	}
+	if d := max(waitEmail.Val(), waitIP.Val()); d > 0 {
+		return &LoginThrottledError{RetryAfter: d}
+	}
+	return nil
+}
+
+// backoff: 1s, 2s, 4s, ... untuk gagal ke-(free+1) dan seterusnya.
+func backoff(fails, free int64, limit time.Duration) time.Duration {
+	if fails <= free {
+		return 0
+	}
+	return min(time.Second<<min(fails-free-1, 20), limit)
+}
+
+// recordLoginFailure menambah counter gagal, memasang backoff, dan mengunci
+// akun kalau sudah LoginLockThreshold kali (hasilnya lama kunci, 0 = tidak
+// dikunci). Pemilik akun dikirimi email saat akun dikunci.
+func recordLoginFailure(ctx context.Context, email, ip string) time.Duration {
+	log := securityLog(ctx)
+	incr := func(key string) int64 {
+		n, err := database.RDB.Incr(ctx, key).Result()
+		if err != nil {
+			log.Error("gagal mencatat login gagal", "key", key, "error", err)
+			return 0
+		}
+		if n == 1 {
+			database.RDB.Expire(ctx, key, LoginFailWindow)
+		}
+		return n
+	}
+
+	ipFails := incr("login:fail:ip:" + ip)
+	if d := backoff(ipFails, LoginIPFreeAttempts, LoginIPMaxBackoff); d > 0 {
+		database.RDB.Set(ctx, "login:wait:ip:"+ip, 1, d)
+	}
+
+	fails := incr(throttleKey("fail", email))
+	log.Info("login gagal", "email", email, "ip", ip, "fails", fails, "ip_fails", ipFails)
+	if fails < LoginLockThreshold {
+		if d := backoff(fails, LoginFreeAttempts, LoginMaxBackoff); d > 0 {
+			database.RDB.Set(ctx, throttleKey("wait", email), 1, d)
+		}
+		return 0
+	}
+
+	// Kunci makin lama tiap kali terulang: 15m, 30m, 1h, ... maks 24 jam
+	lockouts := incr(throttleKey("lockouts", email))
+	database.RDB.Expire(ctx, throttleKey("lockouts", email), LoginMaxLock)
+	lockFor := min(LoginLockDuration<<min(lockouts-1, 10), LoginMaxLock)
+	database.RDB.Set(ctx, throttleKey("lock", email), 1, lockFor)
+	database.RDB.Del(ctx, throttleKey("fail", email), throttleKey("wait", email))
+	log.Warn("akun dikunci karena terlalu banyak login gagal", "email", email, "ip", ip, "lock_for", lockFor.String(), "lockouts", lockouts)
+
+	// Dicari di background supaya waktu response tidak bergantung pada ada
+	// tidaknya akun
+	until := time.Now().Add(lockFor)
+	emailCtx := context.WithoutCancel(ctx)
+	server.Go(func() {
+		user, err := repository.GetUserByEmail(emailCtx, email)
+		if err != nil {
+			return
+		}
+		if err := utils.SendAccountLockedEmail(emailCtx, user.Email, user.Username, until); err != nil {
+			log.Error("gagal kirim email akun dikunci", "user_id", user.ID, "error", err)
+		}
+	})
+	return lockFor
+}
+
+// resetLoginThrottle menghapus semua counter milik email (login sukses atau
+// password direset).
+func resetLoginThrottle(ctx context.Context, email string) error {
+	keys := make([]string, 0, 4)
+	for _, k := range []string{"fail", "wait", "lock", "lockouts"} {
+		keys = append(keys, throttleKey(k, email))
+	}
+	return database.RDB.Del(ctx, keys...).Err()
+}
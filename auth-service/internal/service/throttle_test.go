@@ -0,0 +1,69 @@
+package service
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoginBackoff(t *testing.T) {
+	cases := []struct {
+		fails int64
+		want  time.Duration
+	}{
+		{LoginFreeAttempts, 0},
+		{LoginFreeAttempts + 1, time.Second},
+		{LoginFreeAttempts + 2, 2 * time.Second},
+		{LoginFreeAttempts + 3, 4 * time.Second},
+		{LoginFreeAttempts + 7, time.Minute}, // 64s dipotong LoginMaxBackoff
+		{1000, time.Minute},
+	}
+	for _, tc := range cases {
+		assert.Equal(t, tc.want, backoff(tc.fails, LoginFreeAttempts, LoginMaxBackoff), "gagal ke-%d", tc.fails)
+	}
+}
+
+func TestLoginThrottle(t *testing.T) {
+	mr := useMiniredis(t)
+	ctx := context.Background()
+	email, ip := "User@Example.com", "192.0.2.1"
+
+	for range LoginFreeAttempts {
+		assert.Zero(t, recordLoginFailure(ctx, email, ip))
+		assert.NoError(t, checkLoginThrottle(ctx, email, ip), "belum ada backoff dalam jatah gratis")
+	}
+
+	// Gagal berikutnya: backoff 1s, berlaku juga untuk variasi huruf email
+	recordLoginFailure(ctx, email, ip)
+	var throttled *LoginThrottledError
+	require.ErrorAs(t, checkLoginThrottle(ctx, " user@example.com", ip), &throttled)
+	assert.False(t, throttled.Locked)
+	assert.InDelta(t, time.Second, throttled.RetryAfter, float64(100*time.Millisecond))
+
+	mr.FastForward(time.Second)
+	assert.NoError(t, checkLoginThrottle(ctx, email, ip), "backoff selesai")
+	recordLoginFailure(ctx, email, ip)
+	require.ErrorAs(t, checkLoginThrottle(ctx, email, ip), &throttled)
+	assert.InDelta(t, 2*time.Second, throttled.RetryAfter, float64(100*time.Millisecond), "backoff naik dua kali lipat")
+
+	// Akun dikunci: 423 walau tidak ada backoff
+	mr.Set(throttleKey("lock", email), "1")
+	mr.SetTTL(throttleKey("lock", email), LoginLockDuration)
+	require.ErrorAs(t, checkLoginThrottle(ctx, email, ip), &throttled)
+	assert.True(t, throttled.Locked)
+	assert.Equal(t, LoginLockDuration, throttled.RetryAfter)
+
+	// Login sukses / reset password menghapus semua counter email, tapi
+	// counter IP tetap
+	require.NoError(t, resetLoginThrottle(ctx, email))
+	for _, kind := range []string{"fail", "wait", "lock", "lockouts"} {
+		assert.False(t, mr.Exists(throttleKey(kind, email)), kind)
+	}
+	assert.NoError(t, checkLoginThrottle(ctx, email, ip))
+	n, _ := mr.Get("login:fail:ip:" + ip)
+	assert.Equal(t, strconv.Itoa(LoginFreeAttempts+2), n)
+}
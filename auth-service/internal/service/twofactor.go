@@ -0,0 +1,383 @@
+package service
+
+import (
+	"auth-service/internal/database"
+	"auth-service/internal/metrics"
+	"auth-service/internal/models"
+	"auth-service/internal/repository"
+	"auth-service/internal/utils"
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"shared/logging"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 2FA TOTP. Alur:
+//  1. POST /auth/2fa/enroll   -> (dengan password) secret + URI otpauth://
+//     (belum aktif)
+//  2. POST /auth/2fa/confirm  -> kode pertama dari aplikasi dalam EnrollTTL,
+//     2FA aktif, recovery code ditampilkan sekali
+//  3. Login dengan password menghasilkan challenge token (bukan token);
+//     POST /auth/login/2fa menukar challenge + kode TOTP/recovery code
+//     dengan access & refresh token.
+//
+// Challenge disimpan di Redis (2fa:challenge:<hash token>) selama
+// ChallengeTTL dan hangus setelah MaxChallengeAttempts percobaan, begitu juga
+// enrollment (2fa:enroll:<user id>) setelah MaxOTPAttempts; counter-nya sama
+// dengan OTP email (countAttempt). Kode salah saat login juga dihitung
+// sebagai login gagal, jadi akun tetap bisa terkunci.
+const (
+	TwoFactorIssuer      = "Food App"
+	EnrollTTL            = 15 * time.Minute
+	ChallengeTTL         = 5 * time.Minute
+	MaxChallengeAttempts = 5
+	RecoveryCodeCount    = 10
+)
+
+var (
+	ErrTwoFactorEnabled     = errors.New("2FA sudah aktif")
+	ErrTwoFactorNotEnabled  = errors.New("2FA belum aktif")
+	ErrTwoFactorNotEnrolled = errors.New("belum ada enrollment 2FA, panggil enroll dulu")
+	ErrEnrollmentExpired    = errors.New("enrollment 2FA kadaluarsa atau terlalu banyak kode salah, panggil enroll lagi")
+	ErrTwoFactorCode        = errors.New("kode 2FA salah")
+	ErrChallengeInvalid     = errors.New("sesi login 2FA tidak valid atau kadaluarsa, silakan login ulang")
+	ErrPasswordWrong        = errors.New("password salah")
+	ErrTwoFactorUnavailable = errors.New("2FA sedang tidak tersedia: kunci enkripsi 2FA belum dikonfigurasi di server")
+)
+
+// TwoFactorRequiredError: password benar tapi user memakai 2FA. Login
+// dilanjutkan lewat Login2FA dengan ChallengeToken.
+type TwoFactorRequiredError struct {
+	ChallengeToken string
+}
+
+func (e *TwoFactorRequiredError) Error() string { return "kode 2FA diperlukan" }
+
+// checkCurrentPassword mengecek ulang password user yang sedang login
+// sebelum aksi sensitif. Password salah ikut dihitung throttling login,
+// supaya access token curian tidak bisa dipakai menebak password.
+func checkCurrentPassword(ctx context.Context, userID int64, password, ip, action string) (*models.User, error) {
+	user, err := repository.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	// GetUserByID tidak mengambil password_hash
+	user, err = repository.GetUserByEmail(ctx, user.Email)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkLoginThrottle(ctx, user.Email, ip); err != nil {
+		return nil, err
+	}
+	if !utils.CheckPassword(password, user.PasswordHash) {
+		metrics.TwoFactor.WithLabelValues(action, "invalid").Inc()
+		if lockFor := recordLoginFailure(ctx, user.Email, ip); lockFor > 0 {
+			return nil, &LoginThrottledError{Locked: true, RetryAfter: lockFor}
+		}
+		return nil, ErrPasswordWrong
+	}
+	return user, nil
+}
+
+// EnrollTOTP membuat secret baru (menimpa enrollment yang belum
+// dikonfirmasi). Wajib password, supaya access token curian tidak bisa
+// dipakai memasang 2FA milik penyerang.
+func EnrollTOTP(ctx context.Context, userID int64, password, ip string) (secret, uri string, err error) {
+	ctx, span := tracer.Start(ctx, "service.EnrollTOTP")
+	defer span.End()
+
+	if !utils.TOTPAvailable() {
+		return "", "", ErrTwoFactorUnavailable
+	}
+	user, err := checkCurrentPassword(ctx, userID, password, ip, "enroll")
+	if err != nil {
+		return "", "", err
+	}
+	secret = utils.GenerateTOTPSecret()
+	enc, err := utils.EncryptSecret(secret)
+	if err != nil {
+		return "", "", err
+	}
+	ok, err := repository.SaveTOTPSecret(ctx, userID, enc)
+	if err != nil {
+		metrics.TwoFactor.WithLabelValues("enroll", "error").Inc()
+		return "", "", err
+	}
+	if !ok {
+		return "", "", ErrTwoFactorEnabled
+	}
+	// Penanda enrollment untuk batas waktu & jumlah percobaan confirm
+	if err := storeOTP(ctx, "2fa:enroll", strconv.FormatInt(userID, 10), "1", EnrollTTL); err != nil {
+		metrics.TwoFactor.WithLabelValues("enroll", "error").Inc()
+		return "", "", err
+	}
+	metrics.TwoFactor.WithLabelValues("enroll", "success").Inc()
+	return secret, utils.TOTPURI(TwoFactorIssuer, user.Email, secret), nil
+}
+
+// ConfirmTOTP mengaktifkan 2FA kalau code cocok dengan secret enrollment.
+// Hasilnya recovery code mentah, hanya ditampilkan sekali.
+func ConfirmTOTP(ctx context.Context, userID int64, code string) ([]string, error) {
+	ctx, span := tracer.Start(ctx, "service.ConfirmTOTP")
+	defer span.End()
+
+	if !utils.TOTPAvailable() {
+		return nil, ErrTwoFactorUnavailable
+	}
+	totp, err := repository.GetTOTP(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if totp == nil {
+		return nil, ErrTwoFactorNotEnrolled
+	}
+	if totp.EnabledAt != nil {
+		return nil, ErrTwoFactorEnabled
+	}
+
+	id := strconv.FormatInt(userID, 10)
+	attempts, err := countAttempt(ctx, "2fa:enroll", id, MaxOTPAttempts)
+	if err != nil {
+		return nil, err
+	}
+	if attempts == 0 || attempts > MaxOTPAttempts {
+		metrics.TwoFactor.WithLabelValues("confirm", "expired").Inc()
+		return nil, ErrEnrollmentExpired
+	}
+	if err := checkTOTP(ctx, totp, code); err != nil {
+		if !errors.Is(err, ErrTwoFactorCode) {
+			return nil, err
+		}
+		metrics.TwoFactor.WithLabelValues("confirm", "invalid").Inc()
+		if attempts >= MaxOTPAttempts {
+			clearAttempts(ctx, "2fa:enroll", id)
+			securityLog(ctx).Warn("enrollment 2FA hangus setelah terlalu banyak percobaan", "user_id", userID)
+			return nil, ErrEnrollmentExpired
+		}
+		return nil, err
+	}
+
+	codes, hashes := generateRecoveryCodes()
+	if err := repository.EnableTOTP(ctx, userID, hashes); err != nil {
+		metrics.TwoFactor.WithLabelValues("confirm", "error").Inc()
+		return nil, err
+	}
+	clearAttempts(ctx, "2fa:enroll", id)
+	metrics.TwoFactor.WithLabelValues("confirm", "success").Inc()
+	securityLog(ctx).Info("2FA diaktifkan", "user_id", userID)
+	return codes, nil
+}
+
+// DisableTOTP mematikan 2FA setelah password dicek ulang.
+func DisableTOTP(ctx context.Context, userID int64, password, ip string) error {
+	ctx, span := tracer.Start(ctx, "service.DisableTOTP")
+	defer span.End()
+
+	if _, err := checkCurrentPassword(ctx, userID, password, ip, "disable"); err != nil {
+		return err
+	}
+
+	totp, err := repository.GetTOTP(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if totp == nil || totp.EnabledAt == nil {
+		return ErrTwoFactorNotEnabled
+	}
+	if err := repository.DisableTOTP(ctx, userID); err != nil {
+		metrics.TwoFactor.WithLabelValues("disable", "error").Inc()
+		return err
+	}
+	metrics.TwoFactor.WithLabelValues("disable", "success").Inc()
+	securityLog(ctx).Warn("2FA dimatikan", "user_id", userID, "ip", ip)
+	return nil
+}
+
+// startTwoFactor dipanggil Login setelah password benar: challenge token
+// kalau 2FA user aktif, "" kalau tidak.
+func startTwoFactor(ctx context.Context, userID int64, deviceID string) (string, error) {
+	totp, err := repository.GetTOTP(ctx, userID)
+	if err != nil || totp == nil || totp.EnabledAt == nil {
+		return "", err
+	}
+
+	challenge := randomCode(32)
+	key := "2fa:challenge:" + utils.HashToken(challenge)
+	pipe := database.RDB.TxPipeline()
+	pipe.HSet(ctx, key, "user_id", userID, "device_id", deviceID)
+	pipe.Expire(ctx, key, ChallengeTTL)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return "", err
+	}
+	return challenge, nil
+}
+
+// 3b. LOGIN 2FA
+// code: kode TOTP 6 digit atau recovery code. Kode salah ikut dihitung ke
+// throttling login user (lihat throttle.go), jadi menebak kode lewat banyak
+// challenge tetap berujung akun dikunci. Counter baru direset setelah kode
+// benar.
+func Login2FA(ctx context.Context, challenge, code, ip string) (string, string, error) {
+	ctx, span := tracer.Start(ctx, "service.Login2FA")
+	defer span.End()
+
+	hash := utils.HashToken(challenge)
+	key := "2fa:challenge:" + hash
+	vals, err := database.RDB.HGetAll(ctx, key).Result()
+	if err != nil {
+		return "", "", err
+	}
+	userID, _ := strconv.ParseInt(vals["user_id"], 10, 64)
+	if userID == 0 {
+		metrics.TwoFactor.WithLabelValues("login", "expired").Inc()
+		return "", "", ErrChallengeInvalid
+	}
+
+	user, err := repository.GetUserByID(ctx, userID)
+	if err != nil {
+		return "", "", err
+	}
+	if err := checkLoginThrottle(ctx, user.Email, ip); err != nil {
+		metrics.TwoFactor.WithLabelValues("login", "throttled").Inc()
+		return "", "", err
+	}
+
+	totp, err := repository.GetTOTP(ctx, userID)
+	if err != nil {
+		return "", "", err
+	}
+	if totp == nil || totp.EnabledAt == nil {
+		// 2FA dimatikan di tengah login
+		clearAttempts(ctx, "2fa:challenge", hash)
+		return "", "", ErrChallengeInvalid
+	}
+
+	// Tanpa kunci enkripsi kode TOTP tidak bisa dicek; recovery code tetap
+	// bisa dipakai
+	if isTOTPCode(code) && !utils.TOTPAvailable() {
+		metrics.TwoFactor.WithLabelValues("login", "unavailable").Inc()
+		return "", "", ErrTwoFactorUnavailable
+	}
+	attempts, err := countAttempt(ctx, "2fa:challenge", hash, MaxChallengeAttempts)
+	if err != nil {
+		return "", "", err
+	}
+	if attempts == 0 || attempts > MaxChallengeAttempts {
+		metrics.TwoFactor.WithLabelValues("login", "expired").Inc()
+		return "", "", ErrChallengeInvalid
+	}
+
+	result := "success"
+	if isTOTPCode(code) {
+		err = checkTOTP(ctx, totp, code)
+	} else {
+		result = "recovery"
+		err = useRecoveryCode(ctx, userID, code)
+	}
+	if errors.Is(err, ErrTwoFactorCode) {
+		metrics.TwoFactor.WithLabelValues("login", "invalid").Inc()
+		securityLog(ctx).Info("kode 2FA salah", "user_id", userID, "attempts", attempts)
+		if lockFor := recordLoginFailure(ctx, user.Email, ip); lockFor > 0 {
+			clearAttempts(ctx, "2fa:challenge", hash)
+			return "", "", &LoginThrottledError{Locked: true, RetryAfter: lockFor}
+		}
+		if attempts >= MaxChallengeAttempts {
+			clearAttempts(ctx, "2fa:challenge", hash)
+			securityLog(ctx).Warn("challenge 2FA hangus setelah terlalu banyak percobaan", "user_id", userID)
+			return "", "", ErrChallengeInvalid
+		}
+		return "", "", ErrTwoFactorCode
+	}
+	if err != nil {
+		metrics.TwoFactor.WithLabelValues("login", "error").Inc()
+		return "", "", err
+	}
+
+	// Challenge hanya bisa ditukar sekali
+	if n, err := clearAttempts(ctx, "2fa:challenge", hash); err != nil || n == 0 {
+		return "", "", ErrChallengeInvalid
+	}
+	at, rt, err := issueTokens(ctx, user, vals["device_id"])
+	if err != nil {
+		metrics.TwoFactor.WithLabelValues("login", "error").Inc()
+		return "", "", err
+	}
+	if err := resetLoginThrottle(ctx, user.Email); err != nil {
+		logging.FromContext(ctx).Error("gagal reset counter login", "error", err)
+	}
+	metrics.TwoFactor.WithLabelValues("login", result).Inc()
+	if result == "recovery" {
+		securityLog(ctx).Warn("login memakai recovery code", "user_id", userID)
+	}
+	return at, rt, nil
+}
+
+// checkTOTP mengecek kode TOTP sekaligus mencatat step-nya, supaya kode yang
+// sama tidak bisa dipakai dua kali.
+func checkTOTP(ctx context.Context, totp *models.TOTP, code string) error {
+	secret, err := utils.DecryptSecret(totp.SecretEnc)
+	if err != nil {
+		return err
+	}
+	step, ok := utils.VerifyTOTP(secret, code, time.Now())
+	if !ok {
+		return ErrTwoFactorCode
+	}
+	fresh, err := repository.UseTOTPStep(ctx, totp.UserID, step)
+	if err != nil {
+		return err
+	}
+	if !fresh {
+		return ErrTwoFactorCode
+	}
+	return nil
+}
+
+func useRecoveryCode(ctx context.Context, userID int64, code string) error {
+	ok, err := repository.UseRecoveryCode(ctx, userID, utils.HashToken(normalizeRecoveryCode(code)))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrTwoFactorCode
+	}
+	return nil
+}
+
+func isTOTPCode(code string) bool {
+	if len(code) != utils.TOTPDigits {
+		return false
+	}
+	for _, c := range code {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// generateRecoveryCodes: code mentah (format xxxxx-xxxxx) dan hash-nya.
+func generateRecoveryCodes() (codes, hashes []string) {
+	for range RecoveryCodeCount {
+		c := strings.ToLower(randomCode(10))
+		codes = append(codes, c[:5]+"-"+c[5:])
+		hashes = append(hashes, utils.HashToken(c))
+	}
+	return codes, hashes
+}
+
+// normalizeRecoveryCode: huruf besar/kecil, spasi & strip diabaikan.
+func normalizeRecoveryCode(code string) string {
+	return strings.NewReplacer("-", "", " ", "").Replace(strings.ToLower(code))
+}
+
+// randomCode: n karakter base32 acak (5 bit per karakter).
+func randomCode(n int) string {
+	b := make([]byte, (n*5+7)/8)
+	rand.Read(b)
+	return base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b)[:n]
+}
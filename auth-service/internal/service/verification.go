@@ -0,0 +1,101 @@
+package service
+
+import (
+	"auth-service/internal/database"
+	"auth-service/internal/metrics"
+	"auth-service/internal/repository"
+	"auth-service/internal/utils"
+	"context"
+	"errors"
+	"shared/logging"
+	"shared/server"
+	"time"
+)
+
+// Batas kirim ulang kode verifikasi. Counter harian berjalan 24 jam sejak
+// kiriman pertama (bukan per tanggal).
+const (
+	VerificationTTL    = 15 * time.Minute
+	ResendCooldown     = time.Minute
+	ResendDailyPerMail = 5
+	ResendDailyPerIP   = 20
+)
+
+// ErrResendLimited: masih cooldown atau kuota harian habis.
+var ErrResendLimited = errors.New("terlalu sering meminta kode verifikasi, coba lagi nanti")
+
+// 2b. RESEND VERIFICATION
+// Mengembalikan lama tunggu sampai kiriman berikutnya diizinkan. Batas
+// dihitung untuk semua email (terdaftar atau tidak) dan hasilnya sama, supaya
+// endpoint tidak bisa dipakai mengecek email mana yang punya akun; kode hanya
+// benar-benar dikirim ke akun yang belum terverifikasi.
+func ResendVerification(ctx context.Context, email, ip string) (time.Duration, error) {
+	ctx, span := tracer.Start(ctx, "service.ResendVerification")
+	defer span.End()
+
+	cooldownKey := "verif:cooldown:" + email
+	ok, err := database.RDB.SetNX(ctx, cooldownKey, 1, ResendCooldown).Result()
+	if err != nil {
+		metrics.VerificationResends.WithLabelValues("error").Inc()
+		return 0, err
+	}
+	if !ok {
+		metrics.VerificationResends.WithLabelValues("cooldown").Inc()
+		return database.RDB.TTL(ctx, cooldownKey).Val(), ErrResendLimited
+	}
+
+	// Request yang ditolak ikut dihitung; tidak masalah karena counter sudah
+	// di atas batas dan TTL-nya tidak diperpanjang.
+	mailKey, ipKey := "verif:daily:email:"+email, "verif:daily:ip:"+ip
+	counts := make([]int64, 2)
+	ttls := make([]time.Duration, 2)
+	for i, key := range []string{mailKey, ipKey} {
+		n, err := database.RDB.Incr(ctx, key).Result()
+		if err != nil {
+			metrics.VerificationResends.WithLabelValues("error").Inc()
+			return 0, err
+		}
+		if n == 1 {
+			database.RDB.Expire(ctx, key, 24*time.Hour)
+		}
+		counts[i], ttls[i] = n, database.RDB.TTL(ctx, key).Val()
+	}
+
+	var wait time.Duration
+	if counts[0] > ResendDailyPerMail {
+		wait = ttls[0]
+	}
+	if counts[1] > ResendDailyPerIP {
+		wait = max(wait, ttls[1])
+	}
+	if wait > 0 {
+		metrics.VerificationResends.WithLabelValues("daily_limit").Inc()
+		logging.FromContext(ctx).Warn("kuota kirim ulang verifikasi habis", "ip", ip,
+			"email_count", counts[0], "ip_count", counts[1])
+		database.RDB.Expire(ctx, cooldownKey, wait)
+		return wait, ErrResendLimited
+	}
+
+	user, err := repository.GetUserByEmail(ctx, email)
+	if err != nil || user.IsVerified {
+		metrics.VerificationResends.WithLabelValues("skipped").Inc()
+		return ResendCooldown, nil
+	}
+
+	// Kode baru menggantikan kode lama
+	otp := generateOTP()
+	if err := storeOTP(ctx, "verif", email, otp, VerificationTTL); err != nil {
+		metrics.VerificationResends.WithLabelValues("error").Inc()
+		return 0, err
+	}
+	metrics.VerificationResends.WithLabelValues("sent").Inc()
+
+	emailCtx := context.WithoutCancel(ctx)
+	logger := logging.FromContext(ctx)
+	server.Go(func() {
+		if err := utils.SendVerificationEmail(emailCtx, email, otp); err != nil {
+			logger.Error("gagal kirim ulang email verifikasi", "error", err)
+		}
+	})
+	return ResendCooldown, nil
+}
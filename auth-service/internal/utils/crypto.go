@@ -7,7 +7,6 @@ import (
 	"encoding/base64" // -> integer 
 	"encoding/hex" // -> hexadecimal
 	"fmt"
-	"os"
 	"strings"
 	"time"
 
@@ -81,15 +80,76 @@ func CheckPassword(password, encodedHash string) bool {
 
 // --- JWT & TOKEN UTILS ---
 
-func GenerateAccessToken(userID int64, username string) (string, error) {
+const AccessTokenTTL = 15 * time.Minute
+
+// AccessClaims: isi access token yang dipakai auth-service sendiri
+// (logout, revocation).
+type AccessClaims struct {
+	UserID    int64
+	Username  string
+	SessionID string
+	TokenID   string // claim jti
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+}
+
+// GenerateAccessToken: roles (tabel user_roles) dipakai gateway untuk RBAC.
+func GenerateAccessToken(userID int64, username, sessionID string, roles []string) (string, error) {
+	if roles == nil {
+		roles = []string{}
+	}
+	// iat_ms: waktu terbit dalam milidetik untuk denylist:user, supaya token
+	// yang terbit sesaat setelah revokeUser tidak ikut dicabut. iat tetap
+	// detik bulat sesuai RFC 7519.
+	now := time.Now()
 	claims := jwt.MapClaims{
-		"sub":  userID,
-		"name": username,
-		"iat":  time.Now().Unix(),
-		"exp":  time.Now().Add(15 * time.Minute).Unix(),
+		"sub":    userID,
+		"name":   username,
+		"sid":    sessionID,
+		"roles":  roles,
+		"jti":    uuid.New().String(),
+		"iat":    now.Unix(),
+		"iat_ms": now.UnixMilli(),
+		"exp":    now.Add(AccessTokenTTL).Unix(),
+	}
+	key := Keys.Active()
+	token := jwt.NewWithClaims(key.Method, claims)
+	token.Header["kid"] = key.KID
+	return token.SignedString(key.Private)
+}
+
+// ParseAccessToken memverifikasi access token dengan signing key milik
+// auth-service (termasuk kunci lama yang masih ada di JWKS).
+func ParseAccessToken(tokenString string) (*AccessClaims, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		key := Keys.Lookup(kid)
+		if key == nil {
+			return nil, fmt.Errorf("kid %q tidak dikenal", kid)
+		}
+		if token.Method.Alg() != key.Method.Alg() {
+			return nil, fmt.Errorf("alg %s tidak cocok dengan kunci %s", token.Method.Alg(), kid)
+		}
+		return key.Private.Public(), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg(), jwt.SigningMethodEdDSA.Alg()}))
+	if err != nil {
+		return nil, err
+	}
+
+	sub, _ := claims["sub"].(float64)
+	exp, _ := claims.GetExpirationTime()
+	out := &AccessClaims{UserID: int64(sub)}
+	out.Username, _ = claims["name"].(string)
+	out.SessionID, _ = claims["sid"].(string)
+	out.TokenID, _ = claims["jti"].(string)
+	if exp != nil {
+		out.ExpiresAt = exp.Time
+	}
+	if ms, ok := claims["iat_ms"].(float64); ok {
+		out.IssuedAt = time.UnixMilli(int64(ms))
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(os.Getenv("JWT_SECRET")))
+	return out, nil
 }
 
 func GenerateRefreshToken() string {
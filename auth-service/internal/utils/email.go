@@ -1,12 +1,32 @@
 package utils
 
 import (
+	"context"
 	"fmt"
 	"net/smtp"
 	"os"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
 )
 
-func SendVerificationEmail(toEmail, code string) error {
+// sendMail: smtp.SendMail dibungkus span supaya waktu kirim email kelihatan di trace.
+func sendMail(ctx context.Context, addr string, auth smtp.Auth, from string, to []string, msg []byte) error {
+	_, span := otel.Tracer("auth-service/internal/utils").Start(ctx, "smtp.SendMail")
+	defer span.End()
+	span.SetAttributes(attribute.String("server.address", addr))
+
+	err := smtp.SendMail(addr, auth, from, to, msg)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return err
+}
+
+func SendVerificationEmail(ctx context.Context, toEmail, code string) error {
 	host := os.Getenv("SMTP_HOST")
 	port := os.Getenv("SMTP_PORT")
 	user := os.Getenv("SMTP_USER")
@@ -39,11 +59,84 @@ func SendVerificationEmail(toEmail, code string) error {
 	addr := fmt.Sprintf("%s:%s", host, port)
 	auth := smtp.PlainAuth("", user, password, host)
 
-	return smtp.SendMail(addr, auth, user, []string{toEmail}, msg)
+	return sendMail(ctx, addr, auth, user, []string{toEmail}, msg)
+}
+
+
+func SendPasswordResetEmail(ctx context.Context, toEmail, code string) error {
+	host := os.Getenv("SMTP_HOST")
+	port := os.Getenv("SMTP_PORT")
+	user := os.Getenv("SMTP_USER")
+	password := os.Getenv("SMTP_PASS")
+
+	senderName := os.Getenv("SMTP_SENDER_NAME")
+	if senderName == "" {
+		senderName = user
+	}
+
+	subject := "Subject: Reset Password Food App\n"
+	fromHeader := fmt.Sprintf("From: %s\n", senderName)
+	toHeader := fmt.Sprintf("To: %s\n", toEmail)
+	mime := "MIME-version: 1.0;\nContent-Type: text/html; charset=\"UTF-8\";\n\n"
+
+	body := fmt.Sprintf(`
+		<html>
+			<body style="font-family: Arial, sans-serif; padding: 20px;">
+				<div style="background-color: #f4f4f4; padding: 20px; border-radius: 8px;">
+					<h2 style="color: #333;">Reset Password</h2>
+					<p>Ada permintaan reset password untuk akun Anda. Gunakan kode berikut:</p>
+					<h1 style="color: #0070f3; background: #fff; padding: 10px; display: inline-block;">%s</h1>
+					<p>Kode berlaku selama 15 menit dan hanya bisa dipakai sekali.</p>
+					<p>Abaikan email ini kalau Anda tidak meminta reset password.</p>
+				</div>
+			</body>
+		</html>
+	`, code)
+
+	msg := []byte(subject + fromHeader + toHeader + mime + body)
+	addr := fmt.Sprintf("%s:%s", host, port)
+	auth := smtp.PlainAuth("", user, password, host)
+
+	return sendMail(ctx, addr, auth, user, []string{toEmail}, msg)
 }
 
+func SendAccountLockedEmail(ctx context.Context, toEmail, username string, until time.Time) error {
+	host := os.Getenv("SMTP_HOST")
+	port := os.Getenv("SMTP_PORT")
+	user := os.Getenv("SMTP_USER")
+	password := os.Getenv("SMTP_PASS")
+
+	senderName := os.Getenv("SMTP_SENDER_NAME")
+	if senderName == "" {
+		senderName = user
+	}
+
+	subject := "Subject: Akun Food App Dikunci Sementara\n"
+	fromHeader := fmt.Sprintf("From: %s\n", senderName)
+	toHeader := fmt.Sprintf("To: %s\n", toEmail)
+	mime := "MIME-version: 1.0;\nContent-Type: text/html; charset=\"UTF-8\";\n\n"
+
+	body := fmt.Sprintf(`
+		<html>
+			<body style="font-family: Arial, sans-serif; padding: 20px;">
+				<div style="background-color: #f4f4f4; padding: 20px; border-radius: 8px;">
+					<h2 style="color: #c0392b;">Akun Dikunci Sementara</h2>
+					<p>Halo <strong>%s</strong>,</p>
+					<p>Ada terlalu banyak percobaan login dengan password salah ke akun Anda, jadi akun dikunci sampai <strong>%s</strong>.</p>
+					<p>Kalau itu bukan Anda, segera reset password lewat menu Lupa Password. Reset password juga langsung membuka kunci akun.</p>
+				</div>
+			</body>
+		</html>
+	`, username, until.UTC().Format("02 Jan 2006 15:04 MST"))
+
+	msg := []byte(subject + fromHeader + toHeader + mime + body)
+	addr := fmt.Sprintf("%s:%s", host, port)
+	auth := smtp.PlainAuth("", user, password, host)
+
+	return sendMail(ctx, addr, auth, user, []string{toEmail}, msg)
+}
 
-func SendReceiptEmail(toEmail, username, orderID string, amount float64, itemName string) error {
+func SendReceiptEmail(ctx context.Context, toEmail, username, orderID string, amount float64, itemName string) error {
 	host := os.Getenv("SMTP_HOST")
 	port := os.Getenv("SMTP_PORT")
 	user := os.Getenv("SMTP_USER")
@@ -95,5 +188,5 @@ func SendReceiptEmail(toEmail, username, orderID string, amount float64, itemNam
 	addr := fmt.Sprintf("%s:%s", host, port)
 	auth := smtp.PlainAuth("", user, password, host)
 
-	return smtp.SendMail(addr, auth, user, []string{toEmail}, msg)
+	return sendMail(ctx, addr, auth, user, []string{toEmail}, msg)
 }
\ No newline at end of file
@@ -0,0 +1,227 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"os"
+	"path/filepath"
+	"shared/logging"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// --- SIGNING KEYS (RS256 / EdDSA) ---
+//
+// Kunci privat disimpan di JWT_KEYS_DIR, satu file PEM (PKCS#8) per kunci,
+// nama file = kid. Contoh: keys/2026-10-17.pem -> kid "2026-10-17".
+// Kunci aktif (dipakai menandatangani) = isi file ACTIVE di folder tersebut,
+// atau kid terakhir secara urutan nama kalau file ACTIVE tidak ada.
+//
+// Rotasi tanpa downtime:
+//  1. Tambah file kunci baru + tulis kid lama ke ACTIVE (kunci baru ikut
+//     terpublish di JWKS, tapi belum dipakai tanda tangan).
+//  2. Tunggu cache JWKS di gateway kedaluwarsa, lalu ganti ACTIVE ke kid baru.
+//  3. Setelah access token lama habis masa berlakunya (15 menit), hapus file
+//     kunci lama.
+// Folder dibaca ulang tiap menit, jadi tidak perlu restart.
+
+type SigningKey struct {
+	KID     string
+	Method  jwt.SigningMethod
+	Private crypto.Signer
+}
+
+type keyRing struct {
+	mu     sync.RWMutex
+	keys   map[string]*SigningKey
+	active string
+}
+
+var Keys = &keyRing{}
+
+// InitKeys memuat kunci dari JWT_KEYS_DIR. Kalau env tidak diisi, dibuat satu
+// kunci Ed25519 sementara di memori (khusus development: token tidak valid
+// lagi setelah restart).
+func InitKeys() {
+	dir := os.Getenv("JWT_KEYS_DIR")
+	if dir == "" {
+		key, err := generateDevKey()
+		if err != nil {
+			logging.Fatal("gagal membuat signing key", "error", err)
+		}
+		Keys.set(map[string]*SigningKey{key.KID: key}, key.KID)
+		slog.Warn("JWT_KEYS_DIR kosong, pakai signing key sementara", "kid", key.KID)
+		return
+	}
+
+	if err := ReloadKeys(); err != nil {
+		logging.Fatal("gagal load signing key", "error", err)
+	}
+	slog.Info("signing key aktif", "kid", Keys.Active().KID, "keys", len(Keys.keys))
+
+	go func() {
+		for range time.Tick(time.Minute) {
+			if err := ReloadKeys(); err != nil {
+				slog.Warn("reload signing key gagal, tetap pakai kunci lama", "error", err)
+			}
+		}
+	}()
+}
+
+// ReloadKeys membaca ulang isi JWT_KEYS_DIR.
+func ReloadKeys() error {
+	dir := os.Getenv("JWT_KEYS_DIR")
+	files, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("tidak ada file *.pem di %s", dir)
+	}
+	sort.Strings(files)
+
+	keys := make(map[string]*SigningKey, len(files))
+	var last string
+	for _, f := range files {
+		kid := strings.TrimSuffix(filepath.Base(f), ".pem")
+		raw, err := os.ReadFile(f)
+		if err != nil {
+			return err
+		}
+		key, err := ParseSigningKey(kid, raw)
+		if err != nil {
+			return fmt.Errorf("%s: %w", f, err)
+		}
+		keys[kid] = key
+		last = kid
+	}
+
+	active := last
+	if raw, err := os.ReadFile(filepath.Join(dir, "ACTIVE")); err == nil {
+		active = strings.TrimSpace(string(raw))
+		if keys[active] == nil {
+			return fmt.Errorf("kid aktif %q tidak ada di %s", active, dir)
+		}
+	}
+
+	Keys.set(keys, active)
+	return nil
+}
+
+// ParseSigningKey membaca private key PEM (PKCS#8, atau PKCS#1 untuk RSA).
+func ParseSigningKey(kid string, pemBytes []byte) (*SigningKey, error) {
+	block, _ := pem.Decode(pemBytes)
+	if block == nil {
+		return nil, errors.New("bukan file PEM")
+	}
+
+	var parsed any
+	var err error
+	if block.Type == "RSA PRIVATE KEY" {
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	} else {
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		if k.N.BitLen() < 2048 {
+			return nil, errors.New("kunci RSA minimal 2048 bit")
+		}
+		return &SigningKey{KID: kid, Method: jwt.SigningMethodRS256, Private: k}, nil
+	case ed25519.PrivateKey:
+		return &SigningKey{KID: kid, Method: jwt.SigningMethodEdDSA, Private: k}, nil
+	default:
+		return nil, fmt.Errorf("tipe kunci %T tidak didukung (pakai RSA atau Ed25519)", parsed)
+	}
+}
+
+func generateDevKey() (*SigningKey, error) {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	kid := "dev-" + hex.EncodeToString(suffix)
+	return &SigningKey{KID: kid, Method: jwt.SigningMethodEdDSA, Private: priv}, nil
+}
+
+func (kr *keyRing) set(keys map[string]*SigningKey, active string) {
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+	kr.keys = keys
+	kr.active = active
+}
+
+// Active: kunci yang dipakai untuk menandatangani token baru.
+func (kr *keyRing) Active() *SigningKey {
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+	return kr.keys[kr.active]
+}
+
+// Lookup: kunci berdasarkan kid, untuk verifikasi token.
+func (kr *keyRing) Lookup(kid string) *SigningKey {
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+	return kr.keys[kid]
+}
+
+// --- JWKS ---
+
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Crv string `json:"crv,omitempty"` // OKP
+	X   string `json:"x,omitempty"`   // OKP
+	N   string `json:"n,omitempty"`   // RSA
+	E   string `json:"e,omitempty"`   // RSA
+}
+
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS: public key semua kunci (aktif maupun lama) dalam format RFC 7517.
+func (kr *keyRing) JWKS() JWKSet {
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+
+	set := JWKSet{Keys: []JWK{}}
+	for kid, key := range kr.keys {
+		jwk := JWK{Kid: kid, Use: "sig", Alg: key.Method.Alg()}
+		switch pub := key.Private.Public().(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	sort.Slice(set.Keys, func(i, j int) bool { return set.Keys[i].Kid < set.Keys[j].Kid })
+	return set
+}
@@ -0,0 +1,160 @@
+package utils
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"shared/logging"
+	"strings"
+	"time"
+)
+
+// --- TOTP (RFC 6238) ---
+// SHA-1, 6 digit, periode 30 detik: default yang didukung semua aplikasi
+// authenticator (Google Authenticator, Authy, 1Password, ...).
+
+const (
+	TOTPPeriod = 30
+	TOTPDigits = 6
+	TOTPSkew   = 1 // toleransi jam: kode 1 step sebelum/sesudah masih diterima
+)
+
+var b32 = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateTOTPSecret: 160 bit acak, base32 tanpa padding (format otpauth).
+func GenerateTOTPSecret() string {
+	b := make([]byte, 20)
+	rand.Read(b)
+	return b32.EncodeToString(b)
+}
+
+// TOTPURI: URI otpauth:// untuk QR code di aplikasi authenticator.
+func TOTPURI(issuer, account, secret string) string {
+	q := url.Values{}
+	q.Set("secret", secret)
+	q.Set("issuer", issuer)
+	q.Set("algorithm", "SHA1")
+	q.Set("digits", fmt.Sprint(TOTPDigits))
+	q.Set("period", fmt.Sprint(TOTPPeriod))
+	label := url.PathEscape(issuer) + ":" + url.PathEscape(account)
+	return "otpauth://totp/" + label + "?" + q.Encode()
+}
+
+// TOTPCode: kode untuk time step tertentu (unix time / TOTPPeriod).
+func TOTPCode(secret string, step int64) (string, error) {
+	key, err := b32.DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return "", err
+	}
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(step))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	// Dynamic truncation (RFC 4226 5.3)
+	off := sum[len(sum)-1] & 0x0f
+	bin := binary.BigEndian.Uint32(sum[off:]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", TOTPDigits, bin%1_000_000), nil
+}
+
+// VerifyTOTP mengecek code terhadap step sekarang ± TOTPSkew. Hasilnya step
+// yang cocok, dipakai caller untuk menolak kode yang sama dipakai ulang.
+func VerifyTOTP(secret, code string, now time.Time) (int64, bool) {
+	current := now.Unix() / TOTPPeriod
+	for step := current - TOTPSkew; step <= current+TOTPSkew; step++ {
+		want, err := TOTPCode(secret, step)
+		if err != nil {
+			return 0, false
+		}
+		if subtle.ConstantTimeCompare([]byte(want), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// --- ENKRIPSI SECRET ---
+// Secret TOTP disimpan terenkripsi AES-256-GCM. Kunci dari TOTP_ENCRYPTION_KEY
+// (base64, 32 byte). Ganti kunci = semua user harus enroll 2FA ulang.
+
+var secretKey cipher.AEAD
+
+// ErrTOTPKeyMissing: TOTP_ENCRYPTION_KEY tidak diisi, 2FA tidak tersedia.
+var ErrTOTPKeyMissing = errors.New("kunci enkripsi 2FA belum dimuat")
+
+// InitSecretKey memuat TOTP_ENCRYPTION_KEY. Kalau kosong service tetap jalan
+// tapi 2FA tidak tersedia (TOTPAvailable false); kunci acak tidak dipakai
+// karena membuat user ber-2FA terkunci setelah restart. Khusus development
+// bisa di-opt-in dengan TOTP_EPHEMERAL_KEY=true (kunci sementara di memori).
+// Kunci yang diisi tapi tidak valid tetap membuat service berhenti.
+func InitSecretKey() {
+	secretKey = nil
+	raw := os.Getenv("TOTP_ENCRYPTION_KEY")
+	var key []byte
+	if raw == "" {
+		if os.Getenv("TOTP_EPHEMERAL_KEY") != "true" {
+			slog.Warn("TOTP_ENCRYPTION_KEY kosong, 2FA tidak tersedia (TOTP_EPHEMERAL_KEY=true untuk development)")
+			return
+		}
+		key = make([]byte, 32)
+		rand.Read(key)
+		slog.Warn("TOTP_EPHEMERAL_KEY=true, pakai kunci enkripsi 2FA sementara")
+	} else {
+		var err error
+		if key, err = base64.StdEncoding.DecodeString(raw); err != nil || len(key) != 32 {
+			logging.Fatal("TOTP_ENCRYPTION_KEY harus base64 dari 32 byte")
+		}
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		logging.Fatal("gagal membuat cipher 2FA", "error", err)
+	}
+	if secretKey, err = cipher.NewGCM(block); err != nil {
+		logging.Fatal("gagal membuat cipher 2FA", "error", err)
+	}
+}
+
+// TOTPAvailable: false kalau kunci enkripsi 2FA tidak ada.
+func TOTPAvailable() bool {
+	return secretKey != nil
+}
+
+// EncryptSecret: base64(nonce || ciphertext).
+func EncryptSecret(plain string) (string, error) {
+	if secretKey == nil {
+		return "", ErrTOTPKeyMissing
+	}
+	nonce := make([]byte, secretKey.NonceSize())
+	rand.Read(nonce)
+	sealed := secretKey.Seal(nonce, nonce, []byte(plain), nil)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func DecryptSecret(enc string) (string, error) {
+	if secretKey == nil {
+		return "", ErrTOTPKeyMissing
+	}
+	sealed, err := base64.StdEncoding.DecodeString(enc)
+	if err != nil || len(sealed) < secretKey.NonceSize() {
+		return "", errors.New("secret 2FA rusak")
+	}
+	n := secretKey.NonceSize()
+	plain, err := secretKey.Open(nil, sealed[:n], sealed[n:], nil)
+	if err != nil {
+		return "", err
+	}
+	return string(plain), nil
+}
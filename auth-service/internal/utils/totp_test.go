@@ -0,0 +1,77 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test vector RFC 6238 (SHA-1, secret "12345678901234567890"), diambil 6
+// digit terakhir dari kode 8 digit di RFC.
+func TestTOTPVectors(t *testing.T) {
+	secret := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+	vectors := map[int64]string{
+		59:          "287082",
+		1111111109:  "081804",
+		1111111111:  "050471",
+		1234567890:  "005924",
+		2000000000:  "279037",
+		20000000000: "353130",
+	}
+	for unix, want := range vectors {
+		got, err := TOTPCode(secret, unix/TOTPPeriod)
+		assert.NoError(t, err)
+		assert.Equal(t, want, got, "T=%d", unix)
+	}
+}
+
+func TestVerifyTOTP(t *testing.T) {
+	secret := GenerateTOTPSecret()
+	now := time.Unix(1_800_000_000, 0)
+	code, _ := TOTPCode(secret, now.Unix()/TOTPPeriod)
+
+	step, ok := VerifyTOTP(secret, code, now.Add(25*time.Second))
+	assert.True(t, ok, "kode step sebelumnya masih diterima")
+	assert.Equal(t, now.Unix()/TOTPPeriod, step)
+
+	_, ok = VerifyTOTP(secret, code, now.Add(2*time.Minute))
+	assert.False(t, ok, "kode lama di luar toleransi harus ditolak")
+
+	uri := TOTPURI("Food App", "budi@example.com", secret)
+	assert.True(t, strings.HasPrefix(uri, "otpauth://totp/Food%20App:budi@example.com?"), uri)
+	assert.Contains(t, uri, "secret="+secret)
+}
+
+func TestEncryptSecret(t *testing.T) {
+	t.Setenv("TOTP_ENCRYPTION_KEY", "")
+	t.Setenv("TOTP_EPHEMERAL_KEY", "true")
+	InitSecretKey()
+
+	enc, err := EncryptSecret("JBSWY3DPEHPK3PXP")
+	assert.NoError(t, err)
+	assert.NotContains(t, enc, "JBSWY3DPEHPK3PXP")
+
+	plain, err := DecryptSecret(enc)
+	assert.NoError(t, err)
+	assert.Equal(t, "JBSWY3DPEHPK3PXP", plain)
+
+	_, err = DecryptSecret(enc[:len(enc)-4] + "AAAA")
+	assert.Error(t, err, "ciphertext yang diubah harus ditolak")
+}
+
+func TestSecretKeyMissing(t *testing.T) {
+	t.Setenv("TOTP_ENCRYPTION_KEY", "")
+	t.Setenv("TOTP_EPHEMERAL_KEY", "")
+	old := secretKey
+	t.Cleanup(func() { secretKey = old })
+	InitSecretKey()
+
+	// Service tetap jalan, 2FA saja yang tidak tersedia
+	assert.False(t, TOTPAvailable())
+	_, err := EncryptSecret("JBSWY3DPEHPK3PXP")
+	assert.ErrorIs(t, err, ErrTOTPKeyMissing)
+	_, err = DecryptSecret("AAAA")
+	assert.ErrorIs(t, err, ErrTOTPKeyMissing)
+}
@@ -0,0 +1,7 @@
+-- Session id: dibawa oleh semua refresh token hasil rotasi dari satu login,
+-- dan ikut masuk ke access token (claim "sid") supaya satu sesi bisa dicabut.
+ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS session_id VARCHAR(64);
+UPDATE refresh_tokens SET session_id = 'legacy-' || id WHERE session_id IS NULL;
+ALTER TABLE refresh_tokens ALTER COLUMN session_id SET NOT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_refresh_session ON refresh_tokens(session_id);
@@ -0,0 +1,21 @@
+-- Role user untuk RBAC di API Gateway. Nama role ikut masuk ke access token
+-- (claim "roles"), dicek gateway sesuai policy.yaml.
+CREATE TABLE IF NOT EXISTS roles (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(32) UNIQUE NOT NULL
+);
+
+INSERT INTO roles (name) VALUES ('customer'), ('merchant'), ('admin'), ('support')
+ON CONFLICT (name) DO NOTHING;
+
+CREATE TABLE IF NOT EXISTS user_roles (
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    role_id INT NOT NULL REFERENCES roles(id) ON DELETE CASCADE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, role_id)
+);
+
+-- User lama otomatis jadi customer
+INSERT INTO user_roles (user_id, role_id)
+SELECT u.id, r.id FROM users u CROSS JOIN roles r WHERE r.name = 'customer'
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,18 @@
+-- API key untuk client mesin (kiosk partner, batch job) yang tidak bisa login
+-- email/OTP. Yang disimpan hanya hash SHA-256 dari key; key mentah cuma
+-- ditampilkan sekali saat dibuat. prefix dipakai untuk mengenali key di daftar.
+CREATE TABLE IF NOT EXISTS api_keys (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    prefix VARCHAR(16) NOT NULL,
+    key_hash CHAR(64) UNIQUE NOT NULL,
+    scopes TEXT[] NOT NULL DEFAULT '{}',
+    rate_limit INT NOT NULL DEFAULT 0, -- request per menit, 0 = default gateway
+    expires_at TIMESTAMP,
+    revoked_at TIMESTAMP,
+    last_used_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_api_keys_user ON api_keys(user_id);
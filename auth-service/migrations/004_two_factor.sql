@@ -0,0 +1,22 @@
+-- 2FA TOTP (RFC 6238). Secret dienkripsi AES-GCM dengan TOTP_ENCRYPTION_KEY
+-- sebelum disimpan. enabled_at NULL = masih enrollment (belum dikonfirmasi
+-- dengan kode pertama). last_step: time step kode terakhir yang dipakai,
+-- supaya kode yang sama tidak bisa dipakai dua kali.
+CREATE TABLE IF NOT EXISTS user_totp (
+    user_id BIGINT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    secret_enc TEXT NOT NULL,
+    enabled_at TIMESTAMP,
+    last_step BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Recovery code sekali pakai, yang disimpan hanya hash SHA-256-nya.
+CREATE TABLE IF NOT EXISTS recovery_codes (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    code_hash CHAR(64) NOT NULL,
+    used_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_recovery_codes_user ON recovery_codes(user_id);
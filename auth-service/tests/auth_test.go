@@ -4,12 +4,17 @@ import (
 	"auth-service/internal/database"
 	"auth-service/internal/handler"
 	"auth-service/internal/middleware"
+	"auth-service/internal/service"
+	"auth-service/internal/utils"
 	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
 	"log"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"strconv"
 	"testing"
 	"time"
 
@@ -28,6 +33,11 @@ func setupRouter() *gin.Engine {
 	// 2. Konek Database (Pake database asli local)
 	database.InitDB()
 	database.InitRedis()
+	utils.InitKeys()
+	if os.Getenv("TOTP_ENCRYPTION_KEY") == "" {
+		os.Setenv("TOTP_EPHEMERAL_KEY", "true") // 2FA tetap bisa dites tanpa kunci di .env
+	}
+	utils.InitSecretKey()
 
 	// 3. Setup Router (Sama persis kayak di main.go)
 	gin.SetMode(gin.TestMode) // Supaya log gak berisik
@@ -38,20 +48,33 @@ func setupRouter() *gin.Engine {
 	{
 		auth.POST("/register", handler.Register)
 		auth.POST("/verify", handler.Verify)
+		auth.POST("/resend-verification", handler.ResendVerification)
 		auth.POST("/login", handler.Login)
+		auth.POST("/login/2fa", handler.Login2FA)
 		auth.POST("/refresh", handler.Refresh)
 		auth.POST("/logout", handler.Logout)
+		auth.POST("/forgot-password", handler.ForgotPassword)
+		auth.POST("/reset-password", handler.ResetPassword)
+
+		twoFactor := auth.Group("/2fa", middleware.RequireUser())
+		twoFactor.POST("/enroll", handler.EnrollTOTP)
+		twoFactor.POST("/confirm", handler.ConfirmTOTP)
 	}
 	return r
 }
 
+// testIP: alamat client di request yang dibatasi per IP (resend verifikasi)
+const testIP = "198.51.100.10"
+
 // --- HELPER: Bersihkan Data Bekas Test ---
 func clearTestData(email string) {
 	// Hapus token & user dari Postgres
 	// Hapus OTP dari Redis
 	database.DB.Exec("DELETE FROM refresh_tokens WHERE user_id IN (SELECT id FROM users WHERE email = $1)", email)
 	database.DB.Exec("DELETE FROM users WHERE email = $1", email)
-	database.RDB.Del(context.Background(), "verif:"+email)
+	database.RDB.Del(context.Background(), "verif:"+email, "verif:cooldown:"+email, "verif:daily:email:"+email, "verif:attempts:"+email, "reset:"+email, "reset:attempts:"+email,
+		"login:fail:email:"+email, "login:wait:email:"+email, "login:lock:email:"+email, "login:lockouts:email:"+email,
+		"verif:daily:ip:"+testIP, "login:fail:ip:"+testIP, "login:wait:ip:"+testIP)
 }
 
 // --- TEST UTAMA (END-TO-END) ---
@@ -177,4 +200,272 @@ func TestFullAuthFlow(t *testing.T) {
 		}
 		assert.NotEqual(t, refreshCookie.Value, newRefreshCookie.Value, "Refresh token hash harus berubah (Rotated)")
 	})
-}
\ No newline at end of file
+
+	// --- STEP 6: LUPA PASSWORD ---
+	var resetCode string
+	t.Run("6. Lupa Password", func(t *testing.T) {
+		post := func(email string) *httptest.ResponseRecorder {
+			jsonBody, _ := json.Marshal(map[string]string{"email": email})
+			req, _ := http.NewRequest("POST", "/auth/forgot-password", bytes.NewBuffer(jsonBody))
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			return w
+		}
+
+		// Response email terdaftar & tidak terdaftar harus sama persis
+		known, unknown := post(email), post("tidak_ada@example.com")
+		assert.Equal(t, http.StatusOK, known.Code)
+		assert.Equal(t, known.Code, unknown.Code)
+		assert.Equal(t, known.Body.String(), unknown.Body.String())
+
+		val, err := database.RDB.Get(context.Background(), "reset:"+email).Result()
+		assert.NoError(t, err, "kode reset harus ada di Redis")
+		resetCode = val
+	})
+
+	// --- STEP 7: RESET PASSWORD ---
+	newPassword := "passwordBaru456!"
+	t.Run("7. Reset Password", func(t *testing.T) {
+		reset := func() *httptest.ResponseRecorder {
+			jsonBody, _ := json.Marshal(map[string]string{"email": email, "code": resetCode, "new_password": newPassword})
+			req, _ := http.NewRequest("POST", "/auth/reset-password", bytes.NewBuffer(jsonBody))
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			return w
+		}
+
+		assert.Equal(t, http.StatusOK, reset().Code)
+		assert.Equal(t, http.StatusBadRequest, reset().Code, "kode reset hanya boleh dipakai sekali")
+
+		// Semua refresh token lama dicabut
+		var active int
+		database.DB.QueryRow("SELECT COUNT(*) FROM refresh_tokens WHERE user_id IN (SELECT id FROM users WHERE email = $1) AND revoked_at IS NULL", email).Scan(&active)
+		assert.Zero(t, active, "refresh token harus di-revoke semua")
+
+		login := func(password string) int {
+			jsonBody, _ := json.Marshal(map[string]string{"email": email, "password": password})
+			req, _ := http.NewRequest("POST", "/auth/login", bytes.NewBuffer(jsonBody))
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			return w.Code
+		}
+		assert.Equal(t, http.StatusUnauthorized, login(password), "password lama tidak boleh dipakai lagi")
+		assert.Equal(t, http.StatusOK, login(newPassword))
+	})
+
+	// --- STEP 8: KIRIM ULANG VERIFIKASI (COOLDOWN & KUOTA HARIAN) ---
+	t.Run("8. Kirim Ulang Verifikasi", func(t *testing.T) {
+		resend := func() (*httptest.ResponseRecorder, int) {
+			jsonBody, _ := json.Marshal(map[string]string{"email": email})
+			req, _ := http.NewRequest("POST", "/auth/resend-verification", bytes.NewBuffer(jsonBody))
+			req.RemoteAddr = testIP + ":1234"
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			var resp struct {
+				RetryAfter int `json:"retry_after"`
+			}
+			json.Unmarshal(w.Body.Bytes(), &resp)
+			return w, resp.RetryAfter
+		}
+
+		w, retryAfter := resend()
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, int(service.ResendCooldown.Seconds()), retryAfter)
+
+		// Langsung minta lagi: masih cooldown
+		w, retryAfter = resend()
+		assert.Equal(t, http.StatusTooManyRequests, w.Code)
+		assert.NotEmpty(t, w.Header().Get("Retry-After"))
+		assert.Positive(t, retryAfter)
+		assert.LessOrEqual(t, retryAfter, int(service.ResendCooldown.Seconds()))
+
+		// Cooldown dihapus untuk mensimulasikan waktu berlalu, sampai kuota
+		// harian per email habis (request yang kena cooldown tidak dihitung)
+		for i := 1; i < service.ResendDailyPerMail; i++ {
+			database.RDB.Del(context.Background(), "verif:cooldown:"+email)
+			w, _ = resend()
+			assert.Equal(t, http.StatusOK, w.Code, "kiriman ke-%d masih dalam kuota", i+1)
+		}
+		database.RDB.Del(context.Background(), "verif:cooldown:"+email)
+		w, retryAfter = resend()
+		assert.Equal(t, http.StatusTooManyRequests, w.Code, "kuota harian habis")
+		assert.Greater(t, retryAfter, int(service.ResendCooldown.Seconds()), "tunggu sampai counter harian habis, bukan cuma cooldown")
+
+		// Akun sudah terverifikasi: batasnya sama, tapi tidak ada kode baru
+		_, err := database.RDB.Get(context.Background(), "verif:"+email).Result()
+		assert.Error(t, err, "akun terverifikasi tidak boleh dapat kode baru")
+	})
+
+	// --- STEP 9: THROTTLING LOGIN (BACKOFF, LOCKOUT, RESET COUNTER) ---
+	t.Run("9. Throttling Login", func(t *testing.T) {
+		ctx := context.Background()
+		login := func(password string) *httptest.ResponseRecorder {
+			jsonBody, _ := json.Marshal(map[string]string{"email": email, "password": password})
+			req, _ := http.NewRequest("POST", "/auth/login", bytes.NewBuffer(jsonBody))
+			req.RemoteAddr = testIP + ":1234"
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			return w
+		}
+		// Menghapus backoff = mensimulasikan waktu tunggu sudah lewat
+		skipBackoff := func() { database.RDB.Del(ctx, "login:wait:email:"+email) }
+
+		for i := 0; i < service.LoginFreeAttempts; i++ {
+			assert.Equal(t, http.StatusUnauthorized, login("salah").Code)
+		}
+		// Gagal berikutnya memasang backoff 1s: password benar pun ditolak dulu
+		assert.Equal(t, http.StatusUnauthorized, login("salah").Code)
+		w := login(newPassword)
+		assert.Equal(t, http.StatusTooManyRequests, w.Code)
+		assert.Equal(t, "1", w.Header().Get("Retry-After"))
+
+		// Login sukses setelah backoff lewat mereset counter email
+		skipBackoff()
+		assert.Equal(t, http.StatusOK, login(newPassword).Code)
+		assert.Zero(t, database.RDB.Exists(ctx, "login:fail:email:"+email).Val(), "counter gagal harus direset")
+
+		// LoginLockThreshold kali gagal: akun dikunci (423)
+		for i := 1; i < service.LoginLockThreshold; i++ {
+			skipBackoff()
+			assert.Equal(t, http.StatusUnauthorized, login("salah").Code, "gagal ke-%d", i)
+		}
+		skipBackoff()
+		w = login("salah")
+		assert.Equal(t, http.StatusLocked, w.Code)
+		assert.Equal(t, strconv.Itoa(int(service.LoginLockDuration.Seconds())), w.Header().Get("Retry-After"))
+		assert.Equal(t, http.StatusLocked, login(newPassword).Code, "password benar tetap ditolak selama dikunci")
+
+		// Reset password membuka kunci
+		jsonBody, _ := json.Marshal(map[string]string{"email": email})
+		req, _ := http.NewRequest("POST", "/auth/forgot-password", bytes.NewBuffer(jsonBody))
+		router.ServeHTTP(httptest.NewRecorder(), req)
+		code, err := database.RDB.Get(ctx, "reset:"+email).Result()
+		assert.NoError(t, err, "kode reset harus ada di Redis")
+
+		jsonBody, _ = json.Marshal(map[string]string{"email": email, "code": code, "new_password": password})
+		req, _ = http.NewRequest("POST", "/auth/reset-password", bytes.NewBuffer(jsonBody))
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, http.StatusOK, login(password).Code, "kunci dibuka setelah reset password")
+	})
+
+	// --- STEP 10: 2FA (ENROLL, CONFIRM, LOGIN 2FA, RECOVERY CODE) ---
+	t.Run("10. Two-Factor Auth", func(t *testing.T) {
+		ctx := context.Background()
+		// Counter IP masih berisi kegagalan dari step 9
+		database.RDB.Del(ctx, "login:fail:ip:"+testIP, "login:wait:ip:"+testIP)
+		skipBackoff := func() { database.RDB.Del(ctx, "login:wait:email:"+email) }
+		post := func(path, accessToken string, body any) *httptest.ResponseRecorder {
+			jsonBody, _ := json.Marshal(body)
+			req, _ := http.NewRequest("POST", path, bytes.NewBuffer(jsonBody))
+			req.RemoteAddr = testIP + ":1234"
+			if accessToken != "" {
+				req.Header.Set("Authorization", "Bearer "+accessToken)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			return w
+		}
+		loginBody := map[string]string{"email": email, "password": password}
+
+		w := post("/auth/login", "", loginBody)
+		assert.Equal(t, http.StatusOK, w.Code)
+		var tokens map[string]any
+		json.Unmarshal(w.Body.Bytes(), &tokens)
+		accessToken, _ := tokens["access_token"].(string)
+
+		// Enroll wajib password saat ini
+		assert.Equal(t, http.StatusBadRequest, post("/auth/2fa/enroll", accessToken, map[string]string{}).Code)
+		assert.Equal(t, http.StatusUnauthorized, post("/auth/2fa/enroll", accessToken, map[string]string{"password": "salah"}).Code)
+
+		enroll := func() string {
+			w := post("/auth/2fa/enroll", accessToken, map[string]string{"password": password})
+			assert.Equal(t, http.StatusOK, w.Code)
+			var resp map[string]string
+			json.Unmarshal(w.Body.Bytes(), &resp)
+			return resp["secret"]
+		}
+		secret := enroll()
+		code := func(offset int64) string {
+			c, _ := utils.TOTPCode(secret, time.Now().Unix()/utils.TOTPPeriod+offset)
+			return c
+		}
+		// Kode 6 digit yang tidak cocok dengan step mana pun di jendela verifikasi
+		wrongCode := func() string {
+			for n := 0; ; n++ {
+				c := fmt.Sprintf("%06d", n)
+				if _, ok := utils.VerifyTOTP(secret, c, time.Now()); !ok {
+					return c
+				}
+			}
+		}
+
+		// Salah tebak ke-MaxOTPAttempts menghanguskan enrollment
+		for i := 0; i < service.MaxOTPAttempts; i++ {
+			assert.Equal(t, http.StatusBadRequest, post("/auth/2fa/confirm", accessToken, map[string]string{"code": wrongCode()}).Code)
+		}
+		w = post("/auth/2fa/confirm", accessToken, map[string]string{"code": code(0)})
+		assert.Equal(t, http.StatusBadRequest, w.Code, "kode benar ditolak setelah enrollment hangus")
+		assert.Contains(t, w.Body.String(), service.ErrEnrollmentExpired.Error())
+
+		// Enroll ulang lalu konfirmasi
+		secret = enroll()
+		w = post("/auth/2fa/confirm", accessToken, map[string]string{"code": code(0)})
+		assert.Equal(t, http.StatusOK, w.Code)
+		var confirmed struct {
+			RecoveryCodes []string `json:"recovery_codes"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &confirmed)
+		if !assert.Len(t, confirmed.RecoveryCodes, service.RecoveryCodeCount) {
+			return
+		}
+		recovery := confirmed.RecoveryCodes
+
+		// Login sekarang berhenti di challenge
+		challenge := func() string {
+			w := post("/auth/login", "", loginBody)
+			assert.Equal(t, http.StatusOK, w.Code)
+			var resp map[string]any
+			json.Unmarshal(w.Body.Bytes(), &resp)
+			assert.Equal(t, true, resp["two_factor_required"])
+			assert.Nil(t, resp["access_token"], "token baru keluar setelah 2FA")
+			ch, _ := resp["challenge_token"].(string)
+			return ch
+		}
+		login2FA := func(ch, code string) int {
+			return post("/auth/login/2fa", "", map[string]string{"challenge_token": ch, "code": code}).Code
+		}
+
+		// Kode TOTP: step yang dipakai confirm sudah terpakai, jadi pakai
+		// step berikutnya. Challenge hanya bisa ditukar sekali.
+		ch := challenge()
+		assert.Equal(t, http.StatusOK, login2FA(ch, code(1)))
+		assert.Equal(t, http.StatusUnauthorized, login2FA(ch, recovery[0]), "challenge tidak bisa dipakai ulang")
+
+		// Recovery code hanya sekali pakai
+		assert.Equal(t, http.StatusOK, login2FA(challenge(), recovery[0]))
+		assert.Equal(t, http.StatusUnauthorized, login2FA(challenge(), recovery[0]), "recovery code tidak bisa dipakai ulang")
+
+		// Challenge kadaluarsa: key dihapus = mensimulasikan ChallengeTTL lewat
+		ch = challenge()
+		key := "2fa:challenge:" + utils.HashToken(ch)
+		ttl := database.RDB.TTL(ctx, key).Val()
+		assert.True(t, ttl > 0 && ttl <= service.ChallengeTTL, "challenge harus punya TTL, dapat %v", ttl)
+		database.RDB.Del(ctx, key)
+		assert.Equal(t, http.StatusUnauthorized, login2FA(ch, recovery[1]))
+		assert.Equal(t, http.StatusOK, login2FA(challenge(), recovery[1]), "recovery code tidak terpakai oleh challenge kadaluarsa")
+
+		// Salah tebak ke-MaxChallengeAttempts menghanguskan challenge
+		ch = challenge()
+		for i := 0; i < service.MaxChallengeAttempts; i++ {
+			skipBackoff()
+			assert.Equal(t, http.StatusUnauthorized, login2FA(ch, "salah-salah"), "percobaan ke-%d", i+1)
+		}
+		skipBackoff()
+		assert.Equal(t, http.StatusUnauthorized, login2FA(ch, recovery[2]), "challenge sudah hangus")
+		skipBackoff()
+		assert.Equal(t, http.StatusOK, login2FA(challenge(), recovery[2]), "recovery code tidak terpakai oleh challenge yang hangus")
+	})
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"net/http"
+
+	"shared/metrics"
+	"shared/server"
+
+	"github.com/gin-gonic/gin"
+)
+
+type routeStatus struct {
+	Prefix    string             `json:"prefix"`
+	Balancer  string             `json:"balancer"`
+	Upstreams []UpstreamSnapshot `json:"upstreams"`
+}
+
+// AdminHandler: endpoint internal gateway, di-listen terpisah (admin_listen)
+// supaya tidak ikut terekspos lewat port publik.
+func (g *Gateway) AdminHandler() http.Handler {
+	r := gin.New()
+	r.Use(gin.Recovery())
+
+	// Status pool & circuit breaker setiap upstream
+	r.GET("/admin/upstreams", func(c *gin.Context) {
+		var out []routeStatus
+		for _, rt := range g.handler.Load().routes {
+			out = append(out, routeStatus{
+				Prefix:    rt.Prefix,
+				Balancer:  rt.Balancer,
+				Upstreams: rt.pool.Snapshot(),
+			})
+		}
+		c.JSON(http.StatusOK, out)
+	})
+
+	// Metrik Prometheus gateway (request per route & per upstream)
+	r.GET("/metrics", metrics.Handler())
+
+	// Probe liveness/readiness. Redis tidak dicek: rate limit & denylist
+	// fail open, jadi gateway tetap bisa melayani saat Redis down.
+	server.Probes(r, nil)
+
+	return r
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"shared/identity"
+	"shared/logging"
+	"shared/svcauth"
+	"shared/tracing"
+
+	"github.com/gin-gonic/gin"
+)
+
+const HeaderAPIKey = "X-API-Key"
+
+// APIKeyIdentity: hasil resolve API key dari auth-service.
+type APIKeyIdentity struct {
+	KeyID     int64    `json:"key_id"`
+	UserID    string   `json:"user_id"`
+	Username  string   `json:"username"`
+	Scopes    []string `json:"scopes"`
+	RateLimit int      `json:"rate_limit"` // request per menit, 0 = default gateway
+}
+
+// apiKeyNegativeTTL: key tidak valid cukup di-cache sebentar; key acak
+// tidak akan diulang, jadi cache lama hanya menghabiskan memori.
+const apiKeyNegativeTTL = 10 * time.Second
+
+// APIKeys me-resolve header X-API-Key lewat endpoint internal auth-service
+// (request ditandatangani SERVICE_HMAC_KEY). Key valid disimpan di memori
+// selama CacheTTL (key yang baru dicabut masih bisa lolos paling lama selama
+// itu), key tidak valid selama apiKeyNegativeTTL. Cache miss dibatasi per IP
+// (ResolveRateLimit).
+type APIKeys struct {
+	url          string
+	ttl          time.Duration
+	key          []byte
+	client       *http.Client
+	limiter      Limiter
+	resolveLimit int
+
+	mu    sync.Mutex
+	cache map[string]apiKeyEntry // key: hash API key, key mentah tidak disimpan
+}
+
+type apiKeyEntry struct {
+	id      *APIKeyIdentity // nil = key tidak valid
+	expires time.Time
+}
+
+func NewAPIKeys(cfg APIKeyConfig, serviceKey []byte, limiter Limiter) *APIKeys {
+	k := &APIKeys{
+		url:          cfg.URL + "/auth/internal/api-keys/resolve",
+		ttl:          time.Duration(cfg.CacheTTL),
+		key:          serviceKey,
+		client:       &http.Client{Timeout: 5 * time.Second, Transport: tracing.Transport(nil)},
+		limiter:      limiter,
+		resolveLimit: cfg.ResolveRateLimit,
+		cache:        make(map[string]apiKeyEntry),
+	}
+	go k.cleanup()
+	return k
+}
+
+func apiKeyHash(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
+
+// cached: hasil resolve yang masih berlaku, ok false kalau belum ada.
+func (k *APIKeys) cached(raw string) (id *APIKeyIdentity, ok bool) {
+	k.mu.Lock()
+	entry, ok := k.cache[apiKeyHash(raw)]
+	k.mu.Unlock()
+	if !ok || time.Now().After(entry.expires) {
+		return nil, false
+	}
+	return entry.id, true
+}
+
+// Resolve: nil tanpa error kalau key tidak dikenal, dicabut, atau kedaluwarsa.
+func (k *APIKeys) Resolve(ctx context.Context, raw string) (*APIKeyIdentity, error) {
+	if id, ok := k.cached(raw); ok {
+		return id, nil
+	}
+
+	id, err := k.fetch(ctx, raw)
+	if err != nil {
+		return nil, err
+	}
+	ttl := k.ttl
+	if id == nil {
+		ttl = min(ttl, apiKeyNegativeTTL)
+	}
+	k.mu.Lock()
+	k.cache[apiKeyHash(raw)] = apiKeyEntry{id: id, expires: time.Now().Add(ttl)}
+	k.mu.Unlock()
+	return id, nil
+}
+
+func (k *APIKeys) fetch(ctx context.Context, raw string) (*APIKeyIdentity, error) {
+	body, _ := json.Marshal(map[string]string{"key": raw})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, k.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	logging.Propagate(req)
+	if err := svcauth.Sign(req, "gateway", k.key); err != nil {
+		return nil, err
+	}
+
+	resp, err := k.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		var id APIKeyIdentity
+		if err := json.NewDecoder(resp.Body).Decode(&id); err != nil {
+			return nil, err
+		}
+		return &id, nil
+	case http.StatusNotFound:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("resolve api key: %s", resp.Status)
+	}
+}
+
+func (k *APIKeys) cleanup() {
+	for range time.Tick(time.Minute) {
+		now := time.Now()
+		k.mu.Lock()
+		for hash, e := range k.cache {
+			if now.After(e.expires) {
+				delete(k.cache, hash)
+			}
+		}
+		k.mu.Unlock()
+	}
+}
+
+// authenticateAPIKey dipanggil AuthMiddleware untuk request dengan X-API-Key
+// (tanpa Bearer). Identitas yang dikirim ke service sama dengan JWT, hanya
+// saja tanpa roles: hak akses key murni dari scopes-nya.
+func authenticateAPIKey(c *gin.Context, apiKeys *APIKeys, identityKey []byte) {
+	raw := c.GetHeader(HeaderAPIKey)
+	c.Request.Header.Del(HeaderAPIKey) // key tidak ikut diteruskan ke upstream
+
+	// Hanya cache miss yang sampai ke auth-service, jadi hanya itu yang
+	// dibatasi per IP; key valid yang sudah di-cache tidak ikut terhitung
+	if _, ok := apiKeys.cached(raw); !ok && apiKeys.resolveLimit > 0 {
+		n := apiKeys.resolveLimit
+		if !allowRequest(c, apiKeys.limiter, "ratelimit:apikey-resolve:"+c.ClientIP(), float64(n)/60, n) {
+			return
+		}
+	}
+
+	key, err := apiKeys.Resolve(c.Request.Context(), raw)
+	if err != nil {
+		logging.FromContext(c.Request.Context()).Error("resolve api key gagal", "error", err)
+		c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "Service Unavailable"})
+		return
+	}
+	if key == nil {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid API Key"})
+		return
+	}
+
+	c.Set("api_key", key)
+	setIdentity(c, identity.Identity{UserID: key.UserID, Username: key.Username, Scopes: key.Scopes}, identityKey)
+}
+
+// APIKeyRateLimitMiddleware: batas request per API key (rate_limit key, atau
+// default dari config), di luar rate limit per route.
+func APIKeyRateLimitMiddleware(limiter Limiter, defaultPerMinute int) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		v, ok := c.Get("api_key")
+		if !ok {
+			c.Next()
+			return
+		}
+		key := v.(*APIKeyIdentity)
+		perMinute := key.RateLimit
+		if perMinute <= 0 {
+			perMinute = defaultPerMinute
+		}
+		if !allowRequest(c, limiter, "ratelimit:apikey:"+strconv.FormatInt(key.KeyID, 10), float64(perMinute)/60, perMinute) {
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"shared/identity"
+	"shared/svcauth"
+
+	"github.com/gin-gonic/gin"
+)
+
+const testAPIKey = "gk_valid"
+
+// newFakeResolver: endpoint resolve auth-service palsu, hanya menerima request
+// yang ditandatangani dengan kunci service yang benar.
+func newFakeResolver(t *testing.T, serviceKey []byte, calls *atomic.Int32) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if _, err := svcauth.Verify(r, serviceKey, time.Now()); err != nil || r.URL.Path != "/auth/internal/api-keys/resolve" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var req struct{ Key string }
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.Key != testAPIKey {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{"key_id":9,"user_id":"7","username":"kiosk","scopes":["orders:read"],"rate_limit":2}`))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestAPIKeyAuth(t *testing.T) {
+	serviceKey, identityKey := []byte("service-key"), []byte("identity-key")
+	var calls atomic.Int32
+	resolver := newFakeResolver(t, serviceKey, &calls)
+	apiKeys := NewAPIKeys(APIKeyConfig{URL: resolver.URL, CacheTTL: Duration(time.Minute)}, serviceKey, NewMemoryLimiter())
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/order/*proxyPath",
+		AuthMiddleware(NewJWKSCache(resolver.URL, time.Minute), []string{"EdDSA"}, nil, apiKeys, identityKey),
+		APIKeyRateLimitMiddleware(NewMemoryLimiter(), 60),
+		func(c *gin.Context) {
+			if c.GetHeader(HeaderAPIKey) != "" {
+				t.Error("X-API-Key tidak boleh diteruskan ke upstream")
+			}
+			id, err := identity.Verify(c.GetHeader(identity.Header), identityKey, time.Now())
+			if err != nil {
+				t.Errorf("identity assertion: %v", err)
+				return
+			}
+			c.String(http.StatusOK, id.UserID+" "+strings.Join(id.Scopes, ","))
+		})
+
+	get := func(key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/order/list", nil)
+		if key != "" {
+			req.Header.Set(HeaderAPIKey, key)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := get(""); w.Code != http.StatusUnauthorized {
+		t.Fatalf("tanpa kredensial = %d, want 401", w.Code)
+	}
+	if w := get("gk_salah"); w.Code != http.StatusUnauthorized {
+		t.Fatalf("key tidak dikenal = %d, want 401", w.Code)
+	}
+
+	// rate_limit key = 2 per menit
+	for i := range 2 {
+		w := get(testAPIKey)
+		if w.Code != http.StatusOK || w.Body.String() != "7 orders:read" {
+			t.Fatalf("request %d = %d %q", i, w.Code, w.Body)
+		}
+	}
+	if w := get(testAPIKey); w.Code != http.StatusTooManyRequests {
+		t.Fatalf("melebihi rate limit key = %d, want 429", w.Code)
+	}
+
+	// Hasil resolve (valid maupun tidak) di-cache
+	get("gk_salah")
+	if got := calls.Load(); got != 2 {
+		t.Fatalf("resolver dipanggil %d kali, want 2", got)
+	}
+}
+
+func TestAPIKeyResolverDown(t *testing.T) {
+	var calls atomic.Int32
+	resolver := newFakeResolver(t, []byte("kunci-lain"), &calls)
+	apiKeys := NewAPIKeys(APIKeyConfig{URL: resolver.URL, CacheTTL: Duration(time.Minute)}, []byte("service-key"), NewMemoryLimiter())
+
+	// Auth-service menolak signature: jangan dianggap key tidak valid (401)
+	// dan jangan di-cache
+	for range 2 {
+		if id, err := apiKeys.Resolve(t.Context(), testAPIKey); err == nil || id != nil {
+			t.Fatalf("Resolve = %v, %v; want error", id, err)
+		}
+	}
+	if got := calls.Load(); got != 2 {
+		t.Fatalf("resolver dipanggil %d kali, want 2", got)
+	}
+}
+
+// Key acak selalu cache miss: dibatasi per IP sebelum sampai ke auth-service.
+func TestAPIKeyResolveLimitPerIP(t *testing.T) {
+	serviceKey := []byte("service-key")
+	var calls atomic.Int32
+	resolver := newFakeResolver(t, serviceKey, &calls)
+	apiKeys := NewAPIKeys(APIKeyConfig{URL: resolver.URL, CacheTTL: Duration(time.Minute), ResolveRateLimit: 2}, serviceKey, NewMemoryLimiter())
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/order/*proxyPath",
+		AuthMiddleware(NewJWKSCache(resolver.URL, time.Minute), []string{"EdDSA"}, nil, apiKeys, []byte("identity-key")),
+		func(c *gin.Context) { c.String(http.StatusOK, "ok") })
+	get := func(key, ip string) int {
+		req := httptest.NewRequest(http.MethodGet, "/order/list", nil)
+		req.RemoteAddr = ip + ":1234"
+		req.Header.Set(HeaderAPIKey, key)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	if code := get(testAPIKey, "192.0.2.1"); code != http.StatusOK {
+		t.Fatalf("key valid = %d, want 200", code)
+	}
+	if code := get("gk_acak1", "192.0.2.1"); code != http.StatusUnauthorized {
+		t.Fatalf("key acak pertama = %d, want 401", code)
+	}
+	if code := get("gk_acak2", "192.0.2.1"); code != http.StatusTooManyRequests {
+		t.Fatalf("key acak melebihi batas resolve = %d, want 429", code)
+	}
+	if got := calls.Load(); got != 2 {
+		t.Fatalf("resolver dipanggil %d kali, want 2", got)
+	}
+
+	// Key yang sudah di-cache tidak ikut dibatasi, IP lain punya jatah sendiri
+	if code := get(testAPIKey, "192.0.2.1"); code != http.StatusOK {
+		t.Fatalf("key valid dari cache = %d, want 200", code)
+	}
+	if code := get("gk_acak3", "192.0.2.2"); code != http.StatusUnauthorized {
+		t.Fatalf("IP lain = %d, want 401", code)
+	}
+
+	// Key tidak valid di-cache lebih singkat dari key valid
+	apiKeys.mu.Lock()
+	valid, invalid := apiKeys.cache[apiKeyHash(testAPIKey)], apiKeys.cache[apiKeyHash("gk_acak1")]
+	apiKeys.mu.Unlock()
+	if invalid.id != nil || time.Until(invalid.expires) > apiKeyNegativeTTL || time.Until(valid.expires) <= apiKeyNegativeTTL {
+		t.Fatalf("cache valid %v, tidak valid %v", time.Until(valid.expires), time.Until(invalid.expires))
+	}
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+type BreakerState int
+
+const (
+	StateClosed BreakerState = iota
+	StateOpen
+	StateHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+func (s BreakerState) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// CircuitOpenError dikembalikan Pool.Pick saat semua upstream yang tersisa
+// sedang diputus circuit breaker-nya.
+type CircuitOpenError struct {
+	RetryAfter time.Duration
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("circuit open, coba lagi dalam %s", e.RetryAfter)
+}
+
+// Breaker adalah circuit breaker untuk satu upstream.
+//
+//	closed    -> semua request lewat; FailureThreshold kegagalan beruntun => open
+//	open      -> request ditolak langsung sampai Cooldown lewat => half-open
+//	half-open -> maksimal HalfOpenRequests request percobaan; semua sukses =>
+//	             closed, satu gagal => open lagi
+type Breaker struct {
+	name string
+	cfg  CircuitBreakerConfig
+
+	mu        sync.Mutex
+	state     BreakerState
+	failures  int
+	openedAt  time.Time
+	inFlight  int // request percobaan saat half-open
+	successes int // percobaan half-open yang sukses
+}
+
+func NewBreaker(name string, cfg CircuitBreakerConfig) *Breaker {
+	return &Breaker{name: name, cfg: cfg}
+}
+
+func (b *Breaker) enabled() bool {
+	return b.cfg.FailureThreshold > 0
+}
+
+// Allow menentukan apakah satu request boleh dikirim ke upstream. Kalau true,
+// hasil request wajib dilaporkan lewat Record.
+func (b *Breaker) Allow(now time.Time) bool {
+	if !b.enabled() {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case StateOpen:
+		if now.Sub(b.openedAt) < time.Duration(b.cfg.Cooldown) {
+			return false
+		}
+		b.setState(StateHalfOpen)
+		b.inFlight, b.successes = 0, 0
+		fallthrough
+	case StateHalfOpen:
+		if b.inFlight >= b.cfg.HalfOpenRequests {
+			return false
+		}
+		b.inFlight++
+	}
+	return true
+}
+
+// Record mencatat hasil request yang sebelumnya diizinkan Allow.
+func (b *Breaker) Record(failed bool, now time.Time) {
+	if !b.enabled() {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case StateClosed:
+		if !failed {
+			b.failures = 0
+			return
+		}
+		b.failures++
+		if b.failures >= b.cfg.FailureThreshold {
+			b.trip(now)
+		}
+	case StateHalfOpen:
+		b.inFlight--
+		if failed {
+			b.trip(now)
+			return
+		}
+		b.successes++
+		if b.successes >= b.cfg.HalfOpenRequests {
+			b.failures = 0
+			b.setState(StateClosed)
+		}
+	}
+}
+
+func (b *Breaker) trip(now time.Time) {
+	b.openedAt = now
+	b.failures = 0
+	b.setState(StateOpen)
+}
+
+func (b *Breaker) setState(s BreakerState) {
+	if b.state != s {
+		slog.Warn("circuit breaker berubah state", "upstream", b.name, "from", b.state.String(), "to", s.String())
+	}
+	b.state = s
+}
+
+// RetryAfter: sisa waktu cooldown kalau breaker sedang open.
+func (b *Breaker) RetryAfter(now time.Time) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state != StateOpen {
+		return 0
+	}
+	return max(time.Duration(b.cfg.Cooldown)-now.Sub(b.openedAt), 0)
+}
+
+type BreakerSnapshot struct {
+	State    BreakerState `json:"state"`
+	Failures int          `json:"failures"`
+	OpenedAt *time.Time   `json:"opened_at,omitempty"`
+}
+
+func (b *Breaker) Snapshot() BreakerSnapshot {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	snap := BreakerSnapshot{State: b.state, Failures: b.failures}
+	if b.state != StateClosed {
+		openedAt := b.openedAt
+		snap.OpenedAt = &openedAt
+	}
+	return snap
+}
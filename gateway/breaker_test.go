@@ -0,0 +1,75 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBreakerLifecycle(t *testing.T) {
+	b := NewBreaker("test", CircuitBreakerConfig{
+		FailureThreshold: 2,
+		Cooldown:         Duration(10 * time.Second),
+		HalfOpenRequests: 1,
+	})
+	now := time.Now()
+
+	for i := 0; i < 2; i++ {
+		if !b.Allow(now) {
+			t.Fatal("breaker closed harus mengizinkan request")
+		}
+		b.Record(true, now)
+	}
+	if b.Snapshot().State != StateOpen {
+		t.Fatalf("state = %s, want open", b.Snapshot().State)
+	}
+	if b.Allow(now.Add(5 * time.Second)) {
+		t.Fatal("breaker open tidak boleh mengizinkan request sebelum cooldown")
+	}
+
+	// Setelah cooldown: satu request percobaan, gagal => open lagi
+	later := now.Add(11 * time.Second)
+	if !b.Allow(later) {
+		t.Fatal("breaker harus half-open setelah cooldown")
+	}
+	if b.Allow(later) {
+		t.Fatal("half-open hanya boleh satu request percobaan")
+	}
+	b.Record(true, later)
+	if b.Snapshot().State != StateOpen {
+		t.Fatalf("state = %s, want open", b.Snapshot().State)
+	}
+
+	// Percobaan berikutnya sukses => closed
+	recovered := later.Add(11 * time.Second)
+	if !b.Allow(recovered) {
+		t.Fatal("breaker harus half-open lagi setelah cooldown")
+	}
+	b.Record(false, recovered)
+	if b.Snapshot().State != StateClosed {
+		t.Fatalf("state = %s, want closed", b.Snapshot().State)
+	}
+}
+
+func TestPoolCircuitOpen(t *testing.T) {
+	p, err := NewPool(RouteConfig{
+		Upstreams: []string{"http://a:1"},
+		Balancer:  BalancerRoundRobin,
+		Breaker:   CircuitBreakerConfig{FailureThreshold: 1, Cooldown: Duration(time.Minute), HalfOpenRequests: 1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u, _ := p.Pick()
+	p.Acquire(u)(503, nil)
+
+	_, err = p.Pick()
+	var openErr *CircuitOpenError
+	if !errors.As(err, &openErr) {
+		t.Fatalf("err = %v, want *CircuitOpenError", err)
+	}
+	if openErr.RetryAfter <= 0 || openErr.RetryAfter > time.Minute {
+		t.Fatalf("RetryAfter = %s", openErr.RetryAfter)
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"shared/logging"
+
+	"github.com/gin-gonic/gin"
+)
+
+// partResult: hasil satu part composite. data nil = part gagal.
+type partResult struct {
+	data   json.RawMessage
+	status int
+	err    string
+}
+
+// compositeHandler: GET cc.Path memanggil semua part secara paralel lewat
+// router gateway sendiri (h), jadi auth, sesi, policy, rate limit & circuit
+// breaker tiap route tetap berlaku. Semua part berbagi satu deadline
+// (cc.Timeout). Part yang gagal diisi null dan dicatat di "errors"; response
+// hanya gagal total kalau part required gagal atau semua part gagal.
+func compositeHandler(cc CompositeConfig, h http.Handler) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), time.Duration(cc.Timeout))
+		defer cancel()
+
+		results := make([]partResult, len(cc.Parts))
+		var wg sync.WaitGroup
+		for i, part := range cc.Parts {
+			wg.Go(func() { results[i] = fetchPart(ctx, c.Request, part.Path, h) })
+		}
+		wg.Wait()
+
+		body := gin.H{}
+		errs := gin.H{}
+		failed := 0
+		for i, part := range cc.Parts {
+			res := results[i]
+			result := "ok"
+			if res.data == nil {
+				result = "error"
+				if res.status == http.StatusGatewayTimeout {
+					result = "timeout"
+				}
+				failed++
+				errs[part.Name] = gin.H{"status": res.status, "error": res.err}
+				logging.FromContext(c.Request.Context()).Warn("part composite gagal",
+					"composite", cc.Path, "part", part.Name, "status", res.status, "error", res.err)
+			}
+			compositeParts.WithLabelValues(cc.Path, part.Name, result).Inc()
+			body[part.Name] = res.data
+		}
+
+		for i, part := range cc.Parts {
+			if part.Required && results[i].data == nil {
+				status := compositeStatus(results[i].status)
+				c.AbortWithStatusJSON(status, gin.H{"error": http.StatusText(status), "errors": errs})
+				return
+			}
+		}
+
+		if failed == len(cc.Parts) {
+			// Biasanya karena belum login: semua part menjawab 401
+			status := compositeStatus(results[0].status)
+			for _, res := range results[1:] {
+				if compositeStatus(res.status) != status {
+					status = http.StatusBadGateway
+				}
+			}
+			c.AbortWithStatusJSON(status, gin.H{"error": http.StatusText(status), "errors": errs})
+			return
+		}
+		if len(errs) > 0 {
+			body["errors"] = errs
+		}
+		c.JSON(http.StatusOK, body)
+	}
+}
+
+// compositeStatus: 401, 403, 429 & timeout diteruskan apa adanya, selain itu
+// dianggap masalah upstream.
+func compositeStatus(status int) int {
+	switch status {
+	case http.StatusUnauthorized, http.StatusForbidden, http.StatusTooManyRequests, http.StatusGatewayTimeout:
+		return status
+	}
+	return http.StatusBadGateway
+}
+
+// fetchPart menjalankan GET path di router gateway dengan header (token,
+// cookie sesi, API key, request id) milik request asli.
+func fetchPart(ctx context.Context, parent *http.Request, path string, h http.Handler) partResult {
+	req := parent.Clone(ctx)
+	req.Method = http.MethodGet
+	req.URL = &url.URL{Path: path}
+	req.RequestURI = path
+	req.Body, req.ContentLength = http.NoBody, 0
+	req.Header.Del("Content-Type")
+	req.Header.Del("Content-Length")
+
+	w := &bufferedResponse{header: http.Header{}, status: http.StatusOK}
+	h.ServeHTTP(w, req)
+
+	ok := w.status < 300 && json.Valid(w.body.Bytes())
+	switch {
+	case ok:
+		return partResult{data: w.body.Bytes(), status: w.status}
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return partResult{status: http.StatusGatewayTimeout, err: "timeout"}
+	case w.status >= 300:
+		return partResult{status: w.status, err: errorMessage(w.status, w.body.Bytes())}
+	default:
+		return partResult{status: http.StatusBadGateway, err: "response bukan JSON"}
+	}
+}
+
+// errorMessage mengambil field "error" dari body error upstream/gateway.
+func errorMessage(status int, body []byte) string {
+	var e struct {
+		Error string `json:"error"`
+	}
+	if json.Unmarshal(body, &e) == nil && e.Error != "" {
+		return e.Error
+	}
+	return http.StatusText(status)
+}
+
+// bufferedResponse menampung response satu part di memori.
+type bufferedResponse struct {
+	header      http.Header
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (w *bufferedResponse) Header() http.Header { return w.header }
+
+func (w *bufferedResponse) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status, w.wroteHeader = status, true
+	}
+}
+
+func (w *bufferedResponse) Write(b []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	return w.body.Write(b)
+}
+
+// Flush: no-op, dipanggil reverse proxy untuk response tertentu.
+func (w *bufferedResponse) Flush() {}
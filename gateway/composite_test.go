@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newCompositeRouter(parts ...CompositePart) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/svc/ok", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"auth": c.GetHeader("Authorization")})
+	})
+	r.GET("/svc/fail", func(c *gin.Context) {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Service Unavailable"})
+	})
+	r.GET("/svc/unauth", func(c *gin.Context) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+	})
+	r.GET("/svc/slow", func(c *gin.Context) {
+		select {
+		case <-time.After(5 * time.Second):
+			c.JSON(http.StatusOK, gin.H{})
+		case <-c.Request.Context().Done():
+			c.AbortWithStatus(http.StatusGatewayTimeout)
+		}
+	})
+	cc := CompositeConfig{Path: "/summary", Timeout: Duration(200 * time.Millisecond), Parts: parts}
+	r.GET(cc.Path, compositeHandler(cc, r))
+	return r
+}
+
+func getSummary(t *testing.T, r http.Handler) (int, map[string]json.RawMessage) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/summary", nil)
+	req.Header.Set("Authorization", "Bearer abc")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	var body map[string]json.RawMessage
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("body bukan JSON: %s", w.Body)
+	}
+	return w.Code, body
+}
+
+func TestCompositePartial(t *testing.T) {
+	r := newCompositeRouter(
+		CompositePart{Name: "a", Path: "/svc/ok"},
+		CompositePart{Name: "b", Path: "/svc/fail"},
+		CompositePart{Name: "c", Path: "/svc/slow"},
+	)
+
+	start := time.Now()
+	code, body := getSummary(t, r)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("composite menunggu %v, deadline 200ms", elapsed)
+	}
+	if code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", code)
+	}
+	if string(body["a"]) != `{"auth":"Bearer abc"}` {
+		t.Fatalf("part a = %s (header request asli harus ikut)", body["a"])
+	}
+	if string(body["b"]) != "null" || string(body["c"]) != "null" {
+		t.Fatalf("part gagal harus null: b=%s c=%s", body["b"], body["c"])
+	}
+	var errs map[string]struct {
+		Status int    `json:"status"`
+		Error  string `json:"error"`
+	}
+	json.Unmarshal(body["errors"], &errs)
+	if errs["b"].Status != http.StatusServiceUnavailable || errs["c"].Error != "timeout" || len(errs) != 2 {
+		t.Fatalf("errors = %s", body["errors"])
+	}
+}
+
+func TestCompositeFailure(t *testing.T) {
+	cases := []struct {
+		name  string
+		parts []CompositePart
+		want  int
+	}{
+		{"required gagal", []CompositePart{{Name: "a", Path: "/svc/ok"}, {Name: "b", Path: "/svc/fail", Required: true}}, http.StatusBadGateway},
+		{"semua 401", []CompositePart{{Name: "a", Path: "/svc/unauth"}, {Name: "b", Path: "/svc/unauth"}}, http.StatusUnauthorized},
+		{"semua gagal beda status", []CompositePart{{Name: "a", Path: "/svc/unauth"}, {Name: "b", Path: "/svc/fail"}}, http.StatusBadGateway},
+		{"tanpa error", []CompositePart{{Name: "a", Path: "/svc/ok"}}, http.StatusOK},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			code, body := getSummary(t, newCompositeRouter(tc.parts...))
+			if code != tc.want {
+				t.Fatalf("status = %d, want %d", code, tc.want)
+			}
+			if _, ok := body["errors"]; ok != (code != http.StatusOK) {
+				t.Fatalf("errors hanya ada kalau ada part yang gagal: %v", body)
+			}
+		})
+	}
+}
+
+func TestValidateComposite(t *testing.T) {
+	cfg := &Config{Routes: []RouteConfig{{Prefix: "/order"}}}
+	cases := []struct {
+		name string
+		cc   CompositeConfig
+		ok   bool
+	}{
+		{"valid", CompositeConfig{Path: "/summary", Parts: []CompositePart{{Name: "orders", Path: "/order/list"}}}, true},
+		{"path di luar route", CompositeConfig{Path: "/summary", Parts: []CompositePart{{Name: "x", Path: "/summary"}}}, false},
+		{"path internal", CompositeConfig{Path: "/summary", Parts: []CompositePart{{Name: "x", Path: "/order/internal/update-status"}}}, false},
+		{"nama dobel", CompositeConfig{Path: "/summary", Parts: []CompositePart{{Name: "x", Path: "/order/a"}, {Name: "x", Path: "/order/b"}}}, false},
+		{"nama errors", CompositeConfig{Path: "/summary", Parts: []CompositePart{{Name: "errors", Path: "/order/a"}}}, false},
+	}
+	for _, tc := range cases {
+		if err := cfg.validateComposite(&tc.cc); (err == nil) != tc.ok {
+			t.Errorf("%s: err = %v", tc.name, err)
+		}
+	}
+}
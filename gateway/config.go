@@ -0,0 +1,512 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/goccy/go-yaml"
+)
+
+// Config adalah isi file konfigurasi gateway (YAML atau JSON).
+type Config struct {
+	Listen      string            `json:"listen"`
+	AdminListen string            `json:"admin_listen"` // endpoint admin, jangan dibuka ke publik
+	CORS        CORSConfig        `json:"cors"`
+	Auth        AuthConfig        `json:"auth"`
+	Redis       RedisConfig       `json:"redis"`
+	RateLimit   RateLimitConfig   `json:"rate_limit"`
+	Transport   TransportConfig   `json:"transport"`
+	Session     SessionConfig     `json:"session"`
+	Composites  []CompositeConfig `json:"composites"`
+
+	// IP proxy/load balancer di depan gateway yang boleh mengisi X-Forwarded-For.
+	// Kosong = IP client diambil dari koneksi langsung.
+	TrustedProxies []string `json:"trusted_proxies"`
+
+	// File aturan RBAC (lihat policy.go), relatif terhadap folder file config.
+	// Kosong = tanpa RBAC, semua token valid diterima.
+	PolicyFile string `json:"policy_file"`
+
+	Routes []RouteConfig `json:"routes"`
+
+	policy        *Policy
+	policyPath    string
+	policyModTime time.Time
+}
+
+// AuthConfig: verifikasi access token memakai public key dari JWKS auth-service.
+type AuthConfig struct {
+	JWKSURL    string   `json:"jwks_url"`
+	Algorithms []string `json:"algorithms"` // alg yang diterima, token dengan alg lain ditolak
+	CacheTTL   Duration `json:"cache_ttl"`
+
+	Revocation RevocationConfig `json:"revocation"`
+	APIKeys    APIKeyConfig     `json:"api_keys"`
+}
+
+// RevocationConfig: tolak access token yang ada di denylist Redis milik
+// auth-service. Hasil cek di-cache lokal selama CacheTTL.
+type RevocationConfig struct {
+	Enabled  bool     `json:"enabled"`
+	CacheTTL Duration `json:"cache_ttl"`
+}
+
+// SessionConfig: mode BFF (backend-for-frontend). Gateway yang login & refresh
+// ke auth-service dan menyimpan token di Redis; browser hanya memegang cookie
+// sesi HttpOnly plus token CSRF. Client yang mengirim header Authorization
+// sendiri tetap jalan seperti biasa.
+type SessionConfig struct {
+	Enabled    bool            `json:"enabled"`
+	AuthURL    string          `json:"auth_url"`    // base URL auth-service, misal http://localhost:8080
+	CookieName string          `json:"cookie_name"` // default "session"; cookie CSRF = <cookie_name>_csrf
+	Domain     string          `json:"domain"`
+	Secure     bool            `json:"secure"`    // wajib true kalau lewat HTTPS (production)
+	SameSite   string          `json:"same_site"` // lax (default) | strict
+	TTL        Duration        `json:"ttl"`       // umur sesi sejak refresh terakhir, default 7 hari
+	RateLimit  []RateLimitRule `json:"rate_limit"`
+}
+
+// APIKeyConfig: header X-API-Key sebagai pengganti Bearer JWT untuk client
+// mesin. Key di-resolve ke auth-service (butuh SERVICE_HMAC_KEY yang sama).
+type APIKeyConfig struct {
+	Enabled          bool     `json:"enabled"`
+	URL              string   `json:"url"`                // base URL auth-service
+	CacheTTL         Duration `json:"cache_ttl"`          // default 30s
+	DefaultRateLimit int      `json:"default_rate_limit"` // request per menit untuk key tanpa rate_limit, default 60
+	ResolveRateLimit int      `json:"resolve_rate_limit"` // resolve ke auth-service (cache miss) per IP per menit, default 30
+}
+
+type RedisConfig struct {
+	Addr     string `json:"addr"`
+	Password string `json:"password"`
+	DB       int    `json:"db"`
+}
+
+// RateLimitConfig memilih backend penyimpanan bucket: memory (satu instance)
+// atau redis (dibagi antar replica gateway, butuh redis.addr).
+type RateLimitConfig struct {
+	Backend string `json:"backend"`
+}
+
+// TransportConfig: pool koneksi gateway -> upstream, dipakai bersama oleh
+// semua route. Perubahan butuh restart gateway.
+type TransportConfig struct {
+	DialTimeout           Duration `json:"dial_timeout"`            // default 5s
+	KeepAlive             Duration `json:"keep_alive"`              // interval TCP keep-alive, default 30s
+	MaxIdleConns          int      `json:"max_idle_conns"`          // total koneksi idle, default 200
+	MaxIdleConnsPerHost   int      `json:"max_idle_conns_per_host"` // default 50
+	MaxConnsPerHost       int      `json:"max_conns_per_host"`      // 0 = tanpa batas
+	IdleConnTimeout       Duration `json:"idle_conn_timeout"`       // default 90s
+	ResponseHeaderTimeout Duration `json:"response_header_timeout"` // 0 = ikut options.timeout route
+	H2C                   bool     `json:"h2c"`                     // HTTP/2 tanpa TLS ke upstream http://
+}
+
+type CORSConfig struct {
+	AllowOrigins []string `json:"allow_origins"`
+}
+
+// RouteConfig: satu prefix path diteruskan ke pool berisi satu atau lebih upstream.
+type RouteConfig struct {
+	Prefix      string               `json:"prefix"`
+	Upstreams   []string             `json:"upstreams"`
+	Auth        bool                 `json:"auth"`     // true = lewat AuthMiddleware dulu
+	Balancer    string               `json:"balancer"` // round_robin (default) | least_conn
+	HealthCheck HealthCheckConfig    `json:"health_check"`
+	Passive     PassiveConfig        `json:"passive"`
+	Breaker     CircuitBreakerConfig `json:"circuit_breaker"`
+	RateLimit   []RateLimitRule      `json:"rate_limit"` // aturan pertama yang cocok dipakai
+	Stream      StreamConfig         `json:"stream"`
+	OpenAPI     OpenAPIConfig        `json:"openapi"`
+	Options     RouteOptions         `json:"options"`
+}
+
+// CompositeConfig: endpoint GET milik gateway yang memanggil beberapa path
+// route lain secara paralel dan menggabungkan hasilnya jadi satu JSON
+// ({"<name>": <response part>, ..., "errors": {...}}).
+type CompositeConfig struct {
+	Path    string          `json:"path"`
+	Timeout Duration        `json:"timeout"` // satu deadline untuk semua part, default 5s
+	Parts   []CompositePart `json:"parts"`
+}
+
+type CompositePart struct {
+	Name     string `json:"name"`     // key di response
+	Path     string `json:"path"`     // path gateway, misal /order/list
+	Required bool   `json:"required"` // part ini gagal = seluruh response gagal
+}
+
+// OpenAPIConfig: spec OpenAPI yang disajikan upstream route ini. Spec semua
+// route digabung di GET /openapi.json gateway; kalau Validate, request yang
+// tidak sesuai spec ditolak (400) sebelum sampai ke upstream.
+type OpenAPIConfig struct {
+	Path     string `json:"path"` // path spec di upstream, misal /openapi.json
+	Validate bool   `json:"validate"`
+}
+
+// StreamConfig: path koneksi panjang (SSE / WebSocket) di route ini. Path
+// stream tidak kena options.timeout, boleh auth lewat query ?access_token=,
+// dan diputus kalau tidak ada data selama IdleTimeout atau sudah MaxDuration
+// (atau access token-nya kedaluwarsa).
+type StreamConfig struct {
+	Paths       []string `json:"paths"`        // format sama dengan path di policy ("*", "/**")
+	IdleTimeout Duration `json:"idle_timeout"` // default 60s
+	MaxDuration Duration `json:"max_duration"` // default 1h
+}
+
+// HealthCheckConfig: probe HTTP aktif ke setiap upstream. Path kosong = nonaktif.
+// Upstream dianggap sehat kalau probe dijawab dengan status < 500.
+type HealthCheckConfig struct {
+	Path               string   `json:"path"`
+	Interval           Duration `json:"interval"`
+	Timeout            Duration `json:"timeout"`
+	HealthyThreshold   int      `json:"healthy_threshold"`
+	UnhealthyThreshold int      `json:"unhealthy_threshold"`
+}
+
+// PassiveConfig: upstream dikeluarkan sementara dari pool setelah MaxFailures
+// kali berturut-turut membalas 5xx atau gagal konek. 0 = nonaktif.
+type PassiveConfig struct {
+	MaxFailures  int      `json:"max_failures"`
+	EjectionTime Duration `json:"ejection_time"`
+}
+
+// CircuitBreakerConfig: breaker per upstream. FailureThreshold 0 = nonaktif.
+type CircuitBreakerConfig struct {
+	FailureThreshold int      `json:"failure_threshold"`  // kegagalan beruntun sampai open
+	Cooldown         Duration `json:"cooldown"`           // lama open sebelum half-open
+	HalfOpenRequests int      `json:"half_open_requests"` // request percobaan saat half-open
+}
+
+// RateLimitRule: token bucket berisi Burst token, diisi Requests token per Period.
+type RateLimitRule struct {
+	Path     string   `json:"path"`    // kosong = semua path di route
+	Methods  []string `json:"methods"` // kosong = semua method
+	Requests int      `json:"requests"`
+	Period   Duration `json:"period"`
+	Burst    int      `json:"burst"` // default = requests
+}
+
+// rate: token per detik.
+func (r *RateLimitRule) rate() float64 {
+	return float64(r.Requests) / time.Duration(r.Period).Seconds()
+}
+
+type RouteOptions struct {
+	Methods     []string `json:"methods"`      // kosong = semua method
+	StripPrefix bool     `json:"strip_prefix"` // "/order/list" -> "/list"
+	Timeout     Duration `json:"timeout"`      // 0 = tanpa batas
+}
+
+// Duration menerima format time.ParseDuration ("30s", "1m") di YAML/JSON.
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("durasi harus string, contoh \"30s\": %s", b)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// LoadConfig membaca file konfigurasi. Ekstensi .json dibaca sebagai JSON,
+// selain itu dianggap YAML.
+func LoadConfig(path string) (*Config, error) {
+	cfg := &Config{}
+	if err := decodeFile(path, cfg); err != nil {
+		return nil, err
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	if cfg.PolicyFile != "" {
+		cfg.policyPath = cfg.PolicyFile
+		if !filepath.IsAbs(cfg.policyPath) {
+			cfg.policyPath = filepath.Join(filepath.Dir(path), cfg.policyPath)
+		}
+		var err error
+		if cfg.policy, cfg.policyModTime, err = LoadPolicy(cfg.policyPath); err != nil {
+			return nil, err
+		}
+	}
+	return cfg, nil
+}
+
+// decodeFile membaca file YAML/JSON ke v. Field yang tidak dikenal = error.
+func decodeFile(path string, v any) error {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	if strings.ToLower(filepath.Ext(path)) != ".json" {
+		raw, err = yaml.YAMLToJSON(raw)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields() // typo di config lebih baik error daripada diam-diam diabaikan
+	if err := dec.Decode(v); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+func (cfg *Config) validate() error {
+	if cfg.Listen == "" {
+		cfg.Listen = ":8000"
+	}
+	if cfg.AdminListen == "" {
+		cfg.AdminListen = "127.0.0.1:8001"
+	}
+	if cfg.Auth.JWKSURL == "" {
+		return fmt.Errorf("auth.jwks_url wajib diisi")
+	}
+	if len(cfg.Auth.Algorithms) == 0 {
+		cfg.Auth.Algorithms = []string{"EdDSA", "RS256"}
+	}
+	for _, alg := range cfg.Auth.Algorithms {
+		if alg != "EdDSA" && alg != "RS256" {
+			return fmt.Errorf("auth.algorithms: %q tidak didukung", alg)
+		}
+	}
+	if cfg.Auth.CacheTTL == 0 {
+		cfg.Auth.CacheTTL = Duration(5 * time.Minute)
+	}
+	if cfg.Auth.Revocation.Enabled {
+		if cfg.Redis.Addr == "" {
+			return fmt.Errorf("auth.revocation butuh redis.addr")
+		}
+		if cfg.Auth.Revocation.CacheTTL == 0 {
+			cfg.Auth.Revocation.CacheTTL = Duration(5 * time.Second)
+		}
+	}
+	if ak := &cfg.Auth.APIKeys; ak.Enabled {
+		if u, err := url.Parse(ak.URL); err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("auth.api_keys.url %q tidak valid", ak.URL)
+		}
+		ak.URL = strings.TrimSuffix(ak.URL, "/")
+		if ak.CacheTTL == 0 {
+			ak.CacheTTL = Duration(30 * time.Second)
+		}
+		if ak.DefaultRateLimit == 0 {
+			ak.DefaultRateLimit = 60
+		}
+		if ak.ResolveRateLimit == 0 {
+			ak.ResolveRateLimit = 30
+		}
+	}
+
+	switch cfg.RateLimit.Backend {
+	case "":
+		cfg.RateLimit.Backend = RateLimitMemory
+	case RateLimitMemory:
+	case RateLimitRedis:
+		if cfg.Redis.Addr == "" {
+			return fmt.Errorf("rate_limit.backend redis butuh redis.addr")
+		}
+	default:
+		return fmt.Errorf("rate_limit.backend %q tidak dikenal", cfg.RateLimit.Backend)
+	}
+	cfg.Transport.setDefaults()
+	if err := cfg.Session.validate(cfg.Redis); err != nil {
+		return err
+	}
+	if len(cfg.Routes) == 0 {
+		return fmt.Errorf("routes kosong")
+	}
+
+	for i := range cfg.Routes {
+		rt := &cfg.Routes[i]
+		if !strings.HasPrefix(rt.Prefix, "/") || strings.HasSuffix(rt.Prefix, "/") {
+			return fmt.Errorf("routes[%d]: prefix %q harus diawali '/' dan tanpa '/' di akhir", i, rt.Prefix)
+		}
+		if len(rt.Upstreams) == 0 {
+			return fmt.Errorf("routes[%d] (%s): upstreams kosong", i, rt.Prefix)
+		}
+		for _, u := range rt.Upstreams {
+			parsed, err := url.Parse(u)
+			if err != nil || parsed.Scheme == "" || parsed.Host == "" {
+				return fmt.Errorf("routes[%d] (%s): upstream %q tidak valid", i, rt.Prefix, u)
+			}
+		}
+		switch rt.Balancer {
+		case "":
+			rt.Balancer = BalancerRoundRobin
+		case BalancerRoundRobin, BalancerLeastConn:
+		default:
+			return fmt.Errorf("routes[%d] (%s): balancer %q tidak dikenal", i, rt.Prefix, rt.Balancer)
+		}
+		rt.HealthCheck.setDefaults()
+		if rt.Passive.MaxFailures > 0 && rt.Passive.EjectionTime == 0 {
+			rt.Passive.EjectionTime = Duration(30 * time.Second)
+		}
+		if rt.Breaker.FailureThreshold > 0 {
+			if rt.Breaker.Cooldown == 0 {
+				rt.Breaker.Cooldown = Duration(30 * time.Second)
+			}
+			if rt.Breaker.HalfOpenRequests == 0 {
+				rt.Breaker.HalfOpenRequests = 1
+			}
+		}
+		if err := validateRateLimit(rt.RateLimit); err != nil {
+			return fmt.Errorf("routes[%d] (%s): %w", i, rt.Prefix, err)
+		}
+		if len(rt.Stream.Paths) > 0 {
+			for _, p := range rt.Stream.Paths {
+				if !strings.HasPrefix(p, rt.Prefix+"/") {
+					return fmt.Errorf("routes[%d] (%s): stream path %q harus di dalam prefix route", i, rt.Prefix, p)
+				}
+			}
+			if rt.Stream.IdleTimeout == 0 {
+				rt.Stream.IdleTimeout = Duration(time.Minute)
+			}
+			if rt.Stream.MaxDuration == 0 {
+				rt.Stream.MaxDuration = Duration(time.Hour)
+			}
+		}
+		if rt.OpenAPI.Validate && rt.OpenAPI.Path == "" {
+			return fmt.Errorf("routes[%d] (%s): openapi.validate butuh openapi.path", i, rt.Prefix)
+		}
+		for j, m := range rt.Options.Methods {
+			rt.Options.Methods[j] = strings.ToUpper(m)
+		}
+	}
+	for i := range cfg.Composites {
+		if err := cfg.validateComposite(&cfg.Composites[i]); err != nil {
+			return fmt.Errorf("composites[%d] (%s): %w", i, cfg.Composites[i].Path, err)
+		}
+	}
+	return nil
+}
+
+func (cfg *Config) validateComposite(cc *CompositeConfig) error {
+	if !strings.HasPrefix(cc.Path, "/") || hasInternalSegment(cc.Path) {
+		return fmt.Errorf("path tidak valid")
+	}
+	if len(cc.Parts) == 0 {
+		return fmt.Errorf("parts kosong")
+	}
+	if cc.Timeout == 0 {
+		cc.Timeout = Duration(5 * time.Second)
+	}
+	names := map[string]bool{"errors": true} // dipakai untuk daftar part yang gagal
+	for _, part := range cc.Parts {
+		if part.Name == "" || names[part.Name] {
+			return fmt.Errorf("nama part %q kosong atau dobel", part.Name)
+		}
+		names[part.Name] = true
+		// Part harus milik route biasa, bukan composite lain (mencegah loop)
+		routed := false
+		for _, rt := range cfg.Routes {
+			if strings.HasPrefix(part.Path, rt.Prefix+"/") {
+				routed = true
+			}
+		}
+		if !routed || hasInternalSegment(part.Path) {
+			return fmt.Errorf("part %s: path %q bukan milik route mana pun", part.Name, part.Path)
+		}
+	}
+	return nil
+}
+
+func validateRateLimit(rules []RateLimitRule) error {
+	for j := range rules {
+		rule := &rules[j]
+		if rule.Requests <= 0 || rule.Period <= 0 {
+			return fmt.Errorf("rate_limit[%d] butuh requests dan period", j)
+		}
+		if rule.Burst == 0 {
+			rule.Burst = rule.Requests
+		}
+		for k, m := range rule.Methods {
+			rule.Methods[k] = strings.ToUpper(m)
+		}
+	}
+	return nil
+}
+
+func (sc *SessionConfig) validate(redis RedisConfig) error {
+	if !sc.Enabled {
+		return nil
+	}
+	if redis.Addr == "" {
+		return fmt.Errorf("session butuh redis.addr")
+	}
+	if u, err := url.Parse(sc.AuthURL); err != nil || u.Scheme == "" || u.Host == "" {
+		return fmt.Errorf("session.auth_url %q tidak valid", sc.AuthURL)
+	}
+	sc.AuthURL = strings.TrimSuffix(sc.AuthURL, "/")
+	if sc.CookieName == "" {
+		sc.CookieName = "session"
+	}
+	switch strings.ToLower(sc.SameSite) {
+	case "", "lax":
+		sc.SameSite = "lax"
+	case "strict":
+		sc.SameSite = "strict"
+	default:
+		// SameSite=None membuat cookie ikut di request lintas situs, tidak didukung
+		return fmt.Errorf("session.same_site %q tidak didukung (lax | strict)", sc.SameSite)
+	}
+	if sc.TTL == 0 {
+		sc.TTL = Duration(7 * 24 * time.Hour)
+	}
+	if err := validateRateLimit(sc.RateLimit); err != nil {
+		return fmt.Errorf("session: %w", err)
+	}
+	return nil
+}
+
+func (tc *TransportConfig) setDefaults() {
+	if tc.DialTimeout == 0 {
+		tc.DialTimeout = Duration(5 * time.Second)
+	}
+	if tc.KeepAlive == 0 {
+		tc.KeepAlive = Duration(30 * time.Second)
+	}
+	if tc.MaxIdleConns == 0 {
+		tc.MaxIdleConns = 200
+	}
+	if tc.MaxIdleConnsPerHost == 0 {
+		tc.MaxIdleConnsPerHost = 50
+	}
+	if tc.IdleConnTimeout == 0 {
+		tc.IdleConnTimeout = Duration(90 * time.Second)
+	}
+}
+
+func (hc *HealthCheckConfig) setDefaults() {
+	if hc.Path == "" {
+		return
+	}
+	if hc.Interval == 0 {
+		hc.Interval = Duration(5 * time.Second)
+	}
+	if hc.Timeout == 0 {
+		hc.Timeout = Duration(2 * time.Second)
+	}
+	if hc.HealthyThreshold == 0 {
+		hc.HealthyThreshold = 2
+	}
+	if hc.UnhealthyThreshold == 0 {
+		hc.UnhealthyThreshold = 3
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const baseConfig = `
+auth:
+  jwks_url: http://auth.test/.well-known/jwks.json
+routes:
+  - prefix: /order
+    upstreams: [http://order.test]
+`
+
+func writeConfig(t *testing.T, dir, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, "gateway.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfig(t *testing.T) {
+	cases := []struct {
+		name    string
+		config  string
+		wantErr string // kosong = valid
+	}{
+		{"minimal", baseConfig, ""},
+		{"tanpa jwks_url", "routes:\n  - prefix: /order\n    upstreams: [http://order.test]\n", "auth.jwks_url wajib diisi"},
+		{"routes kosong", "auth:\n  jwks_url: http://auth.test\nroutes: []\n", "routes kosong"},
+		{"field tidak dikenal", baseConfig + "listn: :9000\n", "unknown field"},
+		{"alg tidak didukung", strings.Replace(baseConfig, "auth:\n", "auth:\n  algorithms: [HS256]\n", 1), "HS256"},
+		{"prefix tanpa slash", strings.Replace(baseConfig, "prefix: /order", "prefix: order", 1), "prefix \"order\""},
+		{"prefix slash di akhir", strings.Replace(baseConfig, "prefix: /order", "prefix: /order/", 1), "prefix \"/order/\""},
+		{"upstream tidak valid", strings.Replace(baseConfig, "http://order.test", "order.test", 1), "upstream \"order.test\" tidak valid"},
+		{"upstreams kosong", strings.Replace(baseConfig, "[http://order.test]", "[]", 1), "upstreams kosong"},
+		{"balancer tidak dikenal", baseConfig + "    balancer: random\n", "balancer \"random\""},
+		{"revocation tanpa redis", strings.Replace(baseConfig, "auth:\n", "auth:\n  revocation:\n    enabled: true\n", 1), "auth.revocation butuh redis.addr"},
+		{"rate limit redis tanpa redis", baseConfig + "rate_limit:\n  backend: redis\n", "butuh redis.addr"},
+		{"durasi bukan string", baseConfig + "    options:\n      timeout: 30\n", "durasi harus string"},
+		{"stream di luar prefix", baseConfig + "    stream:\n      paths: [/payment/stream]\n", "harus di dalam prefix"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg, err := LoadConfig(writeConfig(t, t.TempDir(), tc.config))
+			if tc.wantErr == "" {
+				if err != nil {
+					t.Fatalf("config valid ditolak: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("error = %v, want mengandung %q (cfg %+v)", err, tc.wantErr, cfg)
+			}
+		})
+	}
+}
+
+func TestLoadConfigDefaults(t *testing.T) {
+	cfg, err := LoadConfig(writeConfig(t, t.TempDir(), baseConfig+"    stream:\n      paths: [/order/stream]\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Listen != ":8000" || cfg.AdminListen != "127.0.0.1:8001" {
+		t.Errorf("listen = %q / %q", cfg.Listen, cfg.AdminListen)
+	}
+	if cfg.RateLimit.Backend != RateLimitMemory || len(cfg.Auth.Algorithms) != 2 {
+		t.Errorf("rate_limit.backend = %q, algorithms = %v", cfg.RateLimit.Backend, cfg.Auth.Algorithms)
+	}
+	rt := cfg.Routes[0]
+	if rt.Balancer != BalancerRoundRobin || rt.Stream.IdleTimeout != Duration(time.Minute) || rt.Stream.MaxDuration != Duration(time.Hour) {
+		t.Errorf("default route = %+v", rt)
+	}
+}
+
+// Config baru yang invalid tidak mengganti router yang sedang jalan, dan
+// tidak di-parse ulang terus selama filenya belum diubah.
+func TestReloadInvalidKeepsOldRouter(t *testing.T) {
+	t.Setenv("IDENTITY_SIGNING_KEY", "test")
+	upstream := func(body string) *httptest.Server {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.Write([]byte(body))
+		}))
+		t.Cleanup(srv.Close)
+		return srv
+	}
+	v1, v2 := upstream("v1"), upstream("v2")
+	config := func(up string) string {
+		return strings.Replace(baseConfig, "http://order.test", up, 1) + "    auth: false\n"
+	}
+
+	dir := t.TempDir()
+	path := writeConfig(t, dir, config(v1.URL))
+	g, err := NewGateway(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(g.Close)
+	gw := httptest.NewServer(g)
+	t.Cleanup(gw.Close)
+	get := func() string {
+		resp, err := http.Get(gw.URL + "/order/list")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return string(body)
+	}
+	if got := get(); got != "v1" {
+		t.Fatalf("response = %q, want v1", got)
+	}
+
+	// mtime dimajukan supaya perubahan terdeteksi walau resolusi jam kasar
+	touch := func(at time.Time) {
+		if err := os.Chtimes(path, at, at); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeConfig(t, dir, "auth:\n  jwks_url: http://auth.test\nroutes: []\n")
+	touch(time.Now().Add(time.Minute))
+	if !g.filesChanged() {
+		t.Fatal("perubahan file tidak terdeteksi")
+	}
+	if err := g.Reload(); err == nil {
+		t.Fatal("reload config invalid harus error")
+	}
+	if got := get(); got != "v1" {
+		t.Fatalf("setelah reload gagal response = %q, want router lama (v1)", got)
+	}
+	if g.filesChanged() {
+		t.Fatal("file invalid yang sama akan di-parse ulang tiap interval")
+	}
+
+	writeConfig(t, dir, config(v2.URL))
+	touch(time.Now().Add(2 * time.Minute))
+	if !g.filesChanged() {
+		t.Fatal("file yang sudah diperbaiki tidak terdeteksi")
+	}
+	if err := g.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if got := get(); got != "v2" {
+		t.Fatalf("setelah reload response = %q, want v2", got)
+	}
+}
@@ -0,0 +1,348 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httputil"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"shared/identity"
+	"shared/logging"
+	"shared/metrics"
+	"shared/svcauth"
+	"shared/tracing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/extra/redisotel/v9"
+	"github.com/redis/go-redis/v9"
+)
+
+// Gateway memegang router aktif. Saat config di-reload, router baru dibangun
+// lalu ditukar secara atomik: request yang sedang berjalan tetap selesai di
+// router lama, request berikutnya masuk ke router baru.
+type Gateway struct {
+	path    string
+	handler atomic.Pointer[router]
+	config  atomic.Pointer[Config]
+
+	mu       sync.Mutex           // serialisasi Reload (SIGHUP & polling bisa bareng)
+	modTimes map[string]time.Time // file config & policy yang dipantau Watch
+
+	// Dibuat sekali saat start dan dipakai lintas reload, supaya counter
+	// rate limit tidak ikut ter-reset.
+	rdb       *redis.Client
+	limiter   Limiter
+	jwks      *JWKSCache
+	denylist  *Denylist
+	apiKeys   *APIKeys
+	transport http.RoundTripper // koneksi ke upstream, dipakai bersama semua route
+
+	// Dibatalkan saat shutdown supaya koneksi stream (SSE/WebSocket) yang
+	// bisa berumur berjam-jam tidak menahan drain.
+	streams      context.Context
+	closeStreams context.CancelFunc
+
+	identityKey []byte // IDENTITY_SIGNING_KEY, untuk assertion ke microservice
+}
+
+func NewGateway(path string) (*Gateway, error) {
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		return nil, err
+	}
+
+	g := &Gateway{path: path, identityKey: identity.KeyFromEnv()}
+	if len(g.identityKey) == 0 {
+		return nil, fmt.Errorf("IDENTITY_SIGNING_KEY wajib diisi")
+	}
+	if cfg.Redis.Addr != "" {
+		g.rdb = redis.NewClient(&redis.Options{
+			Addr:     cfg.Redis.Addr,
+			Password: cfg.Redis.Password,
+			DB:       cfg.Redis.DB,
+		})
+		if err := g.rdb.Ping(context.Background()).Err(); err != nil {
+			return nil, fmt.Errorf("redis %s: %w", cfg.Redis.Addr, err)
+		}
+		if err := redisotel.InstrumentTracing(g.rdb); err != nil {
+			return nil, err
+		}
+		slog.Info("connected to Redis", "addr", cfg.Redis.Addr)
+	}
+
+	if cfg.RateLimit.Backend == RateLimitRedis {
+		g.limiter = NewRedisLimiter(g.rdb)
+	} else {
+		g.limiter = NewMemoryLimiter()
+	}
+	g.transport = newTransport(cfg.Transport)
+	g.streams, g.closeStreams = context.WithCancel(context.Background())
+	if cfg.Auth.Revocation.Enabled {
+		g.denylist = NewDenylist(g.rdb, time.Duration(cfg.Auth.Revocation.CacheTTL))
+	}
+	if cfg.Auth.APIKeys.Enabled {
+		key := svcauth.KeyFromEnv()
+		if len(key) == 0 {
+			return nil, fmt.Errorf("auth.api_keys butuh SERVICE_HMAC_KEY")
+		}
+		g.apiKeys = NewAPIKeys(cfg.Auth.APIKeys, key, g.limiter)
+	}
+
+	if err := g.Reload(); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+func (g *Gateway) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	g.handler.Load().ServeHTTP(w, req)
+}
+
+func (g *Gateway) Config() *Config {
+	return g.config.Load()
+}
+
+// CloseStreams memutus semua koneksi stream yang sedang diteruskan. Dipasang
+// lewat http.Server.RegisterOnShutdown; client akan connect ulang.
+func (g *Gateway) CloseStreams() {
+	g.closeStreams()
+}
+
+// Close menghentikan health check upstream dan koneksi Redis. Dipanggil
+// setelah server selesai drain.
+func (g *Gateway) Close() {
+	g.closeStreams()
+	g.handler.Load().close()
+	if g.rdb != nil {
+		g.rdb.Close()
+	}
+}
+
+// Reload membaca ulang file config. Kalau config baru invalid, router lama
+// tetap dipakai.
+func (g *Gateway) Reload() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	info, err := os.Stat(g.path)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := LoadConfig(g.path)
+	if err != nil {
+		g.markSeen()
+		return err
+	}
+
+	if g.jwks == nil || g.jwks.url != cfg.Auth.JWKSURL || g.jwks.ttl != time.Duration(cfg.Auth.CacheTTL) {
+		g.jwks = NewJWKSCache(cfg.Auth.JWKSURL, time.Duration(cfg.Auth.CacheTTL))
+	}
+
+	r, err := g.buildRouter(cfg)
+	if err != nil {
+		g.markSeen()
+		return err
+	}
+
+	if old := g.config.Load(); old != nil {
+		if old.Listen != cfg.Listen || old.AdminListen != cfg.AdminListen {
+			slog.Warn("listen/admin_listen berubah, butuh restart gateway")
+		}
+		if old.Redis != cfg.Redis || old.RateLimit != cfg.RateLimit || old.Auth.Revocation != cfg.Auth.Revocation ||
+			old.Auth.APIKeys != cfg.Auth.APIKeys || old.Transport != cfg.Transport {
+			slog.Warn("redis/rate_limit/auth.revocation/auth.api_keys/transport berubah, butuh restart gateway")
+		}
+	}
+
+	r.start()
+	if old := g.handler.Swap(r); old != nil {
+		// Request lama yang masih jalan tetap bisa pakai pool lama,
+		// yang berhenti hanya health check-nya.
+		old.close()
+	}
+	g.config.Store(cfg)
+	g.modTimes = map[string]time.Time{g.path: info.ModTime()}
+	if cfg.policyPath != "" {
+		g.modTimes[cfg.policyPath] = cfg.policyModTime
+	}
+	return nil
+}
+
+// Watch me-reload config saat menerima SIGHUP atau saat file config/policy
+// berubah (dicek tiap interval).
+func (g *Gateway) Watch(interval time.Duration) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-hup:
+			g.reloadAndLog("SIGHUP")
+		case <-ticker.C:
+			if g.filesChanged() {
+				g.reloadAndLog("file berubah")
+			}
+		}
+	}
+}
+
+// markSeen mencatat mtime file yang sekarang walaupun reload gagal, supaya
+// file yang invalid tidak di-parse ulang (dan error-nya di-log) tiap interval.
+// Reload berikutnya terjadi saat file diubah lagi atau SIGHUP.
+func (g *Gateway) markSeen() {
+	for path := range g.modTimes {
+		if info, err := os.Stat(path); err == nil {
+			g.modTimes[path] = info.ModTime()
+		}
+	}
+}
+
+func (g *Gateway) filesChanged() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for path, modTime := range g.modTimes {
+		if info, err := os.Stat(path); err == nil && !info.ModTime().Equal(modTime) {
+			return true
+		}
+	}
+	return false
+}
+
+func (g *Gateway) reloadAndLog(reason string) {
+	if err := g.Reload(); err != nil {
+		slog.Error("reload config gagal, tetap pakai config lama", "reason", reason, "error", err)
+		return
+	}
+	slog.Info("config di-reload", "reason", reason, "routes", len(g.Config().Routes))
+}
+
+// router adalah hasil build satu versi config: gin.Engine beserta route
+// (dan pool upstream-nya).
+type router struct {
+	*gin.Engine
+	routes []*route
+}
+
+func (r *router) start() {
+	for _, rt := range r.routes {
+		rt.pool.Start()
+	}
+}
+
+func (r *router) close() {
+	for _, rt := range r.routes {
+		rt.pool.Close()
+	}
+}
+
+// route adalah RouteConfig yang sudah di-parse dan siap dipakai proxyRequest.
+type route struct {
+	RouteConfig
+	pool    *Pool
+	proxy   *httputil.ReverseProxy
+	streams context.Context // lihat Gateway.streams
+	spec    *routeSpec      // nil kalau route tidak punya openapi.path
+}
+
+// buildRouter menyusun router baru dari Config. gin panic kalau ada path
+// yang bentrok, jadi panic diubah jadi error supaya reload tidak mematikan gateway.
+func (g *Gateway) buildRouter(cfg *Config) (r *router, err error) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			err = fmt.Errorf("route tidak valid: %v", rec)
+		}
+	}()
+
+	r = &router{Engine: gin.New()}
+	if err := r.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		return nil, err
+	}
+	trusted, err := parseTrustedProxies(cfg.TrustedProxies)
+	if err != nil {
+		return nil, err
+	}
+	r.Use(gin.Recovery(), tracing.Middleware("gateway"), logging.Middleware(), metrics.Middleware())
+	r.Use(CORSMiddleware(cfg.CORS), CanonicalPathMiddleware(), BlockInternalMiddleware(), StripIdentityMiddleware())
+
+	var sessions *Sessions
+	if cfg.Session.Enabled {
+		if g.rdb == nil {
+			return nil, fmt.Errorf("session butuh Redis, restart gateway setelah mengisi redis.addr")
+		}
+		sessions = NewSessions(cfg.Session, cfg.CORS.AllowOrigins, g.rdb, g.transport)
+		rt := &route{RouteConfig: RouteConfig{Prefix: "/session", RateLimit: cfg.Session.RateLimit}}
+		sr := r.Group("/session", RateLimitMiddleware(g.limiter, rt))
+		sr.GET("", sessions.Current)
+		sr.POST("/login", sessions.Login)
+		sr.POST("/login/2fa", sessions.Login2FA)
+		sr.POST("/logout", sessions.Logout)
+	}
+
+	for _, rc := range cfg.Routes {
+		pool, err := NewPool(rc)
+		if err != nil {
+			return nil, err
+		}
+		rt := &route{RouteConfig: rc, pool: pool, streams: g.streams}
+		rt.proxy = newRouteProxy(rt, g.transport, trusted)
+		if rc.OpenAPI.Path != "" {
+			rt.spec = newRouteSpec(rt, g.transport)
+		}
+		r.routes = append(r.routes, rt)
+
+		var handlers []gin.HandlerFunc
+		if rt.Options.Timeout > 0 {
+			handlers = append(handlers, TimeoutMiddleware(rt))
+		}
+		if rt.Auth && len(rt.Stream.Paths) > 0 {
+			handlers = append(handlers, StreamTokenMiddleware(rt))
+		}
+		if sessions != nil {
+			// Juga di route tanpa auth, supaya endpoint seperti /auth/me
+			// tetap dapat Bearer dari sesi
+			handlers = append(handlers, sessions.Middleware())
+		}
+		if rt.Auth {
+			handlers = append(handlers, AuthMiddleware(g.jwks, cfg.Auth.Algorithms, g.denylist, g.apiKeys, g.identityKey))
+			if g.apiKeys != nil {
+				handlers = append(handlers, APIKeyRateLimitMiddleware(g.limiter, cfg.Auth.APIKeys.DefaultRateLimit))
+			}
+			if cfg.policy != nil {
+				handlers = append(handlers, PolicyMiddleware(cfg.policy))
+			}
+		}
+		if len(rt.RateLimit) > 0 {
+			// Setelah AuthMiddleware supaya bisa dihitung per user
+			handlers = append(handlers, RateLimitMiddleware(g.limiter, rt))
+		}
+		if rt.OpenAPI.Validate {
+			handlers = append(handlers, ValidateMiddleware(rt))
+		}
+		handlers = append(handlers, proxyRequest(rt))
+
+		path := rt.Prefix + "/*proxyPath"
+		if len(rt.Options.Methods) == 0 {
+			r.Any(path, handlers...)
+			continue
+		}
+		for _, m := range rt.Options.Methods {
+			r.Handle(m, path, handlers...)
+		}
+	}
+	for _, cc := range cfg.Composites {
+		r.GET(cc.Path, compositeHandler(cc, r.Engine))
+	}
+	r.GET("/openapi.json", openAPIHandler(r.routes))
+	return r, nil
+}
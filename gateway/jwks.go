@@ -0,0 +1,167 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"shared/tracing"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// jwksMinRefresh: jarak minimal antar fetch saat ada kid yang belum dikenal,
+// supaya token dengan kid ngawur tidak bisa membanjiri auth-service.
+const jwksMinRefresh = 10 * time.Second
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+type publicKey struct {
+	alg string
+	key any
+}
+
+// JWKSCache mengambil public key auth-service dari endpoint JWKS dan
+// menyimpannya selama TTL. Kid baru (hasil rotasi) memicu fetch ulang.
+type JWKSCache struct {
+	url    string
+	ttl    time.Duration
+	client *http.Client
+
+	mu          sync.RWMutex
+	keys        map[string]publicKey
+	fetchedAt   time.Time
+	lastAttempt time.Time
+}
+
+func NewJWKSCache(url string, ttl time.Duration) *JWKSCache {
+	return &JWKSCache{
+		url:    url,
+		ttl:    ttl,
+		client: &http.Client{Timeout: 5 * time.Second, Transport: tracing.Transport(nil)},
+		keys:   map[string]publicKey{},
+	}
+}
+
+// Keyfunc untuk jwt.Parse: cari public key sesuai header kid dan pastikan
+// alg token sama dengan alg kunci tersebut.
+func (j *JWKSCache) Keyfunc(token *jwt.Token) (any, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		return nil, fmt.Errorf("token tanpa kid")
+	}
+
+	key, ok, stale := j.lookup(kid)
+	switch {
+	case !ok:
+		if err := j.refresh(); err != nil {
+			slog.Warn("fetch JWKS gagal", "url", j.url, "error", err)
+		}
+		if key, ok, _ = j.lookup(kid); !ok {
+			return nil, fmt.Errorf("kid %q tidak dikenal", kid)
+		}
+	case stale:
+		// Cache kedaluwarsa: kunci lama tetap dipakai sambil fetch di background
+		go func() {
+			if err := j.refresh(); err != nil {
+				slog.Warn("fetch JWKS gagal", "url", j.url, "error", err)
+			}
+		}()
+	}
+
+	if token.Method.Alg() != key.alg {
+		return nil, fmt.Errorf("alg %s tidak cocok dengan kunci %s (%s)", token.Method.Alg(), kid, key.alg)
+	}
+	return key.key, nil
+}
+
+func (j *JWKSCache) lookup(kid string) (key publicKey, ok, stale bool) {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	key, ok = j.keys[kid]
+	return key, ok, time.Since(j.fetchedAt) > j.ttl
+}
+
+// refresh mengambil JWKS terbaru, paling sering sekali per jwksMinRefresh.
+func (j *JWKSCache) refresh() error {
+	j.mu.Lock()
+	if time.Since(j.lastAttempt) < jwksMinRefresh {
+		j.mu.Unlock()
+		return nil
+	}
+	j.lastAttempt = time.Now()
+	j.mu.Unlock()
+
+	resp, err := j.client.Get(j.url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", j.url, resp.Status)
+	}
+
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return err
+	}
+
+	keys := make(map[string]publicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		pub, err := k.publicKey()
+		if err != nil {
+			slog.Warn("JWK dilewati", "kid", k.Kid, "error", err)
+			continue
+		}
+		keys[k.Kid] = publicKey{alg: k.Alg, key: pub}
+	}
+
+	j.mu.Lock()
+	j.keys = keys
+	j.fetchedAt = time.Now()
+	j.mu.Unlock()
+	return nil
+}
+
+func (k jwk) publicKey() (any, error) {
+	switch {
+	case k.Kty == "RSA" && k.Alg == "RS256":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case k.Kty == "OKP" && k.Crv == "Ed25519" && k.Alg == "EdDSA":
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("panjang kunci Ed25519 salah")
+		}
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, fmt.Errorf("kty/alg %s/%s tidak didukung", k.Kty, k.Alg)
+	}
+}
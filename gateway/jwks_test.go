@@ -0,0 +1,151 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// fakeJWKS: endpoint JWKS yang isinya bisa diganti (simulasi rotasi kunci).
+type fakeJWKS struct {
+	mu      sync.Mutex
+	keys    []jwk
+	fetches atomic.Int32
+}
+
+func (f *fakeJWKS) set(keys ...jwk) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.keys = keys
+}
+
+func (f *fakeJWKS) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	f.fetches.Add(1)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	json.NewEncoder(w).Encode(map[string]any{"keys": f.keys})
+}
+
+func rsaJWK(kid string, pub *rsa.PublicKey) jwk {
+	return jwk{
+		Kty: "RSA", Kid: kid, Alg: "RS256",
+		N: base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+		E: base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+	}
+}
+
+func ed25519JWK(kid string, pub ed25519.PublicKey) jwk {
+	return jwk{Kty: "OKP", Kid: kid, Alg: "EdDSA", Crv: "Ed25519", X: base64.RawURLEncoding.EncodeToString(pub)}
+}
+
+func signToken(t *testing.T, method jwt.SigningMethod, kid string, key any) string {
+	t.Helper()
+	token := jwt.NewWithClaims(method, jwt.MapClaims{"sub": float64(7), "exp": time.Now().Add(time.Minute).Unix()})
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	s, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func parseWith(cache *JWKSCache, token string) error {
+	_, err := jwt.Parse(token, cache.Keyfunc,
+		jwt.WithValidMethods([]string{"RS256", "EdDSA"}),
+		jwt.WithExpirationRequired(),
+	)
+	return err
+}
+
+func TestJWKSVerify(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	edPub, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	fake := &fakeJWKS{}
+	fake.set(rsaJWK("rsa-1", &rsaKey.PublicKey), ed25519JWK("ed-1", edPub))
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+	cache := NewJWKSCache(srv.URL, time.Hour)
+
+	if err := parseWith(cache, signToken(t, jwt.SigningMethodRS256, "rsa-1", rsaKey)); err != nil {
+		t.Fatalf("RS256 valid ditolak: %v", err)
+	}
+	if err := parseWith(cache, signToken(t, jwt.SigningMethodEdDSA, "ed-1", edKey)); err != nil {
+		t.Fatalf("EdDSA valid ditolak: %v", err)
+	}
+
+	// alg token harus sama dengan alg kunci milik kid
+	if err := parseWith(cache, signToken(t, jwt.SigningMethodEdDSA, "rsa-1", edKey)); err == nil {
+		t.Fatal("token EdDSA dengan kid kunci RSA harus ditolak")
+	}
+	// HS256 memakai public key sebagai secret
+	if err := parseWith(cache, signToken(t, jwt.SigningMethodHS256, "rsa-1", []byte(fake.keys[0].N))); err == nil {
+		t.Fatal("token HS256 harus ditolak")
+	}
+	if err := parseWith(cache, signToken(t, jwt.SigningMethodRS256, "", rsaKey)); err == nil {
+		t.Fatal("token tanpa kid harus ditolak")
+	}
+	if err := parseWith(cache, signToken(t, jwt.SigningMethodRS256, "tidak-ada", rsaKey)); err == nil {
+		t.Fatal("kid tidak dikenal harus ditolak")
+	}
+
+	// Kunci lain dengan kid yang benar: signature tidak cocok
+	other, _ := rsa.GenerateKey(rand.Reader, 2048)
+	if err := parseWith(cache, signToken(t, jwt.SigningMethodRS256, "rsa-1", other)); err == nil {
+		t.Fatal("signature dari kunci lain harus ditolak")
+	}
+}
+
+func TestJWKSRefreshOnUnknownKid(t *testing.T) {
+	oldKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	newKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	fake := &fakeJWKS{}
+	fake.set(rsaJWK("k1", &oldKey.PublicKey))
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+	cache := NewJWKSCache(srv.URL, time.Hour)
+
+	if err := parseWith(cache, signToken(t, jwt.SigningMethodRS256, "k1", oldKey)); err != nil {
+		t.Fatal(err)
+	}
+	if err := parseWith(cache, signToken(t, jwt.SigningMethodRS256, "k1", oldKey)); err != nil {
+		t.Fatal(err)
+	}
+	if n := fake.fetches.Load(); n != 1 {
+		t.Fatalf("fetch = %d, want 1 (kunci dari cache)", n)
+	}
+
+	// Rotasi: kid baru memicu fetch ulang (setelah jwksMinRefresh lewat)
+	fake.set(rsaJWK("k1", &oldKey.PublicKey), rsaJWK("k2", &newKey.PublicKey))
+	cache.mu.Lock()
+	cache.lastAttempt = time.Now().Add(-jwksMinRefresh)
+	cache.mu.Unlock()
+	if err := parseWith(cache, signToken(t, jwt.SigningMethodRS256, "k2", newKey)); err != nil {
+		t.Fatalf("kid hasil rotasi ditolak: %v", err)
+	}
+	if n := fake.fetches.Load(); n != 2 {
+		t.Fatalf("fetch = %d, want 2", n)
+	}
+
+	// Kid ngawur berturut-turut tidak membanjiri auth-service
+	for range 5 {
+		if err := parseWith(cache, signToken(t, jwt.SigningMethodRS256, "ngawur", newKey)); err == nil {
+			t.Fatal("kid tidak dikenal harus ditolak")
+		}
+	}
+	if n := fake.fetches.Load(); n != 2 {
+		t.Fatalf("fetch = %d, want tetap 2 dalam jwksMinRefresh", n)
+	}
+}
@@ -2,93 +2,221 @@
 package main
 
 import (
-	"fmt"
-	"log"
+	"context"
+	"log/slog"
 	"net/http"
-	"net/http/httputil"
-	"net/url"
 	"os"
+	"path"
 	"strings"
+	"time"
+
+	"shared/identity"
+	"shared/logging"
+	"shared/server"
+	"shared/tracing"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/joho/godotenv"
 )
 
-// Helper: Proxy Request
-func proxyRequest(target string) gin.HandlerFunc {
+// Middleware: Validasi Token (public key dari JWKS auth-service), cek denylist
+// (kalau diaktifkan) & Inject identity assertion untuk microservice. Kalau
+// apiKeys tidak nil, request tanpa Bearer boleh memakai header X-API-Key.
+func AuthMiddleware(jwks *JWKSCache, algorithms []string, denylist *Denylist, apiKeys *APIKeys, identityKey []byte) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		remote, _ := url.Parse(target)
-		proxy := httputil.NewSingleHostReverseProxy(remote)
-		proxy.Director = func(req *http.Request) {
-			req.Header = c.Request.Header
-			req.Host = remote.Host
-			req.URL.Scheme = remote.Scheme
-			req.URL.Host = remote.Host
-            
-			// UBAH BARIS INI:
-			// Gunakan c.Request.URL.Path agar "/order/create" tetap utuh
-			req.URL.Path = c.Request.URL.Path 
+		tokenString, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if (!ok || tokenString == "") && apiKeys != nil && c.GetHeader(HeaderAPIKey) != "" {
+			authenticateAPIKey(c, apiKeys, identityKey)
+			return
 		}
-		proxy.ServeHTTP(c.Writer, c.Request)
-	}
-}
-// Middleware: Validasi Token & Inject User ID
-func AuthMiddleware() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		authHeader := c.GetHeader("Authorization")
-		if authHeader == "" {
+		if !ok || tokenString == "" {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
 			return
 		}
 
-		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			return []byte(os.Getenv("JWT_SECRET")), nil
-		})
+		// Hanya alg yang diizinkan config; mencegah token "alg: none" atau
+		// HS256 yang ditandatangani pakai public key.
+		token, err := jwt.Parse(tokenString, jwks.Keyfunc,
+			jwt.WithValidMethods(algorithms),
+			jwt.WithExpirationRequired(),
+		)
 
 		if err != nil || !token.Valid {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid Token"})
 			return
 		}
 
-		claims, ok := token.Claims.(jwt.MapClaims)
-		if ok {
-			// Kirim User ID ke Microservice via Header
-			c.Request.Header.Set("X-User-ID", fmt.Sprintf("%v", claims["sub"]))
+		claims, _ := token.Claims.(jwt.MapClaims)
+		if denylist != nil {
+			revoked, err := denylist.Revoked(c.Request.Context(), claims)
+			if err != nil {
+				// Fail open: Redis down tidak boleh membuat semua user ter-logout
+				logging.FromContext(c.Request.Context()).Warn("cek denylist gagal, token tetap diterima", "error", err)
+			}
+			if revoked {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Token Revoked"})
+				return
+			}
+		}
+
+		id := identity.Identity{UserID: subject(claims)}
+		id.Username, _ = claims["name"].(string)
+		if roles, ok := claims["roles"].([]any); ok {
+			for _, r := range roles {
+				if role, ok := r.(string); ok {
+					id.Roles = append(id.Roles, role)
+				}
+			}
+		}
+		if scope, ok := claims["scope"].(string); ok {
+			id.Scopes = strings.Fields(scope) // format OAuth2: "orders:read payments:write"
 		}
+		if exp, _ := claims.GetExpirationTime(); exp != nil {
+			c.Set("token_exp", exp.Time) // batas umur koneksi stream
+		}
+		setIdentity(c, id, identityKey)
+	}
+}
+
+// setIdentity mengirim identitas user ke Microservice via assertion bertanda
+// tangan, lalu lanjut ke handler berikutnya.
+func setIdentity(c *gin.Context, id identity.Identity, identityKey []byte) {
+	assertion, err := identity.Sign(id, identityKey, time.Now())
+	if err != nil {
+		logging.FromContext(c.Request.Context()).Error("gagal membuat identity assertion", "error", err)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
+		return
+	}
+	c.Request.Header.Set(identity.Header, assertion)
+	identity.Set(c, &id) // dipakai PolicyMiddleware, RateLimitMiddleware & log
+	c.Next()
+}
+
+// Middleware: buang header identitas yang dikirim client. Identitas hanya
+// boleh berasal dari AuthMiddleware.
+func StripIdentityMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Request.Header.Del("X-User-ID")
+		c.Request.Header.Del(identity.Header)
 		c.Next()
 	}
 }
 
-func main() {
-	godotenv.Load() // Pastikan ada JWT_SECRET di .env
+// Middleware: endpoint /internal/ hanya untuk komunikasi antar service, tidak
+// boleh diakses dari luar lewat gateway. Path dinormalisasi dulu supaya variasi
+// seperti "//internal", "/./internal" atau huruf besar tidak lolos.
+func BlockInternalMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if hasInternalSegment(c.Request.URL.Path) || hasInternalSegment(c.Request.URL.RawPath) {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "Not Found"})
+			return
+		}
+		c.Next()
+	}
+}
 
-	r := gin.Default()
+func hasInternalSegment(p string) bool {
+	for _, segment := range strings.Split(path.Clean("/"+p), "/") {
+		if strings.EqualFold(segment, "internal") {
+			return true
+		}
+	}
+	return false
+}
 
-	// Setup CORS untuk Gateway
-	r.Use(func(c *gin.Context) {
-		c.Writer.Header().Set("Access-Control-Allow-Origin", "http://localhost:3000")
+// Middleware: tolak path yang belum kanonik ("//", "/./", "/../"). Policy
+// dan upstream harus melihat path yang sama; kalau path dibersihkan hanya
+// untuk policy, "/order/list/../admin" dicek sebagai "/order/admin" tapi
+// diteruskan apa adanya ke upstream.
+func CanonicalPathMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !isCanonicalPath(c.Request.URL.Path) {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Bad Request"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// isCanonicalPath: p tidak berubah oleh path.Clean, kecuali "/" di akhir.
+func isCanonicalPath(p string) bool {
+	clean := path.Clean(p)
+	if strings.HasSuffix(p, "/") && clean != "/" {
+		clean += "/"
+	}
+	return clean == p
+}
+
+// Middleware: CORS sesuai config
+func CORSMiddleware(cfg CORSConfig) gin.HandlerFunc {
+	allowed := make(map[string]bool, len(cfg.AllowOrigins))
+	for _, o := range cfg.AllowOrigins {
+		allowed[o] = true
+	}
+
+	return func(c *gin.Context) {
+		if origin := c.GetHeader("Origin"); allowed[origin] {
+			c.Writer.Header().Set("Access-Control-Allow-Origin", origin)
+			c.Writer.Header().Add("Vary", "Origin")
+		}
 		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, DELETE")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Device-ID")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Device-ID, Idempotency-Key, X-CSRF-Token")
 		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
+		c.Writer.Header().Set("Access-Control-Expose-Headers", "Retry-After, X-RateLimit-Limit, X-RateLimit-Remaining, X-RateLimit-Reset, Idempotent-Replayed")
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
 			return
 		}
 		c.Next()
-	})
+	}
+}
+
+// Middleware: batas waktu per route. Context request dibatalkan setelah
+// options.timeout, sehingga reverse proxy berhenti menunggu upstream. Path
+// stream punya batas sendiri (lihat serveStream).
+func TimeoutMiddleware(rt *route) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if rt.isStream(c.Request.URL.Path) {
+			c.Next()
+			return
+		}
+		ctx, cancel := context.WithTimeout(c.Request.Context(), time.Duration(rt.Options.Timeout))
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
+
+func main() {
+	godotenv.Load() // Pastikan ada IDENTITY_SIGNING_KEY di .env
+	logging.Setup("gateway")
 
-	// 1. Route ke Auth Service (Tanpa Middleware Auth)
-	// Request ke /auth/login akan diteruskan ke localhost:8080/auth/login
-	r.Any("/auth/*proxyPath", proxyRequest("http://localhost:8080"))
+	shutdownTracing, err := tracing.Setup(context.Background(), "gateway")
+	if err != nil {
+		logging.Fatal("gagal setup tracing", "error", err)
+	}
+	defer shutdownTracing(context.Background())
 
-	// 2. Route ke Order Service (Butuh Login)
-	r.Any("/order/*proxyPath", AuthMiddleware(), proxyRequest("http://localhost:8081"))
+	configPath := os.Getenv("GATEWAY_CONFIG")
+	if configPath == "" {
+		configPath = "gateway.yaml"
+	}
 
-	// 3. Route ke Payment Service (Butuh Login)
-	r.Any("/payment/*proxyPath", AuthMiddleware(), proxyRequest("http://localhost:8082"))
+	gw, err := NewGateway(configPath)
+	if err != nil {
+		logging.Fatal("gagal load config gateway", "path", configPath, "error", err)
+	}
+	go gw.Watch(2 * time.Second)
 
-	log.Println("🚪 API Gateway running on port 8000")
-	r.Run(":8000")
-}
\ No newline at end of file
+	admin := &http.Server{Addr: gw.Config().AdminListen, Handler: gw.AdminHandler()}
+	public := &http.Server{Addr: gw.Config().Listen, Handler: gw}
+	public.RegisterOnShutdown(gw.CloseStreams)
+
+	slog.Info("admin endpoint running", "addr", admin.Addr)
+	slog.Info("API Gateway running", "addr", public.Addr, "config", configPath)
+	if err := server.Run(public, admin); err != nil {
+		logging.Fatal("API Gateway berhenti", "error", err)
+	}
+	gw.Close()
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Metrik per upstream. Metrik HTTP umum (per route gateway) ada di
+// shared/metrics; semuanya keluar di /metrics pada admin_listen.
+var (
+	upstreamDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "gateway_upstream_request_duration_seconds",
+		Help:    "Latency request dari gateway ke upstream.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"route", "upstream"})
+
+	upstreamErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gateway_upstream_errors_total",
+		Help: "Request ke upstream yang gagal: error (koneksi/timeout) atau 5xx.",
+	}, []string{"route", "upstream", "reason"})
+
+	upstreamRejected = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gateway_upstream_rejected_total",
+		Help: "Request yang tidak diteruskan karena circuit open atau tidak ada upstream sehat.",
+	}, []string{"route", "reason"})
+
+	requestsInvalid = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gateway_requests_invalid_total",
+		Help: "Request yang ditolak karena tidak sesuai spec OpenAPI route.",
+	}, []string{"route"})
+
+	compositeParts = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gateway_composite_parts_total",
+		Help: "Part endpoint composite per hasil (ok, error, timeout).",
+	}, []string{"composite", "part", "result"})
+)
+
+func observeUpstream(rt *route, u *Upstream, start time.Time, status int, err error) {
+	upstreamDuration.WithLabelValues(rt.Prefix, u.URL.String()).Observe(time.Since(start).Seconds())
+	observeUpstreamError(rt, u, status, err)
+}
+
+// observeUpstreamError tanpa latency, untuk koneksi stream yang umurnya
+// memang panjang dan akan merusak histogram.
+func observeUpstreamError(rt *route, u *Upstream, status int, err error) {
+	switch {
+	case err != nil:
+		upstreamErrors.WithLabelValues(rt.Prefix, u.URL.String(), "error").Inc()
+	case status >= 500:
+		upstreamErrors.WithLabelValues(rt.Prefix, u.URL.String(), "5xx").Inc()
+	}
+}
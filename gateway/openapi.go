@@ -0,0 +1,299 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"shared/logging"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/gorillamux"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	specRefresh  = 5 * time.Minute  // umur spec sebelum diambil ulang dari upstream
+	specMinRetry = 10 * time.Second // jarak minimal antar fetch saat upstream gagal
+	specMaxSize  = 4 << 20
+
+	bearerScheme = "bearerAuth"
+)
+
+func init() {
+	// kin-openapi tidak memvalidasi format "email" kalau tidak didaftarkan
+	openapi3.DefineStringFormatValidator("email", openapi3.NewRegexpFormatValidator(openapi3.FormatOfStringForEmail))
+}
+
+// routeSpec: spec OpenAPI satu route, diambil dari upstream saat pertama
+// dibutuhkan lalu di-refresh di background. Path di dalamnya sudah dalam
+// bentuk path gateway (lihat gatewaySpec).
+type routeSpec struct {
+	rt     *route
+	client *http.Client
+
+	mu          sync.RWMutex
+	doc         *openapi3.T
+	router      routers.Router
+	fetchedAt   time.Time
+	lastAttempt time.Time
+}
+
+func newRouteSpec(rt *route, transport http.RoundTripper) *routeSpec {
+	return &routeSpec{rt: rt, client: &http.Client{Timeout: 5 * time.Second, Transport: transport}}
+}
+
+// get mengembalikan spec terakhir. Nil kalau belum pernah berhasil diambil.
+func (s *routeSpec) get() (*openapi3.T, routers.Router) {
+	s.mu.RLock()
+	doc, router, stale := s.doc, s.router, time.Since(s.fetchedAt) > specRefresh
+	s.mu.RUnlock()
+
+	switch {
+	case doc == nil:
+		s.refresh()
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+		return s.doc, s.router
+	case stale:
+		// Spec lama tetap dipakai sambil fetch di background
+		go s.refresh()
+	}
+	return doc, router
+}
+
+func (s *routeSpec) refresh() {
+	s.mu.Lock()
+	if time.Since(s.lastAttempt) < specMinRetry {
+		s.mu.Unlock()
+		return
+	}
+	s.lastAttempt = time.Now()
+	s.mu.Unlock()
+
+	doc, err := s.fetch()
+	var router routers.Router
+	if err == nil {
+		router, err = gorillamux.NewRouter(doc)
+	}
+	if err != nil {
+		slog.Warn("ambil spec OpenAPI gagal", "route", s.rt.Prefix, "error", err)
+		return
+	}
+
+	s.mu.Lock()
+	s.doc, s.router, s.fetchedAt = doc, router, time.Now()
+	s.mu.Unlock()
+}
+
+// fetch mencoba upstream satu per satu sampai ada yang menjawab.
+func (s *routeSpec) fetch() (*openapi3.T, error) {
+	var errs []error
+	for _, u := range s.rt.Upstreams {
+		doc, err := s.fetchFrom(strings.TrimSuffix(u, "/") + s.rt.OpenAPI.Path)
+		if err == nil {
+			return gatewaySpec(doc, s.rt), nil
+		}
+		errs = append(errs, err)
+	}
+	return nil, errors.Join(errs...)
+}
+
+func (s *routeSpec) fetchFrom(url string) (*openapi3.T, error) {
+	resp, err := s.client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", url, resp.Status)
+	}
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, specMaxSize))
+	if err != nil {
+		return nil, err
+	}
+
+	loader := openapi3.NewLoader()
+	doc, err := loader.LoadFromData(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", url, err)
+	}
+	if err := doc.Validate(loader.Context); err != nil {
+		return nil, fmt.Errorf("%s: %w", url, err)
+	}
+	return doc, nil
+}
+
+// gatewaySpec mengubah spec upstream ke sudut pandang client gateway: prefix
+// dipasang lagi kalau route strip_prefix, path /internal/ dibuang (diblok
+// gateway), dan operasi di route dengan auth diberi security bearer.
+func gatewaySpec(doc *openapi3.T, rt *route) *openapi3.T {
+	paths := openapi3.NewPaths()
+	for p, item := range doc.Paths.Map() {
+		if rt.Options.StripPrefix {
+			p = rt.Prefix + p
+		}
+		if !strings.HasPrefix(p, rt.Prefix+"/") || hasInternalSegment(p) {
+			continue
+		}
+		if rt.Auth {
+			for _, op := range item.Operations() {
+				op.Security = &openapi3.SecurityRequirements{{bearerScheme: []string{}}}
+			}
+		}
+		paths.Set(p, item)
+	}
+	doc.Paths = paths
+	doc.Servers = nil
+
+	if rt.Auth {
+		if doc.Components == nil {
+			doc.Components = &openapi3.Components{}
+		}
+		if doc.Components.SecuritySchemes == nil {
+			doc.Components.SecuritySchemes = openapi3.SecuritySchemes{}
+		}
+		doc.Components.SecuritySchemes[bearerScheme] = &openapi3.SecuritySchemeRef{Value: openapi3.NewJWTSecurityScheme()}
+	}
+	return doc
+}
+
+// mergeSpecs menggabungkan spec semua route jadi satu dokumen. Route yang
+// spec-nya belum bisa diambil dilewati.
+func mergeSpecs(routes []*route) *openapi3.T {
+	out := &openapi3.T{
+		OpenAPI:    "3.0.3",
+		Info:       &openapi3.Info{Title: "API Gateway", Version: "1.0.0"},
+		Paths:      openapi3.NewPaths(),
+		Components: &openapi3.Components{},
+	}
+	for _, rt := range routes {
+		if rt.spec == nil {
+			continue
+		}
+		doc, _ := rt.spec.get()
+		if doc == nil {
+			continue
+		}
+		for p, item := range doc.Paths.Map() {
+			out.Paths.Set(p, item)
+		}
+		if c := doc.Components; c != nil {
+			oc := out.Components
+			oc.Schemas = mergeComponents(oc.Schemas, c.Schemas, "schemas", rt)
+			oc.Parameters = mergeComponents(oc.Parameters, c.Parameters, "parameters", rt)
+			oc.Headers = mergeComponents(oc.Headers, c.Headers, "headers", rt)
+			oc.RequestBodies = mergeComponents(oc.RequestBodies, c.RequestBodies, "requestBodies", rt)
+			oc.Responses = mergeComponents(oc.Responses, c.Responses, "responses", rt)
+			oc.SecuritySchemes = mergeComponents(oc.SecuritySchemes, c.SecuritySchemes, "securitySchemes", rt)
+			oc.Examples = mergeComponents(oc.Examples, c.Examples, "examples", rt)
+		}
+	}
+	return out
+}
+
+// mergeComponents: komponen dengan nama sama (misal schema "Error") boleh ada
+// di beberapa service asal isinya sama. Kalau beda, definisi pertama dipakai.
+func mergeComponents[M ~map[string]V, V any](dst, src M, kind string, rt *route) M {
+	for name, v := range src {
+		if dst == nil {
+			dst = M{}
+		}
+		if old, ok := dst[name]; ok {
+			if !sameJSON(old, v) {
+				slog.Warn("komponen OpenAPI bentrok, dipakai definisi pertama", "component", kind+"/"+name, "route", rt.Prefix)
+			}
+			continue
+		}
+		dst[name] = v
+	}
+	return dst
+}
+
+func sameJSON(a, b any) bool {
+	ja, errA := json.Marshal(a)
+	jb, errB := json.Marshal(b)
+	return errA == nil && errB == nil && bytes.Equal(ja, jb)
+}
+
+// openAPIHandler: GET /openapi.json, spec gabungan semua route.
+func openAPIHandler(routes []*route) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, mergeSpecs(routes))
+	}
+}
+
+// ValidateMiddleware menolak (400) request yang tidak sesuai spec route.
+// Path/method yang tidak ada di spec diteruskan apa adanya (upstream yang
+// menjawab 404/405). Kalau spec belum bisa diambil, validasi dilewati.
+func ValidateMiddleware(rt *route) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if rt.isStream(c.Request.URL.Path) {
+			c.Next()
+			return
+		}
+		_, router := rt.spec.get()
+		if router == nil {
+			c.Next()
+			return
+		}
+		specRoute, params, err := router.FindRoute(c.Request)
+		if err != nil {
+			c.Next()
+			return
+		}
+
+		err = openapi3filter.ValidateRequest(c.Request.Context(), &openapi3filter.RequestValidationInput{
+			Request:    c.Request,
+			PathParams: params,
+			Route:      specRoute,
+			Options: &openapi3filter.Options{
+				// Token sudah dicek AuthMiddleware
+				AuthenticationFunc: openapi3filter.NoopAuthenticationFunc,
+			},
+		})
+		if err != nil {
+			detail := validationDetail(err)
+			requestsInvalid.WithLabelValues(rt.Prefix).Inc()
+			logging.FromContext(c.Request.Context()).Info("request tidak sesuai spec", "route", rt.Prefix, "detail", detail)
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error":  "Bad Request",
+				"code":   "invalid_request",
+				"detail": detail,
+			})
+			return
+		}
+		c.Next()
+	}
+}
+
+// validationDetail: pesan singkat untuk client, tanpa dump skema dari
+// kin-openapi.
+func validationDetail(err error) string {
+	var schemaErr *openapi3.SchemaError
+	if errors.As(err, &schemaErr) {
+		if field := strings.Join(schemaErr.JSONPointer(), "."); field != "" {
+			return field + ": " + schemaErr.Reason
+		}
+		return schemaErr.Reason
+	}
+	var reqErr *openapi3filter.RequestError
+	if errors.As(err, &reqErr) {
+		if reqErr.Parameter != nil {
+			return "parameter " + reqErr.Parameter.Name + ": " + reqErr.Reason
+		}
+		if reqErr.Reason != "" {
+			return reqErr.Reason
+		}
+	}
+	return err.Error()
+}
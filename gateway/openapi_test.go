@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// testSpec: spec upstream dengan path tanpa prefix (route strip_prefix).
+const testSpec = `{
+  "openapi": "3.0.3",
+  "info": {"title": "Order Service", "version": "1.0.0"},
+  "paths": {
+    "/create": {
+      "post": {
+        "requestBody": {
+          "required": true,
+          "content": {"application/json": {"schema": {"$ref": "#/components/schemas/CreateOrderRequest"}}}
+        },
+        "responses": {"201": {"description": "Created"}}
+      }
+    },
+    "/internal/update-status": {
+      "post": {"responses": {"200": {"description": "OK"}}}
+    }
+  },
+  "components": {
+    "schemas": {
+      "CreateOrderRequest": {
+        "type": "object",
+        "required": ["item", "price"],
+        "properties": {
+          "item": {"type": "string", "minLength": 1},
+          "price": {"type": "number", "exclusiveMinimum": true, "minimum": 0}
+        }
+      }
+    }
+  }
+}`
+
+func newSpecUpstream(t *testing.T, calls *int) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/openapi.json" {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(testSpec))
+			return
+		}
+		*calls++
+		w.WriteHeader(http.StatusCreated)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func newSpecRoute(upstream string) *route {
+	rt := &route{RouteConfig: RouteConfig{
+		Prefix:    "/order",
+		Upstreams: []string{upstream},
+		Auth:      true,
+		OpenAPI:   OpenAPIConfig{Path: "/openapi.json", Validate: true},
+		Options:   RouteOptions{StripPrefix: true},
+	}}
+	rt.spec = newRouteSpec(rt, http.DefaultTransport)
+	return rt
+}
+
+func TestMergeSpecs(t *testing.T) {
+	calls := 0
+	rt := newSpecRoute(newSpecUpstream(t, &calls).URL)
+
+	doc := mergeSpecs([]*route{rt})
+	create := doc.Paths.Value("/order/create")
+	if create == nil || create.Post == nil {
+		t.Fatalf("path /order/create tidak ada di spec gabungan: %v", doc.Paths.InMatchingOrder())
+	}
+	if doc.Paths.Value("/order/internal/update-status") != nil {
+		t.Fatal("path internal tidak boleh ikut dipublikasikan")
+	}
+	if create.Post.Security == nil || doc.Components.SecuritySchemes[bearerScheme] == nil {
+		t.Fatal("route dengan auth harus diberi security bearer")
+	}
+	if doc.Components.Schemas["CreateOrderRequest"] == nil {
+		t.Fatal("schema dari upstream tidak ikut digabung")
+	}
+}
+
+func TestValidateMiddleware(t *testing.T) {
+	calls := 0
+	rt := newSpecRoute(newSpecUpstream(t, &calls).URL)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/order/*proxyPath", ValidateMiddleware(rt), func(c *gin.Context) {
+		calls++
+		c.Status(http.StatusCreated)
+	})
+
+	cases := []struct {
+		name, path, body string
+		want             int
+	}{
+		{"valid", "/order/create", `{"item":"Nasi Goreng","price":25000}`, http.StatusCreated},
+		{"field wajib hilang", "/order/create", `{"item":"Nasi Goreng"}`, http.StatusBadRequest},
+		{"harga negatif", "/order/create", `{"item":"Nasi Goreng","price":-1}`, http.StatusBadRequest},
+		{"bukan JSON", "/order/create", `item=1`, http.StatusBadRequest},
+		{"path tidak ada di spec", "/order/lain", `{}`, http.StatusCreated},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, tc.path, strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tc.want {
+				t.Fatalf("status = %d, want %d (%s)", w.Code, tc.want, w.Body)
+			}
+			if w.Code == http.StatusBadRequest {
+				var body map[string]string
+				json.Unmarshal(w.Body.Bytes(), &body)
+				if body["code"] != "invalid_request" || body["detail"] == "" {
+					t.Fatalf("body 400 = %s", w.Body)
+				}
+			}
+		})
+	}
+	if calls != 2 {
+		t.Fatalf("handler dipanggil %d kali, want 2", calls)
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"shared/identity"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	PolicyAllow = "allow"
+	PolicyDeny  = "deny"
+)
+
+// Policy: aturan RBAC gateway (policy_file di config). Dicek sesudah
+// AuthMiddleware, jadi hanya berlaku untuk route dengan auth: true.
+type Policy struct {
+	Default string       `json:"default"` // kalau tidak ada aturan yang cocok: allow (default) | deny
+	Rules   []PolicyRule `json:"rules"`   // aturan pertama yang cocok dipakai
+}
+
+// PolicyRule: request yang cocok Path & Methods harus punya salah satu Roles
+// atau semua Scopes. Roles & Scopes kosong = cukup login.
+type PolicyRule struct {
+	Path    string   `json:"path"`    // "*" = satu segmen, "**" di akhir = sisa path
+	Methods []string `json:"methods"` // kosong = semua method
+	Roles   []string `json:"roles"`
+	Scopes  []string `json:"scopes"`
+}
+
+// LoadPolicy membaca file policy (YAML atau JSON, sama seperti config).
+func LoadPolicy(file string) (*Policy, time.Time, error) {
+	info, err := os.Stat(file)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	p := &Policy{}
+	if err := decodeFile(file, p); err != nil {
+		return nil, time.Time{}, err
+	}
+	if err := p.validate(); err != nil {
+		return nil, time.Time{}, fmt.Errorf("%s: %w", file, err)
+	}
+	return p, info.ModTime(), nil
+}
+
+func (p *Policy) validate() error {
+	switch p.Default {
+	case "":
+		p.Default = PolicyAllow
+	case PolicyAllow, PolicyDeny:
+	default:
+		return fmt.Errorf("default %q tidak dikenal (allow | deny)", p.Default)
+	}
+	for i := range p.Rules {
+		rule := &p.Rules[i]
+		if !strings.HasPrefix(rule.Path, "/") {
+			return fmt.Errorf("rules[%d]: path %q harus diawali '/'", i, rule.Path)
+		}
+		if n := strings.Count(rule.Path, "**"); n > 1 || n == 1 && !strings.HasSuffix(rule.Path, "/**") {
+			return fmt.Errorf("rules[%d]: \"**\" hanya boleh di akhir path (%q)", i, rule.Path)
+		}
+		for j, m := range rule.Methods {
+			rule.Methods[j] = strings.ToUpper(m)
+		}
+	}
+	return nil
+}
+
+// Allow: apakah id boleh mengakses method + urlPath.
+func (p *Policy) Allow(method, urlPath string, id *identity.Identity) bool {
+	urlPath = path.Clean("/" + urlPath)
+	for _, rule := range p.Rules {
+		if len(rule.Methods) > 0 && !slices.Contains(rule.Methods, method) {
+			continue
+		}
+		if !matchPath(rule.Path, urlPath) {
+			continue
+		}
+		if len(rule.Roles) == 0 && len(rule.Scopes) == 0 {
+			return true
+		}
+		return (len(rule.Roles) > 0 && id.HasRole(rule.Roles...)) ||
+			(len(rule.Scopes) > 0 && id.HasScopes(rule.Scopes...))
+	}
+	return p.Default != PolicyDeny
+}
+
+// matchPath mencocokkan path per segmen: "*" cocok dengan satu segmen apa
+// saja, "/**" di akhir pattern cocok dengan nol atau lebih segmen sisanya.
+func matchPath(pattern, urlPath string) bool {
+	if prefix, ok := strings.CutSuffix(pattern, "/**"); ok {
+		pattern = prefix
+		pp := strings.Split(pattern, "/")
+		up := strings.Split(urlPath, "/")
+		if len(up) < len(pp) {
+			return false
+		}
+		return segmentsMatch(pp, up[:len(pp)])
+	}
+	return segmentsMatch(strings.Split(pattern, "/"), strings.Split(urlPath, "/"))
+}
+
+func segmentsMatch(pattern, segments []string) bool {
+	if len(pattern) != len(segments) {
+		return false
+	}
+	for i, p := range pattern {
+		if p != "*" && p != segments[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// PolicyMiddleware menolak request yang tidak diizinkan policy dengan 403.
+// Harus dipasang sesudah AuthMiddleware.
+func PolicyMiddleware(p *Policy) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := identity.From(c)
+		if id == nil || !p.Allow(c.Request.Method, c.Request.URL.Path, id) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"shared/identity"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestMatchPath(t *testing.T) {
+	cases := []struct {
+		pattern, path string
+		want          bool
+	}{
+		{"/order/list", "/order/list", true},
+		{"/order/list", "/order/list/x", false},
+		{"/order/*/items", "/order/7/items", true},
+		{"/order/*/items", "/order/items", false},
+		{"/order/admin/**", "/order/admin", true},
+		{"/order/admin/**", "/order/admin/users/7", true},
+		{"/order/admin/**", "/order/administrator", false},
+	}
+	for _, tc := range cases {
+		if got := matchPath(tc.pattern, tc.path); got != tc.want {
+			t.Errorf("matchPath(%q, %q) = %v, want %v", tc.pattern, tc.path, got, tc.want)
+		}
+	}
+}
+
+func TestPolicyAllow(t *testing.T) {
+	p := &Policy{
+		Default: PolicyDeny,
+		Rules: []PolicyRule{
+			{Path: "/order/admin/**", Roles: []string{"admin", "support"}},
+			{Path: "/order/**", Methods: []string{"GET"}, Roles: []string{"customer"}, Scopes: []string{"orders:read"}},
+			{Path: "/payment/pay", Methods: []string{"POST"}},
+		},
+	}
+	if err := p.validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	customer := &identity.Identity{UserID: "1", Roles: []string{"customer"}}
+	support := &identity.Identity{UserID: "2", Roles: []string{"support"}}
+	apiKey := &identity.Identity{UserID: "3", Scopes: []string{"orders:read"}}
+
+	cases := []struct {
+		name         string
+		method, path string
+		id           *identity.Identity
+		want         bool
+	}{
+		{"customer lihat order", "GET", "/order/list", customer, true},
+		{"customer ke admin", "GET", "/order/admin/users", customer, false},
+		{"customer ke admin lewat //", "GET", "//order//admin/users", customer, false},
+		{"support ke admin", "POST", "/order/admin/refund", support, true},
+		{"scope saja cukup", "GET", "/order/list", apiKey, true},
+		{"method tidak cocok -> default deny", "POST", "/order/create", customer, false},
+		{"aturan tanpa role = cukup login", "POST", "/payment/pay", support, true},
+		{"tidak ada aturan -> default deny", "GET", "/payment/history", customer, false},
+	}
+	for _, tc := range cases {
+		if got := p.Allow(tc.method, tc.path, tc.id); got != tc.want {
+			t.Errorf("%s: Allow(%s %s) = %v, want %v", tc.name, tc.method, tc.path, got, tc.want)
+		}
+	}
+}
+
+func TestPolicyValidateDoubleStar(t *testing.T) {
+	for _, bad := range []string{"/order/**/items", "/order**", "/a/**/**"} {
+		p := &Policy{Rules: []PolicyRule{{Path: bad}}}
+		if err := p.validate(); err == nil {
+			t.Errorf("path %q harusnya ditolak", bad)
+		}
+	}
+}
+
+// Path yang dicek policy harus sama dengan path yang diteruskan ke upstream:
+// variasi "..", "." atau "//" ditolak sebelum sampai ke policy.
+func TestPolicyNonCanonicalPath(t *testing.T) {
+	var forwarded []string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		forwarded = append(forwarded, r.URL.Path)
+	}))
+	defer upstream.Close()
+
+	rc := RouteConfig{Prefix: "/order", Upstreams: []string{upstream.URL}, Balancer: BalancerRoundRobin}
+	pool, err := NewPool(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rt := &route{RouteConfig: rc, pool: pool}
+	rt.proxy = newRouteProxy(rt, newTransport(TransportConfig{}), nil)
+	p := &Policy{Default: PolicyDeny, Rules: []PolicyRule{
+		{Path: "/order/admin/**", Roles: []string{"admin"}},
+		{Path: "/order/**", Roles: []string{"customer"}},
+	}}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(CanonicalPathMiddleware())
+	r.Any("/order/*proxyPath", func(c *gin.Context) {
+		identity.Set(c, &identity.Identity{UserID: "1", Roles: []string{"customer"}})
+	}, PolicyMiddleware(p), proxyRequest(rt))
+	gw := httptest.NewServer(r)
+	defer gw.Close()
+
+	cases := []struct {
+		path string
+		want int
+	}{
+		{"/order/list", http.StatusOK},
+		{"/order/list/", http.StatusOK},
+		{"/order/admin/users", http.StatusForbidden},
+		{"/order/list/../admin/users", http.StatusBadRequest},
+		{"/order/list/%2e%2e/admin/users", http.StatusBadRequest},
+		{"/order//admin/users", http.StatusBadRequest},
+		{"/order/./admin/users", http.StatusBadRequest},
+	}
+	for _, tc := range cases {
+		resp, err := http.Get(gw.URL + tc.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tc.want {
+			t.Errorf("GET %s = %d, want %d", tc.path, resp.StatusCode, tc.want)
+		}
+	}
+	if len(forwarded) != 2 || forwarded[0] != "/order/list" || forwarded[1] != "/order/list/" {
+		t.Fatalf("path ke upstream = %q, want hanya /order/list dan /order/list/", forwarded)
+	}
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	BalancerRoundRobin = "round_robin"
+	BalancerLeastConn  = "least_conn"
+)
+
+var ErrNoHealthyUpstream = errors.New("no healthy upstream")
+
+// Upstream adalah satu instance service di dalam Pool.
+type Upstream struct {
+	URL     *url.URL
+	breaker *Breaker
+
+	healthy atomic.Bool  // hasil health check aktif
+	active  atomic.Int64 // jumlah request yang sedang diproses
+
+	mu           sync.Mutex
+	failures     int // 5xx / error koneksi berturut-turut (passive)
+	ejectedUntil time.Time
+	probeOK      int
+	probeFail    int
+}
+
+// Available: lolos health check aktif dan tidak sedang di-eject.
+func (u *Upstream) Available(now time.Time) bool {
+	if !u.healthy.Load() {
+		return false
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return !now.Before(u.ejectedUntil)
+}
+
+// Pool memilih upstream untuk satu route dan melacak kesehatannya.
+type Pool struct {
+	upstreams []*Upstream
+	balancer  string
+	health    HealthCheckConfig
+	passive   PassiveConfig
+	next      atomic.Uint64
+
+	client *http.Client
+	stop   chan struct{}
+	once   sync.Once
+}
+
+func NewPool(cfg RouteConfig) (*Pool, error) {
+	p := &Pool{
+		balancer: cfg.Balancer,
+		health:   cfg.HealthCheck,
+		passive:  cfg.Passive,
+		client:   &http.Client{Timeout: time.Duration(cfg.HealthCheck.Timeout)},
+		stop:     make(chan struct{}),
+	}
+	for _, raw := range cfg.Upstreams {
+		parsed, err := url.Parse(raw)
+		if err != nil {
+			return nil, err
+		}
+		u := &Upstream{URL: parsed, breaker: NewBreaker(parsed.Host, cfg.Breaker)}
+		u.healthy.Store(true) // optimis: sehat sampai terbukti sebaliknya
+		p.upstreams = append(p.upstreams, u)
+	}
+	return p, nil
+}
+
+// Pick memilih upstream yang tersedia sesuai balancer route. Upstream yang
+// breaker-nya open dilewati; kalau hanya tersisa upstream seperti itu,
+// errornya *CircuitOpenError.
+func (p *Pool) Pick() (*Upstream, error) {
+	now := time.Now()
+	n := len(p.upstreams)
+	start := int((p.next.Add(1) - 1) % uint64(n))
+
+	candidates := make([]*Upstream, 0, n)
+	for i := 0; i < n; i++ {
+		candidates = append(candidates, p.upstreams[(start+i)%n])
+	}
+	if p.balancer == BalancerLeastConn {
+		// Stable: kalau seri, tetap urutan round-robin di atas
+		slices.SortStableFunc(candidates, func(a, b *Upstream) int {
+			return cmp.Compare(a.active.Load(), b.active.Load())
+		})
+	}
+
+	var openErr *CircuitOpenError
+	for _, u := range candidates {
+		if !u.Available(now) {
+			continue
+		}
+		if u.breaker.Allow(now) {
+			return u, nil
+		}
+		retry := u.breaker.RetryAfter(now)
+		if openErr == nil || retry < openErr.RetryAfter {
+			openErr = &CircuitOpenError{RetryAfter: retry}
+		}
+	}
+	if openErr != nil {
+		return nil, openErr
+	}
+	return nil, ErrNoHealthyUpstream
+}
+
+// Acquire menandai satu request mulai diproses upstream u. Fungsi yang
+// dikembalikan wajib dipanggil sekali setelah request selesai dengan hasilnya:
+// err != nil untuk gagal konek/timeout, atau status code dari upstream.
+func (p *Pool) Acquire(u *Upstream) func(status int, err error) {
+	u.active.Add(1)
+	return func(status int, err error) {
+		u.active.Add(-1)
+		failed := err != nil || status >= 500
+		u.breaker.Record(failed, time.Now())
+		p.report(u, failed)
+	}
+}
+
+func (p *Pool) report(u *Upstream, failed bool) {
+	if p.passive.MaxFailures <= 0 {
+		return
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if !failed {
+		u.failures = 0
+		return
+	}
+	u.failures++
+	if u.failures >= p.passive.MaxFailures {
+		u.failures = 0
+		u.ejectedUntil = time.Now().Add(time.Duration(p.passive.EjectionTime))
+		slog.Warn("upstream di-eject",
+			"upstream", u.URL.String(),
+			"ejection_time", time.Duration(p.passive.EjectionTime).String(),
+			"failures", p.passive.MaxFailures)
+	}
+}
+
+// Start menjalankan health check aktif (kalau dikonfigurasi) di background.
+func (p *Pool) Start() {
+	if p.health.Path == "" {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(time.Duration(p.health.Interval))
+		defer ticker.Stop()
+		for {
+			p.probeAll()
+			select {
+			case <-p.stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Close menghentikan health check. Aman dipanggil berkali-kali.
+func (p *Pool) Close() {
+	p.once.Do(func() { close(p.stop) })
+}
+
+func (p *Pool) probeAll() {
+	var wg sync.WaitGroup
+	for _, u := range p.upstreams {
+		wg.Add(1)
+		go func(u *Upstream) {
+			defer wg.Done()
+			p.probe(u)
+		}(u)
+	}
+	wg.Wait()
+}
+
+func (p *Pool) probe(u *Upstream) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(p.health.Timeout))
+	defer cancel()
+
+	target := u.URL.JoinPath(p.health.Path)
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, target.String(), nil)
+	resp, err := p.client.Do(req)
+	ok := err == nil && resp.StatusCode < 500
+	if resp != nil {
+		resp.Body.Close()
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if ok {
+		u.probeFail = 0
+		u.probeOK++
+		if !u.healthy.Load() && u.probeOK >= p.health.HealthyThreshold {
+			u.healthy.Store(true)
+			slog.Info("upstream kembali sehat", "upstream", u.URL.String())
+		}
+		return
+	}
+
+	u.probeOK = 0
+	u.probeFail++
+	if u.healthy.Load() && u.probeFail >= p.health.UnhealthyThreshold {
+		u.healthy.Store(false)
+		slog.Warn("upstream tidak sehat", "upstream", u.URL.String(), "error", probeError(err, resp))
+	}
+}
+
+func probeError(err error, resp *http.Response) any {
+	if err != nil {
+		return err
+	}
+	return resp.Status
+}
+
+type UpstreamSnapshot struct {
+	URL          string          `json:"url"`
+	Healthy      bool            `json:"healthy"`
+	Active       int64           `json:"active"`
+	EjectedUntil *time.Time      `json:"ejected_until,omitempty"`
+	Breaker      BreakerSnapshot `json:"circuit_breaker"`
+}
+
+// Snapshot: status setiap upstream untuk endpoint admin.
+func (p *Pool) Snapshot() []UpstreamSnapshot {
+	now := time.Now()
+	out := make([]UpstreamSnapshot, 0, len(p.upstreams))
+	for _, u := range p.upstreams {
+		snap := UpstreamSnapshot{
+			URL:     u.URL.String(),
+			Healthy: u.healthy.Load(),
+			Active:  u.active.Load(),
+			Breaker: u.breaker.Snapshot(),
+		}
+		u.mu.Lock()
+		if now.Before(u.ejectedUntil) {
+			until := u.ejectedUntil
+			snap.EjectedUntil = &until
+		}
+		u.mu.Unlock()
+		out = append(out, snap)
+	}
+	return out
+}
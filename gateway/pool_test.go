@@ -0,0 +1,83 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func newTestPool(t *testing.T, balancer string, passive PassiveConfig) *Pool {
+	t.Helper()
+	p, err := NewPool(RouteConfig{
+		Upstreams: []string{"http://a:1", "http://b:2"},
+		Balancer:  balancer,
+		Passive:   passive,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestPoolRoundRobin(t *testing.T) {
+	p := newTestPool(t, BalancerRoundRobin, PassiveConfig{})
+
+	var got []string
+	for i := 0; i < 4; i++ {
+		u, err := p.Pick()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, u.URL.Host)
+	}
+	want := []string{"a:1", "b:2", "a:1", "b:2"}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("urutan pick = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestPoolLeastConn(t *testing.T) {
+	p := newTestPool(t, BalancerLeastConn, PassiveConfig{})
+
+	first, _ := p.Pick()
+	done := p.Acquire(first)
+	defer done(200, nil)
+
+	for i := 0; i < 3; i++ {
+		u, _ := p.Pick()
+		if u == first {
+			t.Fatalf("least_conn memilih %s yang masih sibuk", u.URL)
+		}
+	}
+}
+
+func TestPoolPassiveEjection(t *testing.T) {
+	p := newTestPool(t, BalancerRoundRobin, PassiveConfig{MaxFailures: 2, EjectionTime: Duration(time.Hour)})
+	bad := p.upstreams[0]
+
+	p.Acquire(bad)(502, nil)
+	if !bad.Available(time.Now()) {
+		t.Fatal("upstream di-eject sebelum mencapai max_failures")
+	}
+	p.Acquire(bad)(0, errors.New("connection refused"))
+	if bad.Available(time.Now()) {
+		t.Fatal("upstream seharusnya di-eject")
+	}
+
+	for i := 0; i < 3; i++ {
+		if u, _ := p.Pick(); u == bad {
+			t.Fatal("upstream yang di-eject masih dipilih")
+		}
+	}
+
+	p.upstreams[1].healthy.Store(false)
+	if _, err := p.Pick(); !errors.Is(err, ErrNoHealthyUpstream) {
+		t.Fatalf("err = %v, want ErrNoHealthyUpstream", err)
+	}
+
+	if !bad.Available(time.Now().Add(2 * time.Hour)) {
+		t.Fatal("upstream seharusnya kembali setelah ejection_time")
+	}
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+
+	"shared/logging"
+	"shared/tracing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// statusClientClosed: client memutus koneksi sebelum upstream menjawab
+// (konvensi nginx). Tidak pernah sampai ke client, hanya untuk log & metrik.
+const statusClientClosed = 499
+
+// newTransport: satu http.Transport untuk semua route, supaya koneksi
+// keep-alive ke upstream dipakai ulang antar request.
+func newTransport(cfg TransportConfig) http.RoundTripper {
+	dialer := &net.Dialer{
+		Timeout:   time.Duration(cfg.DialTimeout),
+		KeepAlive: time.Duration(cfg.KeepAlive),
+	}
+	t := &http.Transport{
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          cfg.MaxIdleConns,
+		MaxIdleConnsPerHost:   cfg.MaxIdleConnsPerHost,
+		MaxConnsPerHost:       cfg.MaxConnsPerHost,
+		IdleConnTimeout:       time.Duration(cfg.IdleConnTimeout),
+		ResponseHeaderTimeout: time.Duration(cfg.ResponseHeaderTimeout),
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: time.Second,
+	}
+	if cfg.H2C {
+		// Tanpa HTTP1: upstream http:// langsung diajak bicara HTTP/2 (h2c)
+		t.Protocols = new(http.Protocols)
+		t.Protocols.SetHTTP2(true)
+		t.Protocols.SetUnencryptedHTTP2(true)
+	}
+	return tracing.Transport(t)
+}
+
+// proxyAttempt: upstream yang dipilih untuk satu request beserta hasilnya,
+// dibawa lewat context dari proxyRequest ke Rewrite/ModifyResponse/ErrorHandler.
+type proxyAttempt struct {
+	upstream *Upstream
+	status   int
+	err      error
+}
+
+type proxyAttemptKey struct{}
+
+func attemptFrom(ctx context.Context) *proxyAttempt {
+	a, _ := ctx.Value(proxyAttemptKey{}).(*proxyAttempt)
+	return a
+}
+
+// newRouteProxy membuat reverse proxy untuk satu route. Proxy dibuat sekali
+// per versi config; upstream tujuan dipilih per request oleh proxyRequest.
+//
+// Rewrite (bukan Director) dipakai supaya header hop-by-hop (Connection,
+// Keep-Alive, Upgrade, TE, ...) dan X-Forwarded-* kiriman client dibuang
+// oleh httputil sebelum header baru diisi.
+func newRouteProxy(rt *route, transport http.RoundTripper, trusted trustedProxies) *httputil.ReverseProxy {
+	return &httputil.ReverseProxy{
+		Transport: transport,
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			target := attemptFrom(pr.In.Context()).upstream.URL
+			pr.Out.URL.Scheme = target.Scheme
+			pr.Out.URL.Host = target.Host
+			pr.Out.Host = target.Host
+
+			// Path tetap utuh ("/order/create"), kecuali route minta prefix-nya dibuang
+			if rt.Options.StripPrefix {
+				pr.Out.URL.Path = "/" + strings.TrimLeft(strings.TrimPrefix(pr.In.URL.Path, rt.Prefix), "/")
+				pr.Out.URL.RawPath = ""
+			}
+			setForwarded(pr, trusted)
+		},
+		ModifyResponse: func(resp *http.Response) error {
+			if a := attemptFrom(resp.Request.Context()); a != nil {
+				a.status = resp.StatusCode
+			}
+			return nil
+		},
+		ErrorHandler: proxyErrorHandler(rt),
+	}
+}
+
+// setForwarded mengisi X-Forwarded-For/Host/Proto. Nilai dari client hanya
+// diteruskan kalau koneksi datang dari trusted_proxies.
+func setForwarded(pr *httputil.ProxyRequest, trusted trustedProxies) {
+	clientIP, _, err := net.SplitHostPort(pr.In.RemoteAddr)
+	if err != nil {
+		clientIP = pr.In.RemoteAddr
+	}
+
+	forwardedFor := clientIP
+	host := pr.In.Host
+	proto := "http"
+	if pr.In.TLS != nil {
+		proto = "https"
+	}
+
+	if trusted.contains(clientIP) {
+		if prior := pr.In.Header.Values("X-Forwarded-For"); len(prior) > 0 {
+			forwardedFor = strings.Join(prior, ", ") + ", " + clientIP
+		}
+		if h := pr.In.Header.Get("X-Forwarded-Host"); h != "" {
+			host = h
+		}
+		if p := pr.In.Header.Get("X-Forwarded-Proto"); p == "http" || p == "https" {
+			proto = p
+		}
+	}
+
+	pr.Out.Header.Set("X-Forwarded-For", forwardedFor)
+	pr.Out.Header.Set("X-Forwarded-Host", host)
+	pr.Out.Header.Set("X-Forwarded-Proto", proto)
+}
+
+// proxyErrorHandler: upstream tidak bisa dihubungi atau tidak menjawab.
+// Client dapat JSON yang sama formatnya dengan error gateway lain.
+func proxyErrorHandler(rt *route) func(http.ResponseWriter, *http.Request, error) {
+	return func(w http.ResponseWriter, req *http.Request, err error) {
+		a := attemptFrom(req.Context())
+
+		// context.Canceled = client yang memutus koneksi, bukan salah upstream
+		if errors.Is(err, context.Canceled) {
+			w.WriteHeader(statusClientClosed)
+			return
+		}
+		a.err = err
+
+		status, body := http.StatusBadGateway, gin.H{
+			"error": "Bad Gateway",
+			"code":  "upstream_unavailable",
+			"route": rt.Prefix,
+		}
+		if errors.Is(err, context.DeadlineExceeded) {
+			status, body = http.StatusGatewayTimeout, gin.H{
+				"error": "Gateway Timeout",
+				"code":  "upstream_timeout",
+				"route": rt.Prefix,
+			}
+		}
+
+		logging.FromContext(req.Context()).Error("proxy ke upstream gagal",
+			"upstream", a.upstream.URL.String(), "status", status, "error", err)
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(body)
+	}
+}
+
+// Helper: Proxy Request ke salah satu upstream di pool route
+func proxyRequest(rt *route) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		upstream, err := rt.pool.Pick()
+		var openErr *CircuitOpenError
+		if errors.As(err, &openErr) {
+			// Fail fast: jangan biarkan client menunggu upstream yang sedang bermasalah
+			upstreamRejected.WithLabelValues(rt.Prefix, "circuit_open").Inc()
+			retryAfter := int(math.Ceil(openErr.RetryAfter.Seconds()))
+			c.Header("Retry-After", strconv.Itoa(retryAfter))
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{
+				"error":       "Service Unavailable",
+				"code":        "circuit_open",
+				"route":       rt.Prefix,
+				"retry_after": retryAfter,
+			})
+			return
+		}
+		if err != nil {
+			upstreamRejected.WithLabelValues(rt.Prefix, "no_healthy_upstream").Inc()
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{
+				"error": "Service Unavailable",
+				"code":  "no_healthy_upstream",
+				"route": rt.Prefix,
+			})
+			return
+		}
+
+		// Hasil request dicatat ke pool untuk passive health check & breaker
+		done := rt.pool.Acquire(upstream)
+		attempt := &proxyAttempt{upstream: upstream}
+		req := c.Request.WithContext(context.WithValue(c.Request.Context(), proxyAttemptKey{}, attempt))
+
+		if rt.isStream(c.Request.URL.Path) {
+			rt.serveStream(c, req)
+			observeUpstreamError(rt, upstream, attempt.status, attempt.err)
+		} else {
+			start := time.Now()
+			rt.proxy.ServeHTTP(c.Writer, req)
+			observeUpstream(rt, upstream, start, attempt.status, attempt.err)
+		}
+		done(attempt.status, attempt.err)
+	}
+}
+
+// trustedProxies: daftar IP/CIDR dari config trusted_proxies (format sama
+// dengan gin.SetTrustedProxies).
+type trustedProxies []netip.Prefix
+
+func parseTrustedProxies(entries []string) (trustedProxies, error) {
+	var out trustedProxies
+	for _, e := range entries {
+		if strings.Contains(e, "/") {
+			p, err := netip.ParsePrefix(e)
+			if err != nil {
+				return nil, fmt.Errorf("trusted_proxies: %w", err)
+			}
+			out = append(out, p.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(e)
+		if err != nil {
+			return nil, fmt.Errorf("trusted_proxies: %w", err)
+		}
+		out = append(out, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return out, nil
+}
+
+func (t trustedProxies) contains(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, p := range t {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
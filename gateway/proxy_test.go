@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newTestRoute: route dengan satu upstream, diproxy lewat gin seperti di buildRouter.
+func newTestRoute(t *testing.T, upstream string, trusted []string) http.Handler {
+	t.Helper()
+	rc := RouteConfig{Prefix: "/order", Upstreams: []string{upstream}, Balancer: BalancerRoundRobin}
+	pool, err := NewPool(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tp, err := parseTrustedProxies(trusted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rt := &route{RouteConfig: rc, pool: pool}
+	rt.proxy = newRouteProxy(rt, newTransport(TransportConfig{}), tp)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Any("/order/*proxyPath", proxyRequest(rt))
+	return r
+}
+
+func TestProxyForwardedAndHopByHopHeaders(t *testing.T) {
+	var got http.Header
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+		got.Set("Host", r.Host)
+	}))
+	defer upstream.Close()
+
+	cases := []struct {
+		name    string
+		trusted []string
+		wantXFF string
+	}{
+		{"client langsung", nil, "127.0.0.1"},
+		{"lewat trusted proxy", []string{"127.0.0.0/8"}, "198.51.100.7, 127.0.0.1"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Server sungguhan, bukan ResponseRecorder: ReverseProxy butuh CloseNotifier
+			gw := httptest.NewServer(newTestRoute(t, upstream.URL, tc.trusted))
+			defer gw.Close()
+
+			req, _ := http.NewRequest(http.MethodGet, gw.URL+"/order/list", nil)
+			req.Header.Set("X-Forwarded-For", "198.51.100.7")
+			req.Header.Set("Connection", "X-Secret")
+			req.Header.Set("X-Secret", "bocor")
+			req.Header.Set("Keep-Alive", "timeout=5")
+
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("status = %d", resp.StatusCode)
+			}
+			if xff := got.Get("X-Forwarded-For"); xff != tc.wantXFF {
+				t.Errorf("X-Forwarded-For = %q, want %q", xff, tc.wantXFF)
+			}
+			if p := got.Get("X-Forwarded-Proto"); p != "http" {
+				t.Errorf("X-Forwarded-Proto = %q", p)
+			}
+			for _, hop := range []string{"X-Secret", "Keep-Alive"} {
+				if got.Get(hop) != "" {
+					t.Errorf("header hop-by-hop %s ikut diteruskan", hop)
+				}
+			}
+		})
+	}
+}
+
+func TestProxyUpstreamDownReturnsJSON(t *testing.T) {
+	upstream := httptest.NewServer(http.NotFoundHandler())
+	addr := upstream.URL
+	upstream.Close() // port tertutup: koneksi ditolak
+
+	gw := httptest.NewServer(newTestRoute(t, addr, nil))
+	defer gw.Close()
+
+	resp, err := http.Get(gw.URL + "/order/list")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusBadGateway {
+		t.Fatalf("status = %d, want 502", resp.StatusCode)
+	}
+	var body map[string]any
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatalf("body bukan JSON: %v", err)
+	}
+	if body["code"] != "upstream_unavailable" || body["route"] != "/order" {
+		t.Fatalf("body = %v", body)
+	}
+}
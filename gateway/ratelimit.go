@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"shared/logging"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	RateLimitMemory = "memory"
+	RateLimitRedis  = "redis"
+)
+
+// RateLimitResult adalah kondisi bucket setelah satu request diperhitungkan.
+type RateLimitResult struct {
+	Allowed    bool
+	Remaining  float64       // token tersisa
+	RetryAfter time.Duration // kapan 1 token tersedia lagi (kalau ditolak)
+	ResetAfter time.Duration // kapan bucket penuh lagi
+}
+
+// Limiter adalah token bucket: kapasitas burst, diisi ulang rate token/detik.
+type Limiter interface {
+	Allow(ctx context.Context, key string, rate float64, burst int) (RateLimitResult, error)
+}
+
+func newResult(allowed bool, tokens, rate float64, burst int) RateLimitResult {
+	res := RateLimitResult{
+		Allowed:    allowed,
+		Remaining:  tokens,
+		ResetAfter: time.Duration((float64(burst) - tokens) / rate * float64(time.Second)),
+	}
+	if !allowed {
+		res.RetryAfter = time.Duration((1 - tokens) / rate * float64(time.Second))
+	}
+	return res
+}
+
+// --- MEMORY BACKEND ---
+// Cukup untuk satu instance gateway. Dengan beberapa replica, pakai Redis.
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+type MemoryLimiter struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+}
+
+func NewMemoryLimiter() *MemoryLimiter {
+	l := &MemoryLimiter{buckets: make(map[string]*bucket)}
+	go l.cleanup(time.Minute)
+	return l
+}
+
+func (l *MemoryLimiter) Allow(_ context.Context, key string, rate float64, burst int) (RateLimitResult, error) {
+	now := time.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(burst), last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(float64(burst), b.tokens+now.Sub(b.last).Seconds()*rate)
+	b.last = now
+
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+	return newResult(allowed, b.tokens, rate, burst), nil
+}
+
+// cleanup membuang bucket yang lama tidak dipakai supaya map tidak tumbuh terus.
+func (l *MemoryLimiter) cleanup(every time.Duration) {
+	for range time.Tick(every) {
+		l.mu.Lock()
+		for key, b := range l.buckets {
+			if time.Since(b.last) > 10*time.Minute {
+				delete(l.buckets, key)
+			}
+		}
+		l.mu.Unlock()
+	}
+}
+
+// --- REDIS BACKEND ---
+// Counter dibagi antar replica gateway. Waktu diambil dari Redis (TIME) supaya
+// jam tiap replica yang berbeda tidak mempengaruhi hasil.
+
+var tokenBucketScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+
+local data = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(data[1]) or burst
+local ts = tonumber(data[2]) or now
+
+tokens = math.min(burst, tokens + (now - ts) / 1000 * rate)
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', now)
+redis.call('PEXPIRE', KEYS[1], math.ceil(burst / rate * 1000) + 1000)
+return {allowed, tostring(tokens)}
+`)
+
+type RedisLimiter struct {
+	rdb *redis.Client
+}
+
+func NewRedisLimiter(rdb *redis.Client) *RedisLimiter {
+	return &RedisLimiter{rdb: rdb}
+}
+
+func (l *RedisLimiter) Allow(ctx context.Context, key string, rate float64, burst int) (RateLimitResult, error) {
+	vals, err := tokenBucketScript.Run(ctx, l.rdb, []string{key}, rate, burst).Slice()
+	if err != nil {
+		return RateLimitResult{}, err
+	}
+	allowed, _ := vals[0].(int64)
+	tokens, _ := strconv.ParseFloat(vals[1].(string), 64)
+	return newResult(allowed == 1, tokens, rate, burst), nil
+}
+
+// --- MIDDLEWARE ---
+
+// RateLimitMiddleware membatasi request per route. Route dengan auth dihitung
+// per user (claim "sub" dari AuthMiddleware) atau per API key, route publik
+// per IP client.
+func RateLimitMiddleware(limiter Limiter, rt *route) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ruleIdx, rule := rt.matchRateLimit(c.Request)
+		if rule == nil {
+			c.Next()
+			return
+		}
+
+		subject := "ip:" + c.ClientIP()
+		if key, ok := c.Get("api_key"); ok {
+			subject = "key:" + strconv.FormatInt(key.(*APIKeyIdentity).KeyID, 10)
+		} else if userID := c.GetString("user_id"); userID != "" {
+			subject = "user:" + userID
+		}
+		key := "ratelimit:" + rt.Prefix + ":" + strconv.Itoa(ruleIdx) + ":" + subject
+
+		if !allowRequest(c, limiter, key, rule.rate(), rule.Burst) {
+			return
+		}
+		c.Next()
+	}
+}
+
+// allowRequest mengambil satu token dari bucket key dan memasang header
+// X-RateLimit-*. False kalau request ditolak (response 429 sudah dikirim).
+func allowRequest(c *gin.Context, limiter Limiter, key string, rate float64, burst int) bool {
+	res, err := limiter.Allow(c.Request.Context(), key, rate, burst)
+	if err != nil {
+		// Fail open: gangguan Redis jangan sampai mematikan semua route
+		logging.FromContext(c.Request.Context()).Warn("rate limiter error, request diloloskan", "error", err)
+		return true
+	}
+
+	c.Header("X-RateLimit-Limit", strconv.Itoa(burst))
+	c.Header("X-RateLimit-Remaining", strconv.Itoa(int(res.Remaining)))
+	c.Header("X-RateLimit-Reset", strconv.Itoa(int(math.Ceil(res.ResetAfter.Seconds()))))
+
+	if !res.Allowed {
+		retryAfter := int(math.Ceil(res.RetryAfter.Seconds()))
+		c.Header("Retry-After", strconv.Itoa(retryAfter))
+		c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+			"error":       "Too Many Requests",
+			"retry_after": retryAfter,
+		})
+		return false
+	}
+	return true
+}
+
+// matchRateLimit mencari aturan pertama yang cocok dengan request.
+func (rt *route) matchRateLimit(req *http.Request) (int, *RateLimitRule) {
+	for i := range rt.RateLimit {
+		rule := &rt.RateLimit[i]
+		if rule.Path != "" && rule.Path != req.URL.Path {
+			continue
+		}
+		if len(rule.Methods) > 0 && !slices.Contains(rule.Methods, req.Method) {
+			continue
+		}
+		return i, rule
+	}
+	return -1, nil
+}
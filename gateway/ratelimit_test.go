@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestMemoryLimiterRefill(t *testing.T) {
+	l := NewMemoryLimiter()
+	ctx := context.Background()
+
+	// burst 2, diisi 20 token/detik (1 token per 50ms)
+	for i := range 2 {
+		if res, _ := l.Allow(ctx, "k", 20, 2); !res.Allowed {
+			t.Fatalf("request ke-%d harus lolos", i+1)
+		}
+	}
+	res, _ := l.Allow(ctx, "k", 20, 2)
+	if res.Allowed || res.RetryAfter <= 0 || res.RetryAfter > 50*time.Millisecond {
+		t.Fatalf("request ke-3 = %+v, want ditolak dengan RetryAfter <= 50ms", res)
+	}
+	if res, _ := l.Allow(ctx, "lain", 20, 2); !res.Allowed {
+		t.Fatal("bucket key lain tidak boleh ikut habis")
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if res, _ := l.Allow(ctx, "k", 20, 2); !res.Allowed {
+		t.Fatal("token harus terisi lagi setelah 50ms")
+	}
+}
+
+func TestRedisLimiterRefill(t *testing.T) {
+	mr := miniredis.RunT(t)
+	l := NewRedisLimiter(redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+	ctx := context.Background()
+	now := time.Now()
+	mr.SetTime(now)
+
+	// burst 2, 1 token per detik; waktu diambil dari Redis (TIME)
+	for i := range 2 {
+		res, err := l.Allow(ctx, "ratelimit:test", 1, 2)
+		if err != nil || !res.Allowed {
+			t.Fatalf("request ke-%d = %+v %v, want lolos", i+1, res, err)
+		}
+	}
+	res, _ := l.Allow(ctx, "ratelimit:test", 1, 2)
+	if res.Allowed || res.Remaining >= 1 || res.RetryAfter <= 0 || res.RetryAfter > time.Second {
+		t.Fatalf("request ke-3 = %+v, want ditolak dengan RetryAfter <= 1s", res)
+	}
+	if ttl := mr.TTL("ratelimit:test"); ttl <= 0 {
+		t.Fatalf("bucket tanpa TTL (%v), key tidak akan pernah hilang", ttl)
+	}
+
+	mr.SetTime(now.Add(1500 * time.Millisecond))
+	if res, _ := l.Allow(ctx, "ratelimit:test", 1, 2); !res.Allowed {
+		t.Fatalf("setelah 1.5s = %+v, want lolos", res)
+	}
+	if res, _ := l.Allow(ctx, "ratelimit:test", 1, 2); res.Allowed {
+		t.Fatal("hanya satu token yang terisi dalam 1.5s")
+	}
+}
+
+func newRateLimitRouter(limiter Limiter) *gin.Engine {
+	rt := &route{RouteConfig: RouteConfig{Prefix: "/order", RateLimit: []RateLimitRule{
+		{Path: "/order/create", Methods: []string{http.MethodPost}, Requests: 2, Period: Duration(time.Minute), Burst: 2},
+	}}}
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Any("/order/*proxyPath", func(c *gin.Context) {
+		// Pengganti AuthMiddleware: claim sub dari header
+		if sub := c.GetHeader("X-Test-Sub"); sub != "" {
+			c.Set("user_id", sub)
+		}
+	}, RateLimitMiddleware(limiter, rt), func(c *gin.Context) {
+		c.String(http.StatusOK, "ok")
+	})
+	return r
+}
+
+func rateLimited(r http.Handler, method, path, sub, ip string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	req.RemoteAddr = ip + ":1234"
+	if sub != "" {
+		req.Header.Set("X-Test-Sub", sub)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	r := newRateLimitRouter(NewMemoryLimiter())
+
+	first := rateLimited(r, http.MethodPost, "/order/create", "7", "192.0.2.1")
+	if first.Code != http.StatusOK || first.Header().Get("X-RateLimit-Limit") != "2" || first.Header().Get("X-RateLimit-Remaining") != "1" {
+		t.Fatalf("request pertama = %d %v", first.Code, first.Header())
+	}
+	rateLimited(r, http.MethodPost, "/order/create", "7", "192.0.2.1")
+
+	// User yang sama dari IP lain tetap kena: dihitung per sub
+	w := rateLimited(r, http.MethodPost, "/order/create", "7", "192.0.2.2")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("request ke-3 user 7 = %d, want 429", w.Code)
+	}
+	if w.Header().Get("Retry-After") == "" || w.Header().Get("X-RateLimit-Remaining") != "0" || w.Header().Get("X-RateLimit-Reset") == "" {
+		t.Fatalf("header 429 tidak lengkap: %v", w.Header())
+	}
+	if !strings.Contains(w.Body.String(), `"retry_after"`) {
+		t.Fatalf("body 429 = %s", w.Body)
+	}
+
+	// User lain dari IP yang sama punya bucket sendiri
+	if w := rateLimited(r, http.MethodPost, "/order/create", "8", "192.0.2.1"); w.Code != http.StatusOK {
+		t.Fatalf("user 8 = %d, want 200", w.Code)
+	}
+
+	// Tanpa user: per IP
+	for range 2 {
+		rateLimited(r, http.MethodPost, "/order/create", "", "198.51.100.1")
+	}
+	if w := rateLimited(r, http.MethodPost, "/order/create", "", "198.51.100.1"); w.Code != http.StatusTooManyRequests {
+		t.Fatalf("IP yang sama = %d, want 429", w.Code)
+	}
+	if w := rateLimited(r, http.MethodPost, "/order/create", "", "198.51.100.2"); w.Code != http.StatusOK {
+		t.Fatalf("IP lain = %d, want 200", w.Code)
+	}
+
+	// Path/method di luar aturan tidak dibatasi
+	w = rateLimited(r, http.MethodGet, "/order/create", "7", "192.0.2.1")
+	if w.Code != http.StatusOK || w.Header().Get("X-RateLimit-Limit") != "" {
+		t.Fatalf("GET tanpa aturan = %d %v", w.Code, w.Header())
+	}
+}
+
+type failingLimiter struct{}
+
+func (failingLimiter) Allow(context.Context, string, float64, int) (RateLimitResult, error) {
+	return RateLimitResult{}, errors.New("redis down")
+}
+
+func TestRateLimitFailOpen(t *testing.T) {
+	r := newRateLimitRouter(failingLimiter{})
+	for range 3 {
+		if w := rateLimited(r, http.MethodPost, "/order/create", "7", "192.0.2.1"); w.Code != http.StatusOK {
+			t.Fatalf("limiter error = %d, want request diloloskan", w.Code)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/redis/go-redis/v9"
+)
+
+// Denylist mengecek access token yang sudah dicabut auth-service (logout,
+// token reuse). Format key sama dengan auth-service/internal/service/revocation.go.
+//
+// Hasil cek disimpan sebentar di memori supaya tidak setiap request ke Redis.
+// Konsekuensinya token yang baru dicabut masih bisa lolos paling lama CacheTTL.
+type Denylist struct {
+	rdb *redis.Client
+	ttl time.Duration
+
+	mu    sync.Mutex
+	cache map[string]denyEntry // key: jti
+}
+
+type denyEntry struct {
+	revoked bool
+	expires time.Time
+}
+
+func NewDenylist(rdb *redis.Client, cacheTTL time.Duration) *Denylist {
+	d := &Denylist{rdb: rdb, ttl: cacheTTL, cache: make(map[string]denyEntry)}
+	go d.cleanup()
+	return d
+}
+
+// Revoked: true kalau token (jti), sesinya (sid), atau semua token user
+// yang terbit (iat_ms) sebelum waktu tertentu sudah dicabut.
+func (d *Denylist) Revoked(ctx context.Context, claims jwt.MapClaims) (bool, error) {
+	jti, _ := claims["jti"].(string)
+	sid, _ := claims["sid"].(string)
+	if jti == "" || sid == "" {
+		return true, nil // token tanpa jti/sid tidak bisa dicabut, jadi tidak diterima
+	}
+
+	now := time.Now()
+	d.mu.Lock()
+	entry, ok := d.cache[jti]
+	d.mu.Unlock()
+	if ok && now.Before(entry.expires) {
+		return entry.revoked, nil
+	}
+
+	userKey := "denylist:user:" + subject(claims)
+	vals, err := d.rdb.MGet(ctx, "denylist:jti:"+jti, "denylist:sid:"+sid, userKey).Result()
+	if err != nil {
+		return false, err
+	}
+
+	revoked := vals[0] != nil || vals[1] != nil
+	if since, ok := vals[2].(string); ok && !revoked {
+		revokedAt, _ := strconv.ParseInt(since, 10, 64)
+		issuedAt, ok := claims["iat_ms"].(float64)
+		revoked = !ok || int64(issuedAt) < revokedAt
+	}
+
+	d.mu.Lock()
+	d.cache[jti] = denyEntry{revoked: revoked, expires: now.Add(d.ttl)}
+	d.mu.Unlock()
+	return revoked, nil
+}
+
+func (d *Denylist) cleanup() {
+	for range time.Tick(time.Minute) {
+		now := time.Now()
+		d.mu.Lock()
+		for jti, e := range d.cache {
+			if now.After(e.expires) {
+				delete(d.cache, jti)
+			}
+		}
+		d.mu.Unlock()
+	}
+}
+
+// subject: claim sub sebagai string ("7", bukan "7e+00").
+func subject(claims jwt.MapClaims) string {
+	switch v := claims["sub"].(type) {
+	case float64:
+		return strconv.FormatInt(int64(v), 10)
+	case string:
+		return v
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestDenylistUserRevocation(t *testing.T) {
+	mr := miniredis.RunT(t)
+	d := NewDenylist(redis.NewClient(&redis.Options{Addr: mr.Addr()}), 0)
+
+	// Dicabut di tengah detik; token sebelum dan sesudahnya di detik yang sama
+	revokedAt := time.UnixMilli(1_800_000_000_500)
+	mr.Set("denylist:user:7", strconv.FormatInt(revokedAt.UnixMilli(), 10))
+	claims := func(jti string, iat time.Time) jwt.MapClaims {
+		return jwt.MapClaims{"sub": float64(7), "jti": jti, "sid": "s1", "iat": float64(iat.Unix()), "iat_ms": float64(iat.UnixMilli())}
+	}
+
+	cases := []struct {
+		name string
+		iat  time.Time
+		want bool
+	}{
+		{"sebelum revoke", revokedAt.Add(-300 * time.Millisecond), true},
+		{"sesudah revoke, detik yang sama", revokedAt.Add(300 * time.Millisecond), false},
+		{"detik berikutnya", revokedAt.Add(time.Second), false},
+	}
+	for i, tc := range cases {
+		got, err := d.Revoked(context.Background(), claims(strconv.Itoa(i), tc.iat))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tc.want {
+			t.Errorf("%s: revoked = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
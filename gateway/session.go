@@ -0,0 +1,497 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"shared/logging"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	HeaderCSRF = "X-CSRF-Token"
+
+	// Access token di-refresh kalau sisa umurnya kurang dari ini, supaya
+	// tidak kedaluwarsa di tengah jalan menuju upstream.
+	sessionRefreshBefore = time.Minute
+	sessionLockTTL       = 10 * time.Second
+	sessionMaxBody       = 64 << 10
+)
+
+var (
+	errSessionExpired = errors.New("sesi berakhir, refresh token ditolak auth-service")
+	errSessionBusy    = errors.New("refresh sesi sedang berjalan di request lain")
+)
+
+// session: token milik satu login, disimpan di Redis. Browser hanya tahu id-nya.
+type session struct {
+	AccessToken  string    `json:"access_token"`
+	RefreshToken string    `json:"refresh_token"`
+	AccessExp    time.Time `json:"access_exp"`
+	CSRFToken    string    `json:"csrf_token"`
+	DeviceID     string    `json:"device_id,omitempty"`
+	UserID       string    `json:"user_id"`
+	Username     string    `json:"username"`
+	Roles        []string  `json:"roles,omitempty"`
+}
+
+// setAccessToken mengisi token beserta data user dari claim-nya. Token
+// datang langsung dari auth-service, jadi tidak perlu diverifikasi ulang di
+// sini (AuthMiddleware tetap memverifikasi saat dipakai).
+func (s *session) setAccessToken(token string) error {
+	claims := jwt.MapClaims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(token, claims); err != nil {
+		return err
+	}
+	exp, err := claims.GetExpirationTime()
+	if err != nil || exp == nil {
+		return fmt.Errorf("access token tanpa exp")
+	}
+	s.AccessToken, s.AccessExp = token, exp.Time
+	s.UserID = subject(claims)
+	s.Username, _ = claims["name"].(string)
+	s.Roles = s.Roles[:0]
+	if roles, ok := claims["roles"].([]any); ok {
+		for _, r := range roles {
+			if role, ok := r.(string); ok {
+				s.Roles = append(s.Roles, role)
+			}
+		}
+	}
+	return nil
+}
+
+// SessionStore: sesi di Redis. Key memakai hash id, jadi isi Redis tidak bisa
+// dipakai langsung sebagai cookie.
+type SessionStore struct {
+	rdb *redis.Client
+	ttl time.Duration
+}
+
+func sessionKey(id string) string {
+	sum := sha256.Sum256([]byte(id))
+	return "session:" + hex.EncodeToString(sum[:])
+}
+
+// Get: nil tanpa error kalau sesi tidak ada / sudah kedaluwarsa.
+func (st *SessionStore) Get(ctx context.Context, id string) (*session, error) {
+	raw, err := st.rdb.Get(ctx, sessionKey(id)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var s session
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (st *SessionStore) Save(ctx context.Context, id string, s *session) error {
+	raw, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	return st.rdb.Set(ctx, sessionKey(id), raw, st.ttl).Err()
+}
+
+func (st *SessionStore) Delete(ctx context.Context, id string) error {
+	return st.rdb.Del(ctx, sessionKey(id)).Err()
+}
+
+// lock: hanya satu request (lintas replica) yang boleh refresh satu sesi.
+// Refresh token dirotasi auth-service, dua refresh bersamaan dengan token
+// yang sama dianggap reuse dan seluruh sesi dicabut.
+func (st *SessionStore) lock(ctx context.Context, id string) (bool, error) {
+	return st.rdb.SetNX(ctx, sessionKey(id)+":lock", 1, sessionLockTTL).Result()
+}
+
+func (st *SessionStore) unlock(ctx context.Context, id string) {
+	st.rdb.Del(ctx, sessionKey(id)+":lock")
+}
+
+// Sessions: endpoint /session/* dan SessionMiddleware untuk mode BFF.
+type Sessions struct {
+	cfg     SessionConfig
+	store   *SessionStore
+	client  *http.Client
+	origins map[string]bool // cors.allow_origins, untuk upgrade WebSocket
+}
+
+func NewSessions(cfg SessionConfig, allowOrigins []string, rdb *redis.Client, transport http.RoundTripper) *Sessions {
+	origins := make(map[string]bool, len(allowOrigins))
+	for _, o := range allowOrigins {
+		origins[o] = true
+	}
+	return &Sessions{
+		cfg:     cfg,
+		store:   &SessionStore{rdb: rdb, ttl: time.Duration(cfg.TTL)},
+		client:  &http.Client{Timeout: 10 * time.Second, Transport: transport},
+		origins: origins,
+	}
+}
+
+func (s *Sessions) csrfCookie() string {
+	return s.cfg.CookieName + "_csrf"
+}
+
+// Login: POST /session/login, body sama dengan /auth/login. Token dari
+// auth-service disimpan di sesi; browser menerima cookie sesi (HttpOnly) dan
+// cookie CSRF (bisa dibaca JS, dikirim balik lewat header X-CSRF-Token).
+// User dengan 2FA menerima challenge token dan melanjutkan ke Login2FA.
+func (s *Sessions) Login(c *gin.Context) {
+	s.login(c, "/auth/login")
+}
+
+// Login2FA: POST /session/login/2fa, body sama dengan /auth/login/2fa.
+func (s *Sessions) Login2FA(c *gin.Context) {
+	s.login(c, "/auth/login/2fa")
+}
+
+func (s *Sessions) login(c *gin.Context, path string) {
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, sessionMaxBody))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Bad Request"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	deviceID := c.GetHeader("X-Device-ID")
+	res, err := s.callAuth(ctx, c, path, body, &session{DeviceID: deviceID})
+	if err != nil {
+		logging.FromContext(ctx).Error("login ke auth-service gagal", "error", err)
+		c.AbortWithStatusJSON(http.StatusBadGateway, gin.H{"error": "Bad Gateway"})
+		return
+	}
+	if res.status != http.StatusOK {
+		// Error dari auth-service (password salah, validasi, akun dikunci, ...)
+		// diteruskan apa adanya
+		if res.retryAfter != "" {
+			c.Header("Retry-After", res.retryAfter)
+		}
+		c.Data(res.status, "application/json", res.body)
+		return
+	}
+	if res.twoFactor {
+		// Challenge 2FA diteruskan, sesi baru dibuat setelah kodenya benar
+		c.Data(res.status, "application/json", res.body)
+		return
+	}
+
+	sess := &session{RefreshToken: res.refreshToken, DeviceID: deviceID, CSRFToken: randomToken()}
+	if err := sess.setAccessToken(res.accessToken); err != nil || sess.RefreshToken == "" {
+		logging.FromContext(ctx).Error("response login auth-service tidak lengkap", "error", err)
+		c.AbortWithStatusJSON(http.StatusBadGateway, gin.H{"error": "Bad Gateway"})
+		return
+	}
+
+	id := randomToken()
+	if err := s.store.Save(ctx, id, sess); err != nil {
+		logging.FromContext(ctx).Error("gagal menyimpan sesi", "error", err)
+		c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "Service Unavailable"})
+		return
+	}
+	s.setCookies(c, id, sess.CSRFToken)
+	c.JSON(http.StatusOK, sessionInfo(sess))
+}
+
+// Current: GET /session, data user & token CSRF untuk sesi aktif.
+func (s *Sessions) Current(c *gin.Context) {
+	id, sess, ok := s.load(c)
+	if !ok {
+		return
+	}
+	if id == "" {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	c.JSON(http.StatusOK, sessionInfo(sess))
+}
+
+// Logout: POST /session/logout. Sesi di auth-service ikut dicabut (refresh
+// token & access token masuk denylist), lalu sesi & cookie dihapus.
+func (s *Sessions) Logout(c *gin.Context) {
+	id, sess, ok := s.load(c)
+	if !ok {
+		return
+	}
+	if id != "" {
+		if !s.checkCSRF(c, sess) {
+			return
+		}
+		ctx := c.Request.Context()
+		if _, err := s.callAuth(ctx, c, "/auth/logout", nil, sess); err != nil {
+			logging.FromContext(ctx).Warn("logout ke auth-service gagal, sesi gateway tetap dihapus", "error", err)
+		}
+		if err := s.store.Delete(ctx, id); err != nil {
+			logging.FromContext(ctx).Error("gagal menghapus sesi", "error", err)
+		}
+	}
+	s.clearCookies(c)
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out"})
+}
+
+// SessionMiddleware: untuk request tanpa header Authorization, access token
+// diambil dari sesi cookie (di-refresh dulu kalau hampir kedaluwarsa) lalu
+// dipasang sebagai Bearer untuk AuthMiddleware. Method selain GET/HEAD/OPTIONS
+// wajib membawa X-CSRF-Token yang cocok dengan sesi.
+func (s *Sessions) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetHeader("Authorization") != "" {
+			c.Next()
+			return
+		}
+		id, sess, ok := s.load(c)
+		if !ok {
+			return
+		}
+		if id == "" {
+			c.Next() // tanpa sesi: AuthMiddleware yang menolak
+			return
+		}
+		if !s.checkCSRF(c, sess) || !s.checkOrigin(c) {
+			return
+		}
+
+		if time.Until(sess.AccessExp) < sessionRefreshBefore {
+			ctx := c.Request.Context()
+			fresh, err := s.refresh(ctx, c, id, sess)
+			switch {
+			case errors.Is(err, errSessionExpired):
+				s.store.Delete(ctx, id)
+				s.clearCookies(c)
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Session Expired"})
+				return
+			case err != nil:
+				logging.FromContext(ctx).Error("refresh sesi gagal", "error", err)
+				c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "Service Unavailable"})
+				return
+			}
+			sess = fresh
+		}
+		c.Request.Header.Set("Authorization", "Bearer "+sess.AccessToken)
+		c.Next()
+	}
+}
+
+// load membaca sesi dari cookie. id kosong = tidak ada sesi (cookie basi
+// dihapus). ok=false berarti response error sudah dikirim.
+func (s *Sessions) load(c *gin.Context) (id string, sess *session, ok bool) {
+	id, err := c.Cookie(s.cfg.CookieName)
+	if err != nil || id == "" {
+		return "", nil, true
+	}
+	sess, err = s.store.Get(c.Request.Context(), id)
+	if err != nil {
+		logging.FromContext(c.Request.Context()).Error("gagal membaca sesi", "error", err)
+		c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "Service Unavailable"})
+		return "", nil, false
+	}
+	if sess == nil {
+		s.clearCookies(c)
+		return "", nil, true
+	}
+	return id, sess, true
+}
+
+func (s *Sessions) checkCSRF(c *gin.Context, sess *session) bool {
+	switch c.Request.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	token := c.GetHeader(HeaderCSRF)
+	if token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(sess.CSRFToken)) != 1 {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Forbidden", "code": "csrf_token_invalid"})
+		return false
+	}
+	return true
+}
+
+// checkOrigin: upgrade WebSocket tidak dibatasi CORS dan browser tetap
+// mengirim cookie sesi, jadi tanpa cek ini halaman dari origin mana pun bisa
+// membuka socket atas nama user dan membaca isinya. Origin wajib ada di
+// cors.allow_origins.
+func (s *Sessions) checkOrigin(c *gin.Context) bool {
+	if !strings.EqualFold(c.GetHeader("Upgrade"), "websocket") || s.origins[c.GetHeader("Origin")] {
+		return true
+	}
+	logging.FromContext(c.Request.Context()).Warn("websocket dari origin yang tidak diizinkan ditolak", "origin", c.GetHeader("Origin"))
+	c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Forbidden", "code": "origin_not_allowed"})
+	return false
+}
+
+// refresh menukar refresh token sesi dengan pasangan token baru. Kalau request
+// lain sedang me-refresh sesi yang sama, access token lama dipakai selama
+// masih berlaku, atau ditunggu sampai hasil refresh tersimpan.
+func (s *Sessions) refresh(ctx context.Context, c *gin.Context, id string, sess *session) (*session, error) {
+	locked, err := s.store.lock(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !locked {
+		if time.Now().Before(sess.AccessExp) {
+			return sess, nil
+		}
+		for range 20 {
+			time.Sleep(150 * time.Millisecond)
+			fresh, err := s.store.Get(ctx, id)
+			if err != nil {
+				return nil, err
+			}
+			if fresh == nil {
+				return nil, errSessionExpired
+			}
+			if fresh.AccessExp.After(sess.AccessExp) {
+				return fresh, nil
+			}
+		}
+		return nil, errSessionBusy
+	}
+	defer s.store.unlock(context.WithoutCancel(ctx), id)
+
+	// Bisa saja sudah di-refresh request lain sebelum lock didapat
+	fresh, err := s.store.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if fresh == nil {
+		return nil, errSessionExpired
+	}
+	if time.Until(fresh.AccessExp) >= sessionRefreshBefore {
+		return fresh, nil
+	}
+
+	res, err := s.callAuth(ctx, c, "/auth/refresh", nil, fresh)
+	if err != nil {
+		return nil, err
+	}
+	if res.status == http.StatusUnauthorized {
+		return nil, errSessionExpired
+	}
+	if res.status != http.StatusOK || res.refreshToken == "" {
+		return nil, fmt.Errorf("refresh auth-service: status %d", res.status)
+	}
+	fresh.RefreshToken = res.refreshToken
+	if err := fresh.setAccessToken(res.accessToken); err != nil {
+		return nil, err
+	}
+	if err := s.store.Save(ctx, id, fresh); err != nil {
+		return nil, err
+	}
+	return fresh, nil
+}
+
+type authResult struct {
+	status       int
+	body         []byte
+	retryAfter   string // header Retry-After (login dibatasi)
+	accessToken  string
+	refreshToken string // dari cookie refresh_token di response
+	twoFactor    bool   // login butuh kode 2FA (body berisi challenge_token)
+}
+
+// callAuth memanggil endpoint auth-service atas nama browser. Refresh token
+// dikirim sebagai cookie, sama seperti kalau browser memanggil langsung.
+func (s *Sessions) callAuth(ctx context.Context, c *gin.Context, path string, body []byte, sess *session) (*authResult, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.cfg.AuthURL+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Forwarded-For", c.ClientIP()) // untuk throttling per IP di auth-service
+	if sess.DeviceID != "" {
+		req.Header.Set("X-Device-ID", sess.DeviceID)
+	}
+	if sess.RefreshToken != "" {
+		req.AddCookie(&http.Cookie{Name: "refresh_token", Value: sess.RefreshToken})
+	}
+	if sess.AccessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+sess.AccessToken)
+	}
+	logging.Propagate(req)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	res := &authResult{status: resp.StatusCode, retryAfter: resp.Header.Get("Retry-After")}
+	if res.body, err = io.ReadAll(io.LimitReader(resp.Body, sessionMaxBody)); err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusOK {
+		var tokens struct {
+			AccessToken       string `json:"access_token"`
+			TwoFactorRequired bool   `json:"two_factor_required"`
+		}
+		json.Unmarshal(res.body, &tokens)
+		res.accessToken, res.twoFactor = tokens.AccessToken, tokens.TwoFactorRequired
+		for _, ck := range resp.Cookies() {
+			if ck.Name == "refresh_token" && ck.MaxAge >= 0 {
+				res.refreshToken = ck.Value
+			}
+		}
+	}
+	return res, nil
+}
+
+func (s *Sessions) setCookies(c *gin.Context, id, csrf string) {
+	maxAge := int(time.Duration(s.cfg.TTL).Seconds())
+	http.SetCookie(c.Writer, s.cookie(s.cfg.CookieName, id, maxAge, true))
+	http.SetCookie(c.Writer, s.cookie(s.csrfCookie(), csrf, maxAge, false))
+}
+
+func (s *Sessions) clearCookies(c *gin.Context) {
+	http.SetCookie(c.Writer, s.cookie(s.cfg.CookieName, "", -1, true))
+	http.SetCookie(c.Writer, s.cookie(s.csrfCookie(), "", -1, false))
+}
+
+func (s *Sessions) cookie(name, value string, maxAge int, httpOnly bool) *http.Cookie {
+	sameSite := http.SameSiteLaxMode
+	if s.cfg.SameSite == "strict" {
+		sameSite = http.SameSiteStrictMode
+	}
+	return &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     "/",
+		Domain:   s.cfg.Domain,
+		MaxAge:   maxAge,
+		Secure:   s.cfg.Secure,
+		HttpOnly: httpOnly,
+		SameSite: sameSite,
+	}
+}
+
+func sessionInfo(sess *session) gin.H {
+	return gin.H{
+		"user_id":    sess.UserID,
+		"username":   sess.Username,
+		"roles":      sess.Roles,
+		"csrf_token": sess.CSRFToken,
+	}
+}
+
+func randomToken() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
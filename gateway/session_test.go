@@ -0,0 +1,273 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/redis/go-redis/v9"
+)
+
+// newFakeAuth: auth-service palsu. Access token dari login berumur ttl, dari
+// refresh 1 jam. Refresh token dirotasi dan token lama ditolak (seperti aslinya).
+func newFakeAuth(t *testing.T, ttl time.Duration, refreshes *atomic.Int32) *httptest.Server {
+	t.Helper()
+	var current atomic.Value
+	current.Store("rt-0")
+	issue := func(w http.ResponseWriter, ttl time.Duration) {
+		at, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+			"sub":   float64(7),
+			"name":  "budi",
+			"roles": []string{"user"},
+			"exp":   time.Now().Add(ttl).Unix(),
+		}).SignedString([]byte("test"))
+		rt := "rt-" + time.Now().Format(time.RFC3339Nano)
+		current.Store(rt)
+		http.SetCookie(w, &http.Cookie{Name: "refresh_token", Value: rt, Path: "/auth/refresh", MaxAge: 3600})
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"access_token":"` + at + `"}`))
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/auth/login":
+			body, _ := io.ReadAll(r.Body)
+			if strings.Contains(string(body), `"password":"pakai-2fa"`) {
+				w.Write([]byte(`{"two_factor_required":true,"challenge_token":"ch-1","expires_in":300}`))
+				return
+			}
+			if !strings.Contains(string(body), `"password":"benar"`) {
+				w.WriteHeader(http.StatusUnauthorized)
+				w.Write([]byte(`{"error":"Email atau password salah"}`))
+				return
+			}
+			issue(w, ttl)
+		case "/auth/login/2fa":
+			body, _ := io.ReadAll(r.Body)
+			if string(body) != `{"challenge_token":"ch-1","code":"123456"}` {
+				w.WriteHeader(http.StatusUnauthorized)
+				w.Write([]byte(`{"error":"kode 2FA salah"}`))
+				return
+			}
+			issue(w, ttl)
+		case "/auth/refresh":
+			refreshes.Add(1)
+			ck, err := r.Cookie("refresh_token")
+			if err != nil || ck.Value != current.Load() {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			issue(w, time.Hour)
+		case "/auth/logout":
+			w.Write([]byte(`{"message":"Logged out"}`))
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func newSessionRouter(t *testing.T, auth *httptest.Server) *gin.Engine {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { rdb.Close() })
+
+	cfg := SessionConfig{Enabled: true, AuthURL: auth.URL}
+	if err := cfg.validate(RedisConfig{Addr: mr.Addr()}); err != nil {
+		t.Fatal(err)
+	}
+	s := NewSessions(cfg, []string{"http://localhost:3000"}, rdb, http.DefaultTransport)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/session", s.Current)
+	r.POST("/session/login", s.Login)
+	r.POST("/session/login/2fa", s.Login2FA)
+	r.POST("/session/logout", s.Logout)
+	r.Any("/order/*proxyPath", s.Middleware(), func(c *gin.Context) {
+		c.String(http.StatusOK, c.GetHeader("Authorization"))
+	})
+	return r
+}
+
+func do(r http.Handler, method, path, body string, cookies []*http.Cookie, header map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	for _, ck := range cookies {
+		req.AddCookie(ck)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func login(t *testing.T, r http.Handler) (cookies []*http.Cookie, csrf string) {
+	t.Helper()
+	w := do(r, http.MethodPost, "/session/login", `{"email":"budi@example.com","password":"benar"}`, nil, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("login status = %d (%s)", w.Code, w.Body)
+	}
+	for _, ck := range w.Result().Cookies() {
+		switch ck.Name {
+		case "session":
+			if !ck.HttpOnly || ck.SameSite != http.SameSiteLaxMode {
+				t.Fatalf("cookie sesi harus HttpOnly & SameSite=Lax: %+v", ck)
+			}
+			if strings.Contains(w.Body.String(), ck.Value) {
+				t.Fatal("id sesi tidak boleh ada di body")
+			}
+		case "session_csrf":
+			csrf = ck.Value
+		}
+		cookies = append(cookies, ck)
+	}
+	if len(cookies) != 2 || csrf == "" || !strings.Contains(w.Body.String(), csrf) {
+		t.Fatalf("cookie login = %v, body = %s", cookies, w.Body)
+	}
+	return cookies, csrf
+}
+
+func TestSessionLogin(t *testing.T) {
+	var refreshes atomic.Int32
+	r := newSessionRouter(t, newFakeAuth(t, time.Hour, &refreshes))
+
+	if w := do(r, http.MethodPost, "/session/login", `{"email":"budi@example.com","password":"salah"}`, nil, nil); w.Code != http.StatusUnauthorized || len(w.Result().Cookies()) != 0 {
+		t.Fatalf("login gagal harus diteruskan tanpa cookie: %d %v", w.Code, w.Result().Cookies())
+	}
+
+	cookies, csrf := login(t, r)
+	if w := do(r, http.MethodGet, "/session", "", cookies, nil); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"username":"budi"`) {
+		t.Fatalf("GET /session = %d %s", w.Code, w.Body)
+	}
+	if w := do(r, http.MethodGet, "/order/list", "", cookies, nil); !strings.HasPrefix(w.Body.String(), "Bearer ey") {
+		t.Fatalf("access token tidak dipasang: %s", w.Body)
+	}
+
+	// Method yang mengubah data wajib membawa token CSRF
+	if w := do(r, http.MethodPost, "/order/create", `{}`, cookies, nil); w.Code != http.StatusForbidden {
+		t.Fatalf("POST tanpa CSRF = %d, want 403", w.Code)
+	}
+	if w := do(r, http.MethodPost, "/order/create", `{}`, cookies, map[string]string{HeaderCSRF: "palsu"}); w.Code != http.StatusForbidden {
+		t.Fatalf("POST dengan CSRF salah = %d, want 403", w.Code)
+	}
+	if w := do(r, http.MethodPost, "/order/create", `{}`, cookies, map[string]string{HeaderCSRF: csrf}); w.Code != http.StatusOK {
+		t.Fatalf("POST dengan CSRF = %d, want 200", w.Code)
+	}
+
+	// Header Authorization dari client dipakai apa adanya
+	if w := do(r, http.MethodPost, "/order/create", `{}`, nil, map[string]string{"Authorization": "Bearer lain"}); w.Body.String() != "Bearer lain" {
+		t.Fatalf("Authorization client ditimpa: %s", w.Body)
+	}
+
+	if w := do(r, http.MethodPost, "/session/logout", "", cookies, map[string]string{HeaderCSRF: csrf}); w.Code != http.StatusOK {
+		t.Fatalf("logout = %d", w.Code)
+	}
+	if w := do(r, http.MethodGet, "/session", "", cookies, nil); w.Code != http.StatusUnauthorized {
+		t.Fatalf("sesi masih ada setelah logout: %d", w.Code)
+	}
+	if refreshes.Load() != 0 {
+		t.Fatalf("refresh dipanggil %d kali, want 0", refreshes.Load())
+	}
+}
+
+// Upgrade WebSocket tidak dilindungi CORS: dengan cookie sesi, origin wajib
+// ada di allow_origins.
+func TestSessionWebSocketOrigin(t *testing.T) {
+	var refreshes atomic.Int32
+	r := newSessionRouter(t, newFakeAuth(t, time.Hour, &refreshes))
+	cookies, _ := login(t, r)
+
+	ws := func(origin string) *httptest.ResponseRecorder {
+		h := map[string]string{"Connection": "Upgrade", "Upgrade": "websocket"}
+		if origin != "" {
+			h["Origin"] = origin
+		}
+		return do(r, http.MethodGet, "/order/ws", "", cookies, h)
+	}
+	if w := ws("https://evil.example"); w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), "origin_not_allowed") {
+		t.Fatalf("origin asing = %d %s, want 403", w.Code, w.Body)
+	}
+	if w := ws(""); w.Code != http.StatusForbidden {
+		t.Fatalf("tanpa Origin = %d, want 403", w.Code)
+	}
+	if w := ws("http://localhost:3000"); w.Code != http.StatusOK || !strings.HasPrefix(w.Body.String(), "Bearer ey") {
+		t.Fatalf("origin diizinkan = %d %s", w.Code, w.Body)
+	}
+	// GET biasa tidak terpengaruh (dibatasi CORS di browser)
+	if w := do(r, http.MethodGet, "/order/list", "", cookies, map[string]string{"Origin": "https://evil.example"}); w.Code != http.StatusOK {
+		t.Fatalf("GET biasa = %d, want 200", w.Code)
+	}
+}
+
+func TestSessionLogin2FA(t *testing.T) {
+	var refreshes atomic.Int32
+	r := newSessionRouter(t, newFakeAuth(t, time.Hour, &refreshes))
+
+	// Langkah pertama: challenge diteruskan, sesi belum dibuat
+	w := do(r, http.MethodPost, "/session/login", `{"email":"budi@example.com","password":"pakai-2fa"}`, nil, nil)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"challenge_token":"ch-1"`) || len(w.Result().Cookies()) != 0 {
+		t.Fatalf("login 2FA = %d %s %v", w.Code, w.Body, w.Result().Cookies())
+	}
+
+	if w := do(r, http.MethodPost, "/session/login/2fa", `{"challenge_token":"ch-1","code":"000000"}`, nil, nil); w.Code != http.StatusUnauthorized || len(w.Result().Cookies()) != 0 {
+		t.Fatalf("kode salah = %d %v", w.Code, w.Result().Cookies())
+	}
+
+	w = do(r, http.MethodPost, "/session/login/2fa", `{"challenge_token":"ch-1","code":"123456"}`, nil, nil)
+	if w.Code != http.StatusOK || len(w.Result().Cookies()) != 2 {
+		t.Fatalf("kode benar = %d %s %v", w.Code, w.Body, w.Result().Cookies())
+	}
+	if w := do(r, http.MethodGet, "/order/list", "", w.Result().Cookies(), nil); !strings.HasPrefix(w.Body.String(), "Bearer ey") {
+		t.Fatalf("access token tidak dipasang: %s", w.Body)
+	}
+}
+
+func TestSessionRefresh(t *testing.T) {
+	var refreshes atomic.Int32
+	// Token sudah masuk jendela refresh sejak diterbitkan
+	r := newSessionRouter(t, newFakeAuth(t, 30*time.Second, &refreshes))
+	cookies, _ := login(t, r)
+
+	// Request bersamaan hanya boleh memicu satu refresh, kalau tidak refresh
+	// token lama dipakai dua kali dan ditolak auth-service.
+	const n = 5
+	codes := make(chan int, n)
+	for range n {
+		go func() { codes <- do(r, http.MethodGet, "/order/list", "", cookies, nil).Code }()
+	}
+	for range n {
+		if code := <-codes; code != http.StatusOK {
+			t.Fatalf("request saat refresh = %d, want 200", code)
+		}
+	}
+	if got := refreshes.Load(); got != 1 {
+		t.Fatalf("refresh dipanggil %d kali, want 1", got)
+	}
+}
+
+func TestSessionRefreshRejected(t *testing.T) {
+	var refreshes atomic.Int32
+	auth := newFakeAuth(t, 30*time.Second, &refreshes)
+	r := newSessionRouter(t, auth)
+	cookies, _ := login(t, r)
+
+	// Login lain merotasi refresh token, token milik sesi ini jadi basi
+	do(r, http.MethodPost, "/session/login", `{"email":"budi@example.com","password":"benar"}`, nil, nil)
+
+	w := do(r, http.MethodGet, "/order/list", "", cookies, nil)
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("refresh ditolak = %d, want 401", w.Code)
+	}
+	if w := do(r, http.MethodGet, "/session", "", cookies, nil); w.Code != http.StatusUnauthorized {
+		t.Fatalf("sesi harus dihapus setelah refresh ditolak: %d", w.Code)
+	}
+}
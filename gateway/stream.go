@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"shared/logging"
+
+	"github.com/gin-gonic/gin"
+)
+
+// isStream: path termasuk stream.paths route ini.
+func (rt *route) isStream(urlPath string) bool {
+	for _, p := range rt.Stream.Paths {
+		if matchPath(p, urlPath) {
+			return true
+		}
+	}
+	return false
+}
+
+// StreamTokenMiddleware: EventSource dan WebSocket di browser tidak bisa
+// mengirim header Authorization, jadi khusus path stream token boleh lewat
+// query ?access_token=. Token dipindah ke header lalu dibuang dari URL supaya
+// tidak ikut diteruskan ke upstream. Dipasang sebelum AuthMiddleware.
+func StreamTokenMiddleware(rt *route) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !rt.isStream(c.Request.URL.Path) {
+			c.Next()
+			return
+		}
+		query := c.Request.URL.Query()
+		if token := query.Get("access_token"); token != "" {
+			if c.GetHeader("Authorization") == "" {
+				c.Request.Header.Set("Authorization", "Bearer "+token)
+			}
+			query.Del("access_token")
+			c.Request.URL.RawQuery = query.Encode()
+		}
+		c.Next()
+	}
+}
+
+// serveStream meneruskan koneksi SSE/WebSocket. Koneksi diputus kalau tidak
+// ada data (dua arah) selama idle_timeout, sudah max_duration, access
+// token-nya kedaluwarsa (client harus connect ulang dengan token baru), atau
+// gateway shutdown.
+func (rt *route) serveStream(c *gin.Context, req *http.Request) {
+	deadline := time.Now().Add(time.Duration(rt.Stream.MaxDuration))
+	if exp, ok := c.Get("token_exp"); ok {
+		if exp := exp.(time.Time); exp.Before(deadline) {
+			deadline = exp
+		}
+	}
+	ctx, cancel := context.WithDeadline(req.Context(), deadline)
+	defer cancel()
+	if rt.streams != nil {
+		stop := context.AfterFunc(rt.streams, cancel) // gateway shutdown
+		defer stop()
+	}
+
+	idle := &idleWatch{}
+	idle.touch()
+	go idle.run(ctx, cancel, time.Duration(rt.Stream.IdleTimeout))
+
+	// ReverseProxy panic ErrAbortHandler kalau body terputus setelah header
+	// terkirim. Kalau penyebabnya batas stream di atas, itu penutupan normal.
+	defer func() {
+		if rec := recover(); rec != nil {
+			if rec != http.ErrAbortHandler || ctx.Err() == nil {
+				panic(rec)
+			}
+			logging.FromContext(req.Context()).Info("stream ditutup gateway", "route", rt.Prefix, "reason", context.Cause(ctx).Error())
+		}
+	}()
+	rt.proxy.ServeHTTP(&streamWriter{ResponseWriter: c.Writer, idle: idle}, req.WithContext(ctx))
+}
+
+// idleWatch membatalkan koneksi kalau touch tidak dipanggil selama timeout.
+type idleWatch struct {
+	last atomic.Int64 // unix nano
+}
+
+func (w *idleWatch) touch() {
+	w.last.Store(time.Now().UnixNano())
+}
+
+func (w *idleWatch) run(ctx context.Context, cancel context.CancelFunc, timeout time.Duration) {
+	ticker := time.NewTicker(max(timeout/4, time.Second))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if time.Since(time.Unix(0, w.last.Load())) > timeout {
+				cancel()
+				return
+			}
+		}
+	}
+}
+
+// streamWriter: ResponseWriter yang mencatat aktivitas untuk idleWatch. SSE
+// lewat Write/Flush; WebSocket lewat koneksi hasil Hijack.
+type streamWriter struct {
+	http.ResponseWriter
+	idle *idleWatch
+}
+
+func (w *streamWriter) Write(b []byte) (int, error) {
+	w.idle.touch()
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *streamWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *streamWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, brw, err := http.NewResponseController(w.ResponseWriter).Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	return &idleConn{Conn: conn, idle: w.idle}, brw, nil
+}
+
+type idleConn struct {
+	net.Conn
+	idle *idleWatch
+}
+
+func (c *idleConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if n > 0 {
+		c.idle.touch()
+	}
+	return n, err
+}
+
+func (c *idleConn) Write(b []byte) (int, error) {
+	c.idle.touch()
+	return c.Conn.Write(b)
+}
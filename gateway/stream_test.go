@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newStreamGateway: gateway sungguhan (httptest.Server) di depan upstream,
+// karena ResponseRecorder tidak bisa memperlihatkan flush per event.
+func newStreamGateway(t *testing.T, upstream string, stream StreamConfig) *httptest.Server {
+	t.Helper()
+	rc := RouteConfig{Prefix: "/order", Upstreams: []string{upstream}, Balancer: BalancerRoundRobin, Stream: stream}
+	pool, err := NewPool(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rt := &route{RouteConfig: rc, pool: pool}
+	rt.proxy = newRouteProxy(rt, newTransport(TransportConfig{}), nil)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Any("/order/*proxyPath", proxyRequest(rt))
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// sseUpstream mengirim satu event tiap kali ada nilai di next, sampai
+// koneksi diputus. closed ditutup saat request upstream selesai.
+func sseUpstream(t *testing.T, next <-chan string, closed chan<- struct{}) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer close(closed)
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case data := <-next:
+				fmt.Fprintf(w, "data: %s\n\n", data)
+				w.(http.Flusher).Flush()
+			}
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// readEvent membaca satu baris "data: ..." dengan batas waktu.
+func readEvent(t *testing.T, br *bufio.Reader, within time.Duration) string {
+	t.Helper()
+	got := make(chan string, 1)
+	go func() {
+		for {
+			line, err := br.ReadString('\n')
+			if err != nil {
+				got <- "ERR " + err.Error()
+				return
+			}
+			if data, ok := strings.CutPrefix(strings.TrimSpace(line), "data: "); ok {
+				got <- data
+				return
+			}
+		}
+	}()
+	select {
+	case s := <-got:
+		return s
+	case <-time.After(within):
+		t.Fatalf("tidak ada event dalam %v (response di-buffer?)", within)
+		return ""
+	}
+}
+
+func openStream(t *testing.T, gw *httptest.Server) *bufio.Reader {
+	t.Helper()
+	resp, err := http.Get(gw.URL + "/order/stream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d", resp.StatusCode)
+	}
+	return bufio.NewReader(resp.Body)
+}
+
+// Event diteruskan begitu upstream flush, tidak menunggu response selesai.
+func TestStreamNoBuffering(t *testing.T) {
+	next, closed := make(chan string), make(chan struct{})
+	gw := newStreamGateway(t, sseUpstream(t, next, closed).URL, StreamConfig{
+		Paths: []string{"/order/stream"}, IdleTimeout: Duration(time.Minute), MaxDuration: Duration(time.Minute),
+	})
+	br := openStream(t, gw)
+
+	for _, want := range []string{"satu", "dua"} {
+		next <- want
+		if got := readEvent(t, br, time.Second); got != want {
+			t.Fatalf("event = %q, want %q", got, want)
+		}
+	}
+}
+
+func TestStreamIdleTimeout(t *testing.T) {
+	next, closed := make(chan string), make(chan struct{})
+	gw := newStreamGateway(t, sseUpstream(t, next, closed).URL, StreamConfig{
+		Paths: []string{"/order/stream"}, IdleTimeout: Duration(time.Second), MaxDuration: Duration(time.Minute),
+	})
+	br := openStream(t, gw)
+	next <- "halo"
+	readEvent(t, br, time.Second)
+
+	// Tidak ada data lagi: diputus setelah idle_timeout (dicek tiap detik)
+	start := time.Now()
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("stream idle tidak diputus")
+	}
+	if d := time.Since(start); d < 900*time.Millisecond {
+		t.Fatalf("stream diputus setelah %v, sebelum idle_timeout", d)
+	}
+	if _, err := io.ReadAll(br); err != nil {
+		t.Fatalf("client harus menerima akhir stream normal, got %v", err)
+	}
+}
+
+func TestStreamMaxDuration(t *testing.T) {
+	next, closed := make(chan string), make(chan struct{})
+	gw := newStreamGateway(t, sseUpstream(t, next, closed).URL, StreamConfig{
+		Paths: []string{"/order/stream"}, IdleTimeout: Duration(time.Minute), MaxDuration: Duration(700 * time.Millisecond),
+	})
+	br := openStream(t, gw)
+
+	// Stream aktif terus tetap diputus saat max_duration tercapai
+	start := time.Now()
+	events := 0
+	for {
+		select {
+		case next <- "tick":
+			readEvent(t, br, time.Second)
+			events++
+			time.Sleep(100 * time.Millisecond)
+			continue
+		case <-closed:
+		case <-time.After(3 * time.Second):
+			t.Fatal("stream tidak diputus setelah max_duration")
+		}
+		break
+	}
+	if d := time.Since(start); d < 600*time.Millisecond || d > 2*time.Second {
+		t.Fatalf("stream diputus setelah %v, want sekitar 700ms", d)
+	}
+	if events < 3 {
+		t.Fatalf("hanya %d event sebelum diputus", events)
+	}
+}
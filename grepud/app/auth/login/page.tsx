@@ -2,12 +2,15 @@
 
 import { useState, FormEvent } from 'react';
 import { useRouter } from 'next/navigation';
-import api from '../../../lib/api'; // Sesuaikan path
+import gateway from '../../../lib/gateway';
 
 export default function LoginPage() {
   const [email, setEmail] = useState('');
   const [password, setPassword] = useState('');
   const [error, setError] = useState<string | null>(null);
+  // Terisi kalau akun memakai 2FA: langkah kedua minta kode authenticator
+  const [challenge, setChallenge] = useState<string | null>(null);
+  const [code, setCode] = useState('');
   const router = useRouter();
 
   const handleSubmit = async (e: FormEvent) => {
@@ -15,20 +18,18 @@ export default function LoginPage() {
     setError(null);
 
     try {
-      // Mengatur agar axios mengirim kredensial (cookie)
-      const response = await api.post('/login', {
-        email,
-        password,
-      }, {
-        withCredentials: true, // Penting agar cookie dari backend bisa disimpan browser
-      });
+      // Login lewat gateway: token disimpan di sesi gateway, browser hanya
+      // menerima cookie sesi HttpOnly (tidak ada token di localStorage)
+      const response = challenge
+        ? await gateway.post('/session/login/2fa', { challenge_token: challenge, code })
+        : await gateway.post('/session/login', { email, password });
 
-      if (response.status === 200 && response.data.access_token) {
-        // Simpan access token di localStorage untuk digunakan pada request selanjutnya
-        localStorage.setItem('access_token', response.data.access_token);
-        
-        // Arahkan ke halaman utama atau dashboard setelah login berhasil
-        router.push('/'); 
+      if (response.data.two_factor_required) {
+        setChallenge(response.data.challenge_token);
+        return;
+      }
+      if (response.status === 200) {
+        router.push('/dashboard');
       }
     } catch (err: any) {
       if (err.response) {
@@ -45,6 +46,25 @@ export default function LoginPage() {
         <h1 className="text-2xl font-bold text-center text-gray-800">Login ke Akun Anda</h1>
         
         <form onSubmit={handleSubmit} className="space-y-6">
+          {challenge ? (
+          <div>
+            <label htmlFor="code" className="block text-sm font-medium text-gray-700">
+              Kode 2FA (dari aplikasi authenticator) atau recovery code
+            </label>
+            <input
+              id="code"
+              name="code"
+              type="text"
+              required
+              autoComplete="one-time-code"
+              maxLength={20}
+              value={code}
+              onChange={(e) => setCode(e.target.value.trim())}
+              className="w-full px-3 py-2 mt-1 text-center text-lg tracking-widest border border-gray-300 rounded-md shadow-sm focus:outline-none focus:ring-indigo-500 focus:border-indigo-500"
+            />
+          </div>
+          ) : (
+          <>
           <div>
             <label htmlFor="email" className="block text-sm font-medium text-gray-700">
               Email
@@ -73,12 +93,14 @@ export default function LoginPage() {
               className="w-full px-3 py-2 mt-1 border border-gray-300 rounded-md shadow-sm focus:outline-none focus:ring-indigo-500 focus:border-indigo-500"
             />
           </div>
+          </>
+          )}
           <div>
             <button
               type="submit"
               className="w-full px-4 py-2 font-medium text-white bg-indigo-600 rounded-md hover:bg-indigo-700 focus:outline-none focus:ring-2 focus:ring-offset-2 focus:ring-indigo-500"
             >
-              Login
+              {challenge ? 'Verifikasi' : 'Login'}
             </button>
           </div>
         </form>
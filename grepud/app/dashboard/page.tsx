@@ -1,26 +1,10 @@
 // app/dashboard/page.tsx
 'use client';
 
-import { useState, useEffect } from 'react';
+import { useState, useEffect, useRef } from 'react';
 import { useRouter } from 'next/navigation';
 import axios from 'axios';
-
-const gateway = axios.create({
-  baseURL: 'http://localhost:8000',
-});
-
-// Setup Interceptor untuk pasang Token otomatis
-gateway.interceptors.request.use((config) => {
-  const token = localStorage.getItem('access_token');
-  console.log("Token yang dikirim:", token); // <--- TAMBAHKAN INI
-  if (token) {
-    config.headers.Authorization = `Bearer ${token}`;
-  } else {
-    console.warn("Token tidak ditemukan di localStorage!");
-  }
-  return config;
-});
-
+import gateway, { GATEWAY_URL } from '../../lib/gateway';
 
 interface Order {
   id: string;
@@ -34,6 +18,8 @@ export default function DashboardPage() {
   const [newItem, setNewItem] = useState('Nasi Goreng');
   const [loading, setLoading] = useState(false);
   const router = useRouter();
+  // Idempotency-Key untuk order yang sedang dibuat; key baru setelah sukses
+  const orderKey = useRef<string | null>(null);
 
   // Load Orders
   const fetchOrders = async () => {
@@ -42,8 +28,10 @@ export default function DashboardPage() {
       setOrders(res.data || []);
     } catch (err) {
       console.error('Gagal load order', err);
-      // Jika 401 Unauthorized, lempar ke login
-    //   router.push('/auth/login');
+      // Sesi habis / belum login
+      if (axios.isAxiosError(err) && err.response?.status === 401) {
+        router.push('/auth/login');
+      }
     }
   };
 
@@ -51,14 +39,30 @@ export default function DashboardPage() {
     fetchOrders();
   }, []);
 
+  // Status order live via SSE, autentikasi lewat cookie sesi
+  useEffect(() => {
+    const source = new EventSource(`${GATEWAY_URL}/order/stream`, { withCredentials: true });
+    source.addEventListener('order', (e) => {
+      const order: Order = JSON.parse((e as MessageEvent).data);
+      setOrders((prev) =>
+        prev.some((o) => o.id === order.id)
+          ? prev.map((o) => (o.id === order.id ? order : o))
+          : [...prev, order]
+      );
+    });
+    return () => source.close();
+  }, []);
+
   // Handle Buat Order
   const handleOrder = async () => {
     setLoading(true);
+    orderKey.current ??= crypto.randomUUID();
     try {
       await gateway.post('/order/create', {
         item: newItem,
         price: 25000,
-      });
+      }, { headers: { 'Idempotency-Key': orderKey.current } });
+      orderKey.current = null;
       fetchOrders(); // Refresh list
     } catch (err) {
       alert('Gagal membuat pesanan');
@@ -74,7 +78,7 @@ export default function DashboardPage() {
       await gateway.post('/payment/pay', {
         order_id: orderId,
         amount: amount,
-      });
+      }, { headers: { 'Idempotency-Key': `pay-${orderId}` } }); // satu order = satu pembayaran
       alert('Pembayaran Berhasil!');
       fetchOrders(); // Refresh status jadi 'paid'
     } catch (err) {
@@ -82,13 +86,21 @@ export default function DashboardPage() {
     }
   };
 
+  const handleLogout = async () => {
+    try {
+      await gateway.post('/session/logout');
+    } finally {
+      router.push('/auth/login');
+    }
+  };
+
   return (
     <div className="min-h-screen bg-gray-50 p-10">
       <div className="max-w-4xl mx-auto bg-white p-8 rounded shadow">
         <div className="flex justify-between items-center mb-6">
           <h1 className="text-2xl font-bold">Dashboard Pemesanan</h1>
           <button 
-            onClick={() => { localStorage.clear(); router.push('/auth/login'); }}
+            onClick={handleLogout}
             className="text-red-500 hover:underline">
             Logout
           </button>
@@ -98,7 +110,7 @@ export default function DashboardPage() {
         <div className="flex gap-4 mb-8 p-4 bg-blue-50 rounded">
           <select 
             value={newItem} 
-            onChange={(e) => setNewItem(e.target.value)}
+            onChange={(e) => { setNewItem(e.target.value); orderKey.current = null; }}
             className="p-2 border rounded flex-1"
           >
             <option value="Nasi Goreng">Nasi Goreng - Rp 25.000</option>
@@ -1,14 +1,25 @@
 import axios from "axios";
 
+export const GATEWAY_URL = "http://localhost:8000";
+
+// Mode sesi (BFF): token disimpan gateway, browser cukup kirim cookie sesi.
+// Untuk POST/PUT/DELETE gateway minta token CSRF dari cookie session_csrf.
 const gateway = axios.create({
-  baseURL: "http://localhost:8000",
+  baseURL: GATEWAY_URL,
+  withCredentials: true,
 });
 
+function readCookie(name: string): string | null {
+  const match = document.cookie.match(new RegExp(`(?:^|; )${name}=([^;]*)`));
+  return match ? decodeURIComponent(match[1]) : null;
+}
+
 gateway.interceptors.request.use((config) => {
-  if (typeof window !== "undefined") {
-    const token = localStorage.getItem("access_token");
-    if (token) {
-      config.headers.Authorization = `Bearer ${token}`;
+  const method = (config.method ?? "get").toUpperCase();
+  if (typeof window !== "undefined" && !["GET", "HEAD", "OPTIONS"].includes(method)) {
+    const csrf = readCookie("session_csrf");
+    if (csrf) {
+      config.headers["X-CSRF-Token"] = csrf;
     }
   }
   return config;
@@ -2,11 +2,24 @@
 package main
 
 import (
-	"log"
+	"context"
+	_ "embed"
+	"log/slog"
+	"net/http"
 	"strconv"
 	"sync"
 
+	"shared/idempotency"
+	"shared/identity"
+	"shared/logging"
+	"shared/metrics"
+	"shared/server"
+	"shared/svcauth"
+	"shared/tracing"
+
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
 )
 
 // Model Sederhana (In-Memory DB)
@@ -18,27 +31,73 @@ type Order struct {
 	Status string  `json:"status"` // pending, paid
 }
 
+// Request body, aturannya sama dengan skema di openapi.json
+type CreateOrderRequest struct {
+	Item  string  `json:"item" binding:"required,max=100"`
+	Price float64 `json:"price" binding:"required,gt=0"`
+}
+
+type UpdateStatusRequest struct {
+	OrderID string `json:"order_id" binding:"required"`
+	Status  string `json:"status" binding:"required,oneof=pending paid"`
+}
+
+//go:embed openapi.json
+var openAPISpec []byte
+
+// Metrik domain order
+var (
+	ordersCreated = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "orders_created_total",
+		Help: "Order baru per status awal.",
+	}, []string{"status"})
+	orderStatusUpdates = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "order_status_updates_total",
+		Help: "Perubahan status order (dari payment-service) per status tujuan dan hasil.",
+	}, []string{"status", "result"})
+)
+
 var (
 	orders = make(map[string]Order)
+	broker = NewBroker() // perubahan status order untuk /order/stream & /order/ws
 	mu     sync.Mutex
 	nextID = 1
 )
 
 func main() {
-	r := gin.Default()
+	logging.Setup("order-service")
 
-	// Endpoint: Buat Pesanan
-	r.POST("/order/create", func(c *gin.Context) {
-		var req struct {
-			Item  string  `json:"item"`
-			Price float64 `json:"price"`
-		}
+	shutdownTracing, err := tracing.Setup(context.Background(), "order-service")
+	if err != nil {
+		logging.Fatal("gagal setup tracing", "error", err)
+	}
+	defer shutdownTracing(context.Background())
+
+	r := gin.New()
+	r.Use(gin.Recovery(), tracing.Middleware("order-service"), logging.Middleware(), metrics.Middleware())
+	r.GET("/metrics", metrics.Handler())
+	// Idempotency-Key disimpan di Redis (REDIS_ADDR) supaya berlaku di semua instance
+	idemStore, idemPing := idempotency.StoreFromEnv()
+	checks := map[string]server.Check{}
+	if idemPing != nil {
+		checks["redis"] = idemPing
+	}
+	server.Probes(r, checks)
+	r.GET("/openapi.json", func(c *gin.Context) { c.Data(http.StatusOK, "application/json", openAPISpec) })
+
+	// Endpoint untuk user: identitas diambil dari assertion yang ditandatangani Gateway
+	user := r.Group("/order", identity.Middleware(identity.KeyFromEnv()))
+
+	// Endpoint: Buat Pesanan. Idempotency-Key mencegah order ganda saat
+	// double-click/retry.
+	user.POST("/create", idempotency.Middleware(idemStore, idempotency.TTLFromEnv()), func(c *gin.Context) {
+		var req CreateOrderRequest
 		if err := c.ShouldBindJSON(&req); err != nil {
 			c.JSON(400, gin.H{"error": "Invalid Input"})
 			return
 		}
 
-		userID := c.GetHeader("X-User-ID") // Didapat dari Gateway
+		userID := identity.From(c).UserID // Didapat dari Gateway
 
 		mu.Lock()
 		id := strconv.Itoa(nextID)
@@ -46,13 +105,15 @@ func main() {
 		newOrder := Order{ID: id, UserID: userID, Item: req.Item, Price: req.Price, Status: "pending"}
 		orders[id] = newOrder
 		mu.Unlock()
+		ordersCreated.WithLabelValues(newOrder.Status).Inc()
+		broker.Publish(newOrder)
 
 		c.JSON(201, newOrder)
 	})
 
 	// Endpoint: List Pesanan User
-	r.GET("/order/list", func(c *gin.Context) {
-		userID := c.GetHeader("X-User-ID")
+	user.GET("/list", func(c *gin.Context) {
+		userID := identity.From(c).UserID
 		var userOrders []Order
 
 		mu.Lock()
@@ -65,26 +126,41 @@ func main() {
 		c.JSON(200, userOrders)
 	})
 
-	// Endpoint Internal: Update Status (Dipanggil oleh Payment Service)
-	r.POST("/order/internal/update-status", func(c *gin.Context) {
-		var req struct {
-			OrderID string `json:"order_id"`
-			Status  string `json:"status"`
+	// Endpoint: Live status order milik user (SSE, atau WebSocket)
+	user.GET("/stream", streamSSE(broker))
+	user.GET("/ws", streamWS(broker))
+
+	// Endpoint Internal: hanya untuk service lain, wajib request bertanda tangan HMAC
+	internal := r.Group("/order/internal", svcauth.Middleware(svcauth.KeyFromEnv()))
+
+	// Update Status (Dipanggil oleh Payment Service)
+	internal.POST("/update-status", func(c *gin.Context) {
+		var req UpdateStatusRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": "Invalid Input"})
+			return
 		}
-		c.ShouldBindJSON(&req)
 
 		mu.Lock()
 		if val, ok := orders[req.OrderID]; ok {
 			val.Status = req.Status
 			orders[req.OrderID] = val
 			mu.Unlock()
+			broker.Publish(val)
+			orderStatusUpdates.WithLabelValues(req.Status, "updated").Inc()
 			c.JSON(200, gin.H{"message": "updated"})
 		} else {
 			mu.Unlock()
+			orderStatusUpdates.WithLabelValues(req.Status, "not_found").Inc()
 			c.JSON(404, gin.H{"error": "not found"})
 		}
 	})
 
-	log.Println("📦 Order Service running on port 8081")
-	r.Run(":8081")
-}
\ No newline at end of file
+	srv := &http.Server{Addr: ":8081", Handler: r}
+	srv.RegisterOnShutdown(broker.Close) // koneksi stream tidak ikut ditunggu Shutdown
+
+	slog.Info("Order Service running", "addr", srv.Addr)
+	if err := server.Run(srv); err != nil {
+		logging.Fatal("Order Service berhenti", "error", err)
+	}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"shared/identity"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+// streamHeartbeat: jeda ping ke client supaya koneksi yang diam tidak
+// diputus proxy/gateway karena idle timeout.
+const streamHeartbeat = 15 * time.Second
+
+// Broker: pub/sub perubahan status order di memori, per user. Setiap
+// koneksi SSE / WebSocket adalah satu subscriber. Event untuk subscriber yang
+// buffer-nya penuh dibuang, supaya satu client lambat tidak menahan request lain.
+type Broker struct {
+	mu   sync.Mutex
+	subs map[string]map[chan Order]struct{} // key: user id
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func NewBroker() *Broker {
+	return &Broker{
+		subs: make(map[string]map[chan Order]struct{}),
+		done: make(chan struct{}),
+	}
+}
+
+// Close dipanggil saat shutdown: semua koneksi stream ditutup supaya server
+// tidak menunggu sampai drain timeout. Client akan connect ulang ke instance lain.
+func (b *Broker) Close() {
+	b.closeOnce.Do(func() { close(b.done) })
+}
+
+// Subscribe mendaftarkan subscriber untuk order milik userID. Panggil
+// fungsi yang dikembalikan saat koneksi selesai.
+func (b *Broker) Subscribe(userID string) (<-chan Order, func()) {
+	ch := make(chan Order, 16)
+	b.mu.Lock()
+	if b.subs[userID] == nil {
+		b.subs[userID] = make(map[chan Order]struct{})
+	}
+	b.subs[userID][ch] = struct{}{}
+	b.mu.Unlock()
+
+	return ch, func() {
+		b.mu.Lock()
+		delete(b.subs[userID], ch)
+		if len(b.subs[userID]) == 0 {
+			delete(b.subs, userID)
+		}
+		b.mu.Unlock()
+	}
+}
+
+// Publish mengirim order ke semua subscriber pemiliknya.
+func (b *Broker) Publish(o Order) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subs[o.UserID] {
+		select {
+		case ch <- o:
+		default:
+		}
+	}
+}
+
+// GET /order/stream: Server-Sent Events, satu event "order" per perubahan.
+func streamSSE(b *Broker) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		events, unsubscribe := b.Subscribe(identity.From(c).UserID)
+		defer unsubscribe()
+
+		c.Header("Content-Type", "text/event-stream")
+		c.Header("Cache-Control", "no-cache")
+		c.Header("X-Accel-Buffering", "no") // nginx: jangan di-buffer
+		c.Status(http.StatusOK)
+		c.Writer.Flush()
+
+		heartbeat := time.NewTicker(streamHeartbeat)
+		defer heartbeat.Stop()
+		for {
+			select {
+			case <-c.Request.Context().Done():
+				return
+			case <-b.done:
+				return
+			case o := <-events:
+				c.SSEvent("order", o)
+			case <-heartbeat.C:
+				fmt.Fprint(c.Writer, ": ping\n\n")
+			}
+			c.Writer.Flush()
+		}
+	}
+}
+
+// Origin tidak dicek di sini: service hanya bisa diakses lewat gateway, dan
+// gateway yang menolak upgrade dari origin di luar cors.allow_origins kalau
+// user login lewat cookie sesi (token lewat query/header tidak otomatis
+// terkirim oleh browser).
+var upgrader = websocket.Upgrader{
+	CheckOrigin: func(*http.Request) bool { return true },
+}
+
+type wsMessage struct {
+	Type  string `json:"type"`
+	Order Order  `json:"order"`
+}
+
+// GET /order/ws: alternatif WebSocket, pesan {"type":"order","order":{...}}.
+func streamWS(b *Broker) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+		if err != nil {
+			return // Upgrade sudah menulis response error
+		}
+		defer conn.Close()
+
+		events, unsubscribe := b.Subscribe(identity.From(c).UserID)
+		defer unsubscribe()
+
+		// Pesan dari client tidak dipakai, tapi tetap dibaca supaya close & pong diproses
+		closed := make(chan struct{})
+		go func() {
+			defer close(closed)
+			for {
+				if _, _, err := conn.NextReader(); err != nil {
+					return
+				}
+			}
+		}()
+
+		ping := time.NewTicker(streamHeartbeat)
+		defer ping.Stop()
+		for {
+			select {
+			case <-closed:
+				return
+			case <-b.done:
+				msg := websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutdown")
+				conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(time.Second))
+				return
+			case o := <-events:
+				conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+				if err := conn.WriteJSON(wsMessage{Type: "order", Order: o}); err != nil {
+					return
+				}
+			case <-ping.C:
+				if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(10*time.Second)); err != nil {
+					return
+				}
+			}
+		}
+	}
+}
@@ -3,33 +3,124 @@ package main
 
 import (
 	"bytes"
+	"context"
+	_ "embed"
 	"encoding/json"
-	"log"
+	"fmt"
+	"log/slog"
 	"net/http"
+	"shared/idempotency"
+	"shared/identity"
+	"shared/logging"
+	"shared/metrics"
+	"shared/server"
+	"shared/svcauth"
+	"shared/tracing"
 	"strconv" // Tambahkan ini
+	"sync"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
 )
 
+// paymentsProcessed: hasil pembayaran. result="order_update_failed" berarti
+// pembayaran diterima tapi status order di order-service gagal diupdate.
+var paymentsProcessed = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "payments_processed_total",
+	Help: "Pembayaran yang diproses per hasil.",
+}, []string{"result"})
+
+// PayRequest: body /payment/pay, aturannya sama dengan skema di openapi.json
+type PayRequest struct {
+	OrderID string  `json:"order_id" binding:"required"`
+	Amount  float64 `json:"amount" binding:"required,gt=0"`
+}
+
+// Riwayat pembayaran (In-Memory DB), hanya yang berhasil
+type Payment struct {
+	OrderID string    `json:"order_id"`
+	UserID  string    `json:"user_id"`
+	Amount  float64   `json:"amount"`
+	PaidAt  time.Time `json:"paid_at"`
+}
+
+var (
+	payments = make(map[string][]Payment) // key: user id
+	mu       sync.Mutex
+)
+
+//go:embed openapi.json
+var openAPISpec []byte
+
+// internalClient: client untuk panggilan antar service, meneruskan trace
+// lewat header traceparent.
+var internalClient = &http.Client{Timeout: 10 * time.Second, Transport: tracing.Transport(nil)}
+
+// postInternal mengirim POST JSON ke endpoint /internal/ service lain,
+// ditandatangani dengan SERVICE_HMAC_KEY. Request id di ctx ikut diteruskan.
+// Status selain 2xx dianggap gagal.
+func postInternal(ctx context.Context, url string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	logging.Propagate(req)
+	if err := svcauth.Sign(req, "payment-service", svcauth.KeyFromEnv()); err != nil {
+		return err
+	}
+
+	resp, err := internalClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s: status %d", url, resp.StatusCode)
+	}
+	return nil
+}
+
 func main() {
-	r := gin.Default()
+	logging.Setup("payment-service")
+
+	shutdownTracing, err := tracing.Setup(context.Background(), "payment-service")
+	if err != nil {
+		logging.Fatal("gagal setup tracing", "error", err)
+	}
+	defer shutdownTracing(context.Background())
 
-	r.POST("/payment/pay", func(c *gin.Context) {
+	r := gin.New()
+	r.Use(gin.Recovery(), tracing.Middleware("payment-service"), logging.Middleware(), metrics.Middleware())
+	r.GET("/metrics", metrics.Handler())
+	// Idempotency-Key disimpan di Redis (REDIS_ADDR) supaya berlaku di semua instance
+	idemStore, idemPing := idempotency.StoreFromEnv()
+	checks := map[string]server.Check{}
+	if idemPing != nil {
+		checks["redis"] = idemPing
+	}
+	server.Probes(r, checks)
+	r.GET("/openapi.json", func(c *gin.Context) { c.Data(http.StatusOK, "application/json", openAPISpec) })
+
+	// Identitas user diambil dari assertion yang ditandatangani Gateway.
+	// Idempotency-Key mencegah pembayaran diproses dua kali saat retry.
+	idem := idempotency.Middleware(idemStore, idempotency.TTLFromEnv())
+	r.POST("/payment/pay", identity.Middleware(identity.KeyFromEnv()), idem, func(c *gin.Context) {
 		// Request dari Frontend
-		var req struct {
-			OrderID  string  `json:"order_id"`
-			Amount   float64 `json:"amount"`
-		}
-		
-		// Header User ID dari Gateway (String)
-		userIDStr := c.GetHeader("X-User-ID")
-		
+		var req PayRequest
+
+		// User ID dari Gateway (String)
+		userIDStr := identity.From(c).UserID
+
 		if err := c.ShouldBindJSON(&req); err != nil {
 			c.JSON(400, gin.H{"error": "Invalid Input"})
 			return
 		}
 
-		log.Printf("💰 Processing payment for Order %s Amount %.2f", req.OrderID, req.Amount)
+		logger := logging.FromContext(c.Request.Context())
+		logger.Info("processing payment", "order_id", req.OrderID, "amount", req.Amount)
 
 		// 1. Update Status di Order Service
 		updatePayload := map[string]string{
@@ -37,16 +128,30 @@ func main() {
 			"status":   "paid",
 		}
 		jsonBody, _ := json.Marshal(updatePayload)
-		resp, err := http.Post("http://localhost:8081/order/internal/update-status", "application/json", bytes.NewBuffer(jsonBody))
-		
-		if err != nil || resp.StatusCode != 200 {
+		if err := postInternal(c.Request.Context(), "http://localhost:8081/order/internal/update-status", jsonBody); err != nil {
+			paymentsProcessed.WithLabelValues("order_update_failed").Inc()
+			logger.Error("gagal update status order", "order_id", req.OrderID, "error", err)
 			c.JSON(500, gin.H{"error": "Payment success but failed to update order"})
 			return
 		}
 
+		paymentsProcessed.WithLabelValues("success").Inc()
+		mu.Lock()
+		payments[userIDStr] = append(payments[userIDStr], Payment{
+			OrderID: req.OrderID,
+			UserID:  userIDStr,
+			Amount:  req.Amount,
+			PaidAt:  time.Now(),
+		})
+		mu.Unlock()
+
 		// 2. TRIGGER KIRIM EMAIL KE AUTH SERVICE
 		// Kita pakai Goroutine agar user tidak perlu menunggu email terkirim
-		go func(oID string, amt float64, uIDStr string) {
+		// (ditunggu saat shutdown). Context tanpa cancel: goroutine tetap jalan
+		// setelah response dikirim, tapi request id-nya tetap terbawa
+		ctx := context.WithoutCancel(c.Request.Context())
+		oID, amt, uIDStr := req.OrderID, req.Amount, userIDStr
+		server.Go(func() {
 			// Convert UserID string ke int64
 			uID, _ := strconv.ParseInt(uIDStr, 10, 64)
 
@@ -59,17 +164,32 @@ func main() {
 			receiptJson, _ := json.Marshal(receiptPayload)
 
 			// Tembak Auth Service
-			_, err := http.Post("http://localhost:8080/auth/internal/send-receipt", "application/json", bytes.NewBuffer(receiptJson))
-			if err != nil {
-				log.Println("⚠️ Gagal trigger receipt:", err)
+			if err := postInternal(ctx, "http://localhost:8080/auth/internal/send-receipt", receiptJson); err != nil {
+				logger.Warn("gagal trigger receipt", "order_id", oID, "error", err)
 			} else {
-				log.Println("📨 Request kirim struk dikirim ke Auth Service")
+				logger.Info("request kirim struk dikirim ke Auth Service", "order_id", oID)
 			}
-		}(req.OrderID, req.Amount, userIDStr)
+		})
 
 		c.JSON(200, gin.H{"message": "Payment Successful", "order_id": req.OrderID})
 	})
 
-	log.Println("💳 Payment Service running on port 8082")
-	r.Run(":8082")
-}
\ No newline at end of file
+	// Endpoint: Riwayat Pembayaran User (terbaru dulu)
+	r.GET("/payment/history", identity.Middleware(identity.KeyFromEnv()), func(c *gin.Context) {
+		userID := identity.From(c).UserID
+
+		mu.Lock()
+		history := make([]Payment, 0, len(payments[userID]))
+		for i := len(payments[userID]) - 1; i >= 0; i-- {
+			history = append(history, payments[userID][i])
+		}
+		mu.Unlock()
+		c.JSON(200, history)
+	})
+
+	srv := &http.Server{Addr: ":8082", Handler: r}
+	slog.Info("Payment Service running", "addr", srv.Addr)
+	if err := server.Run(srv); err != nil {
+		logging.Fatal("Payment Service berhenti", "error", err)
+	}
+}
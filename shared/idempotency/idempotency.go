@@ -0,0 +1,235 @@
+// Package idempotency: dukungan header Idempotency-Key untuk endpoint POST.
+// Response pertama untuk satu key (per user) disimpan lalu diputar ulang
+// untuk request berikutnya dengan key yang sama, jadi double-click atau retry
+// tidak membuat order/pembayaran ganda.
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"shared/identity"
+	"shared/logging"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	Header         = "Idempotency-Key"
+	HeaderReplayed = "Idempotent-Replayed"
+
+	DefaultTTL = 24 * time.Hour
+	maxKeyLen  = 255
+)
+
+// Record adalah isi satu key. Done=false berarti request pertama masih
+// diproses.
+type Record struct {
+	Fingerprint string `json:"fingerprint"` // hash method, path & body
+	Done        bool   `json:"done"`
+	Status      int    `json:"status,omitempty"`
+	ContentType string `json:"content_type,omitempty"`
+	Body        []byte `json:"body,omitempty"`
+}
+
+// Store menyimpan Record per key sampai ttl habis.
+type Store interface {
+	// Reserve menyimpan rec kalau key belum ada (ok=true). Kalau sudah ada,
+	// record yang lama dikembalikan dan tidak ada yang diubah.
+	Reserve(ctx context.Context, key string, rec Record, ttl time.Duration) (existing Record, ok bool, err error)
+	// Complete menimpa record key dengan response final.
+	Complete(ctx context.Context, key string, rec Record, ttl time.Duration) error
+	// Release menghapus key supaya request boleh diulang (response 5xx).
+	Release(ctx context.Context, key string) error
+}
+
+// TTLFromEnv membaca IDEMPOTENCY_TTL (format time.ParseDuration), default 24 jam.
+func TTLFromEnv() time.Duration {
+	v := os.Getenv("IDEMPOTENCY_TTL")
+	if v == "" {
+		return DefaultTTL
+	}
+	ttl, err := time.ParseDuration(v)
+	if err != nil || ttl <= 0 {
+		slog.Warn("IDEMPOTENCY_TTL tidak valid, pakai default", "value", v, "default", DefaultTTL.String())
+		return DefaultTTL
+	}
+	return ttl
+}
+
+// Middleware menangani Idempotency-Key. Dipasang setelah identity.Middleware
+// karena key berlaku per user. Request tanpa header diproses seperti biasa.
+//
+//   - key baru: request diproses, response < 500 disimpan selama ttl
+//   - key sama, body sama: response tersimpan dikirim ulang (Idempotent-Replayed: true)
+//   - key sama, body beda: 422
+//   - key sama, request pertama belum selesai: 409
+//
+// Response 5xx tidak disimpan supaya client bisa retry dengan key yang sama.
+func Middleware(store Store, ttl time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(Header)
+		if key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > maxKeyLen {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Idempotency-Key too long"})
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Invalid body"})
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		userID := ""
+		if id := identity.From(c); id != nil {
+			userID = id.UserID
+		}
+		storeKey := "idempotency:" + userID + ":" + key
+		fp := fingerprint(c.Request, body)
+
+		ctx := c.Request.Context()
+		logger := logging.FromContext(ctx)
+		existing, ok, err := store.Reserve(ctx, storeKey, Record{Fingerprint: fp}, ttl)
+		if err != nil {
+			// Store bermasalah: lebih aman menolak daripada memproses dua kali
+			logger.Error("idempotency store error", "error", err)
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "Service Unavailable"})
+			return
+		}
+		if !ok {
+			switch {
+			case existing.Fingerprint != fp:
+				c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": "Idempotency-Key reused with a different request"})
+			case !existing.Done:
+				c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "A request with this Idempotency-Key is still in progress"})
+			default:
+				c.Header(HeaderReplayed, "true")
+				c.Data(existing.Status, existing.ContentType, existing.Body)
+				c.Abort()
+			}
+			return
+		}
+
+		// Handler panic: key dilepas dulu, panic diteruskan ke gin.Recovery
+		defer func() {
+			if p := recover(); p != nil {
+				store.Release(context.WithoutCancel(ctx), storeKey)
+				panic(p)
+			}
+		}()
+
+		rec := &recorder{ResponseWriter: c.Writer}
+		c.Writer = rec
+		c.Next()
+
+		// Context request bisa sudah dibatalkan (client putus), hasil tetap disimpan
+		ctx = context.WithoutCancel(ctx)
+		status := rec.Status()
+		if status >= http.StatusInternalServerError {
+			if err := store.Release(ctx, storeKey); err != nil {
+				logger.Error("gagal melepas idempotency key", "error", err)
+			}
+			return
+		}
+		final := Record{
+			Fingerprint: fp,
+			Done:        true,
+			Status:      status,
+			ContentType: rec.Header().Get("Content-Type"),
+			Body:        rec.body.Bytes(),
+		}
+		if err := store.Complete(ctx, storeKey, final, ttl); err != nil {
+			logger.Error("gagal menyimpan response idempotency", "error", err)
+		}
+	}
+}
+
+func fingerprint(req *http.Request, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(req.Method + "\n" + req.URL.Path + "\n"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// recorder menyalin body response supaya bisa disimpan.
+type recorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (r *recorder) Write(b []byte) (int, error) {
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
+
+func (r *recorder) WriteString(s string) (int, error) {
+	r.body.WriteString(s)
+	return r.ResponseWriter.WriteString(s)
+}
+
+// --- MEMORY STORE ---
+// Hanya untuk test dan development dengan satu instance; production pakai
+// RedisStore (lihat StoreFromEnv).
+
+type entry struct {
+	rec     Record
+	expires time.Time
+}
+
+type MemoryStore struct {
+	mu      sync.Mutex
+	entries map[string]entry
+	last    time.Time // terakhir kali entry kedaluwarsa dibersihkan
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: make(map[string]entry)}
+}
+
+func (s *MemoryStore) Reserve(_ context.Context, key string, rec Record, ttl time.Duration) (Record, bool, error) {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if now.Sub(s.last) > time.Minute {
+		for k, e := range s.entries {
+			if now.After(e.expires) {
+				delete(s.entries, k)
+			}
+		}
+		s.last = now
+	}
+
+	if e, ok := s.entries[key]; ok && now.Before(e.expires) {
+		return e.rec, false, nil
+	}
+	s.entries[key] = entry{rec: rec, expires: now.Add(ttl)}
+	return Record{}, true, nil
+}
+
+func (s *MemoryStore) Complete(_ context.Context, key string, rec Record, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries[key] = entry{rec: rec, expires: time.Now().Add(ttl)}
+	return nil
+}
+
+func (s *MemoryStore) Release(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, key)
+	return nil
+}
@@ -0,0 +1,145 @@
+package idempotency
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"shared/identity"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+)
+
+func newRouter(calls *int, status int) *gin.Engine {
+	return newRouterWithStore(NewMemoryStore(), calls, status)
+}
+
+func newRouterWithStore(store Store, calls *int, status int) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/order/create", func(c *gin.Context) {
+		identity.Set(c, &identity.Identity{UserID: c.GetHeader("X-Test-User")})
+	}, Middleware(store, time.Minute), func(c *gin.Context) {
+		*calls++
+		c.JSON(status, gin.H{"id": *calls})
+	})
+	return r
+}
+
+func post(r *gin.Engine, user, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/order/create", strings.NewReader(body))
+	req.Header.Set("X-Test-User", user)
+	if key != "" {
+		req.Header.Set(Header, key)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestReplay(t *testing.T) {
+	calls := 0
+	r := newRouter(&calls, http.StatusCreated)
+
+	first := post(r, "1", "k1", `{"item":"A"}`)
+	second := post(r, "1", "k1", `{"item":"A"}`)
+	if calls != 1 {
+		t.Fatalf("handler dipanggil %d kali, want 1", calls)
+	}
+	if second.Code != http.StatusCreated || second.Body.String() != first.Body.String() {
+		t.Fatalf("replay = %d %q, want %d %q", second.Code, second.Body, first.Code, first.Body)
+	}
+	if second.Header().Get(HeaderReplayed) != "true" {
+		t.Fatal("header Idempotent-Replayed tidak ada")
+	}
+
+	// Key sama dari user lain tidak bentrok
+	post(r, "2", "k1", `{"item":"A"}`)
+	// Tanpa header: selalu diproses
+	post(r, "1", "", `{"item":"A"}`)
+	post(r, "1", "", `{"item":"A"}`)
+	if calls != 4 {
+		t.Fatalf("handler dipanggil %d kali, want 4", calls)
+	}
+}
+
+func TestDifferentBody(t *testing.T) {
+	calls := 0
+	r := newRouter(&calls, http.StatusCreated)
+
+	post(r, "1", "k1", `{"item":"A"}`)
+	if w := post(r, "1", "k1", `{"item":"B"}`); w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("body beda = %d, want 422", w.Code)
+	}
+	if calls != 1 {
+		t.Fatalf("handler dipanggil %d kali, want 1", calls)
+	}
+}
+
+func TestServerErrorNotStored(t *testing.T) {
+	calls := 0
+	r := newRouter(&calls, http.StatusBadGateway)
+
+	post(r, "1", "k1", `{}`)
+	post(r, "1", "k1", `{}`)
+	if calls != 2 {
+		t.Fatalf("response 5xx harus boleh di-retry, handler dipanggil %d kali", calls)
+	}
+}
+
+func TestInProgress(t *testing.T) {
+	store := NewMemoryStore()
+	if _, ok, _ := store.Reserve(t.Context(), "idempotency:1:k1", Record{Fingerprint: "x"}, time.Minute); !ok {
+		t.Fatal("reserve pertama harus ok")
+	}
+	existing, ok, _ := store.Reserve(t.Context(), "idempotency:1:k1", Record{Fingerprint: "x"}, time.Minute)
+	if ok || existing.Done {
+		t.Fatalf("reserve kedua = %+v %v, want record belum selesai", existing, ok)
+	}
+}
+
+// Dua instance dengan Redis yang sama: retry ke instance lain diputar ulang.
+func TestRedisStoreAcrossInstances(t *testing.T) {
+	mr := miniredis.RunT(t)
+	calls := 0
+	a := newRouterWithStore(NewRedisStore(redis.NewClient(&redis.Options{Addr: mr.Addr()})), &calls, http.StatusCreated)
+	b := newRouterWithStore(NewRedisStore(redis.NewClient(&redis.Options{Addr: mr.Addr()})), &calls, http.StatusCreated)
+
+	first := post(a, "1", "k1", `{"item":"A"}`)
+	second := post(b, "1", "k1", `{"item":"A"}`)
+	if calls != 1 {
+		t.Fatalf("handler dipanggil %d kali, want 1", calls)
+	}
+	if second.Header().Get(HeaderReplayed) != "true" || second.Body.String() != first.Body.String() {
+		t.Fatalf("instance lain = %d %q, want replay %q", second.Code, second.Body, first.Body)
+	}
+	if w := post(b, "1", "k1", `{"item":"B"}`); w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("body beda = %d, want 422", w.Code)
+	}
+
+	// Key yang kadaluarsa boleh dipakai lagi
+	mr.FastForward(2 * time.Minute)
+	post(b, "1", "k1", `{"item":"B"}`)
+	if calls != 2 {
+		t.Fatalf("handler dipanggil %d kali setelah TTL habis, want 2", calls)
+	}
+}
+
+func TestRedisStoreRelease(t *testing.T) {
+	mr := miniredis.RunT(t)
+	calls := 0
+	r := newRouterWithStore(NewRedisStore(redis.NewClient(&redis.Options{Addr: mr.Addr()})), &calls, http.StatusBadGateway)
+
+	post(r, "1", "k1", `{}`)
+	if mr.Exists("idempotency:1:k1") {
+		t.Fatal("key harus dilepas setelah response 5xx")
+	}
+	post(r, "1", "k1", `{}`)
+	if calls != 2 {
+		t.Fatalf("handler dipanggil %d kali, want 2", calls)
+	}
+}
@@ -0,0 +1,97 @@
+package idempotency
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"os"
+	"time"
+
+	"shared/logging"
+
+	"github.com/redis/go-redis/extra/redisotel/v9"
+	"github.com/redis/go-redis/v9"
+)
+
+// --- REDIS STORE ---
+// Dipakai kalau service jalan lebih dari satu instance (pool di gateway):
+// retry yang mendarat di instance lain tetap melihat key yang sama.
+
+type RedisStore struct {
+	rdb *redis.Client
+}
+
+func NewRedisStore(rdb *redis.Client) *RedisStore {
+	return &RedisStore{rdb: rdb}
+}
+
+func (s *RedisStore) Reserve(ctx context.Context, key string, rec Record, ttl time.Duration) (Record, bool, error) {
+	raw, err := json.Marshal(rec)
+	if err != nil {
+		return Record{}, false, err
+	}
+	// Key bisa kadaluarsa di antara SET NX dan GET, jadi dicoba sekali lagi
+	for range 2 {
+		ok, err := s.rdb.SetNX(ctx, key, raw, ttl).Result()
+		if err != nil || ok {
+			return Record{}, ok, err
+		}
+		existing, err := s.rdb.Get(ctx, key).Bytes()
+		if errors.Is(err, redis.Nil) {
+			continue
+		}
+		if err != nil {
+			return Record{}, false, err
+		}
+		var prev Record
+		if err := json.Unmarshal(existing, &prev); err != nil {
+			return Record{}, false, err
+		}
+		return prev, false, nil
+	}
+	return Record{}, false, errors.New("idempotency key berubah terus saat reserve")
+}
+
+func (s *RedisStore) Complete(ctx context.Context, key string, rec Record, ttl time.Duration) error {
+	raw, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	return s.rdb.Set(ctx, key, raw, ttl).Err()
+}
+
+func (s *RedisStore) Release(ctx context.Context, key string) error {
+	return s.rdb.Del(ctx, key).Err()
+}
+
+// Ping untuk readiness probe.
+func (s *RedisStore) Ping(ctx context.Context) error {
+	return s.rdb.Ping(ctx).Err()
+}
+
+// StoreFromEnv: RedisStore ke REDIS_ADDR. Tanpa REDIS_ADDR (atau dengan
+// IDEMPOTENCY_STORE=memory) memakai MemoryStore, yang hanya aman untuk satu
+// instance. ping dipakai untuk /readyz, nil kalau memory store.
+func StoreFromEnv() (store Store, ping func(context.Context) error) {
+	if os.Getenv("IDEMPOTENCY_STORE") == "memory" {
+		slog.Warn("IDEMPOTENCY_STORE=memory, Idempotency-Key tidak berlaku antar instance")
+		return NewMemoryStore(), nil
+	}
+
+	addr := os.Getenv("REDIS_ADDR")
+	if addr == "" {
+		slog.Warn("REDIS_ADDR kosong, idempotency store di memori; Idempotency-Key tidak berlaku antar instance")
+		return NewMemoryStore(), nil
+	}
+	rdb := redis.NewClient(&redis.Options{Addr: addr})
+	if err := redisotel.InstrumentTracing(rdb); err != nil {
+		logging.Fatal("failed to instrument Redis", "error", err)
+	}
+	if err := rdb.Ping(context.Background()).Err(); err != nil {
+		logging.Fatal("failed to connect to Redis", "error", err)
+	}
+	slog.Info("connected to Redis", "addr", addr)
+	rs := NewRedisStore(rdb)
+	return rs, rs.Ping
+}
@@ -0,0 +1,148 @@
+// Package identity membawa identitas user dari API Gateway ke service di
+// belakangnya dalam bentuk assertion bertanda tangan HMAC (header
+// X-Identity-Assertion), menggantikan header X-User-ID mentah yang bisa
+// dipalsukan siapa saja yang bisa menjangkau port service.
+//
+// Format assertion: base64url(payload JSON) + "." + base64url(HMAC-SHA256).
+// Umurnya pendek (TTL) karena dibuat ulang gateway di setiap request.
+package identity
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	Header = "X-Identity-Assertion"
+	TTL    = time.Minute
+
+	contextKey = "identity"
+)
+
+var (
+	ErrMissing = errors.New("identity: assertion tidak ada")
+	ErrInvalid = errors.New("identity: assertion tidak valid")
+	ErrExpired = errors.New("identity: assertion kedaluwarsa")
+)
+
+type Identity struct {
+	UserID   string   `json:"sub"`
+	Username string   `json:"name,omitempty"`
+	Roles    []string `json:"roles,omitempty"`
+	Scopes   []string `json:"scopes,omitempty"`
+}
+
+// HasRole: true kalau id punya salah satu dari roles.
+func (id *Identity) HasRole(roles ...string) bool {
+	return slices.ContainsFunc(roles, func(r string) bool { return slices.Contains(id.Roles, r) })
+}
+
+// HasScopes: true kalau id punya semua scopes.
+func (id *Identity) HasScopes(scopes ...string) bool {
+	for _, s := range scopes {
+		if !slices.Contains(id.Scopes, s) {
+			return false
+		}
+	}
+	return true
+}
+
+type payload struct {
+	Identity
+	IssuedAt  int64 `json:"iat"`
+	ExpiresAt int64 `json:"exp"`
+}
+
+// KeyFromEnv membaca IDENTITY_SIGNING_KEY (sama di gateway dan semua service).
+func KeyFromEnv() []byte {
+	return []byte(os.Getenv("IDENTITY_SIGNING_KEY"))
+}
+
+// Sign membuat assertion untuk id, berlaku selama TTL sejak now.
+func Sign(id Identity, key []byte, now time.Time) (string, error) {
+	if len(key) == 0 {
+		return "", errors.New("identity: signing key kosong")
+	}
+	raw, err := json.Marshal(payload{Identity: id, IssuedAt: now.Unix(), ExpiresAt: now.Add(TTL).Unix()})
+	if err != nil {
+		return "", err
+	}
+	body := base64.RawURLEncoding.EncodeToString(raw)
+	return body + "." + base64.RawURLEncoding.EncodeToString(sign(body, key)), nil
+}
+
+// Verify memeriksa tanda tangan dan masa berlaku assertion.
+func Verify(assertion string, key []byte, now time.Time) (*Identity, error) {
+	if assertion == "" {
+		return nil, ErrMissing
+	}
+	body, sig, ok := strings.Cut(assertion, ".")
+	if !ok || len(key) == 0 {
+		return nil, ErrInvalid
+	}
+
+	got, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || !hmac.Equal(got, sign(body, key)) {
+		return nil, ErrInvalid
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(body)
+	if err != nil {
+		return nil, ErrInvalid
+	}
+	var p payload
+	if err := json.Unmarshal(raw, &p); err != nil || p.UserID == "" {
+		return nil, ErrInvalid
+	}
+	if now.Unix() >= p.ExpiresAt {
+		return nil, ErrExpired
+	}
+	return &p.Identity, nil
+}
+
+// Middleware memverifikasi assertion dari gateway dan menyimpan Identity di
+// gin context. Request tanpa assertion yang valid dijawab 401.
+func Middleware(key []byte) gin.HandlerFunc {
+	if len(key) == 0 {
+		slog.Warn("IDENTITY_SIGNING_KEY kosong, semua request user akan ditolak")
+	}
+	return func(c *gin.Context) {
+		id, err := Verify(c.GetHeader(Header), key, time.Now())
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+		Set(c, id)
+		c.Next()
+	}
+}
+
+// Set menyimpan Identity di gin context (dipakai Middleware dan gateway).
+func Set(c *gin.Context, id *Identity) {
+	c.Set(contextKey, id)
+	c.Set("user_id", id.UserID) // dipakai logging.Middleware
+}
+
+// From mengambil Identity yang dipasang Middleware. Nil kalau tidak ada.
+func From(c *gin.Context) *Identity {
+	id, _ := c.Get(contextKey)
+	v, _ := id.(*Identity)
+	return v
+}
+
+func sign(body string, key []byte) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(body))
+	return h.Sum(nil)
+}
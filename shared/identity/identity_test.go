@@ -0,0 +1,42 @@
+package identity
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignVerify(t *testing.T) {
+	key := []byte("kunci-identity")
+	now := time.Now()
+	in := Identity{UserID: "7", Username: "budi", Roles: []string{"customer"}}
+
+	assertion, err := Sign(in, key, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := Verify(assertion, key, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.UserID != "7" || got.Username != "budi" || len(got.Roles) != 1 {
+		t.Fatalf("Verify = %+v", got)
+	}
+
+	if _, err := Verify(assertion, key, now.Add(TTL)); !errors.Is(err, ErrExpired) {
+		t.Fatalf("kedaluwarsa: err = %v", err)
+	}
+	if _, err := Verify(assertion, []byte("kunci-lain"), now); !errors.Is(err, ErrInvalid) {
+		t.Fatalf("kunci salah: err = %v", err)
+	}
+
+	// Payload diganti (user id lain) dengan signature lama
+	forged, _ := Sign(Identity{UserID: "8"}, key, now)
+	body, _, _ := strings.Cut(forged, ".")
+	_, sig, _ := strings.Cut(assertion, ".")
+	if _, err := Verify(body+"."+sig, key, now); !errors.Is(err, ErrInvalid) {
+		t.Fatalf("payload dipalsukan: err = %v", err)
+	}
+}
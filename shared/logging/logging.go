@@ -0,0 +1,144 @@
+// Package logging: logger JSON (slog) yang seragam untuk semua service, plus
+// request id (X-Request-ID) yang dibawa dari gateway sampai ke panggilan
+// antar service dan goroutine background.
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const HeaderRequestID = "X-Request-ID"
+
+type ctxKey struct{}
+
+// Setup memasang logger JSON ke stdout sebagai slog default. Output package
+// log standar (termasuk library pihak ketiga) ikut lewat logger ini.
+func Setup(service string) *slog.Logger {
+	level := slog.LevelInfo
+	if os.Getenv("LOG_LEVEL") == "debug" {
+		level = slog.LevelDebug
+	}
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: level})).
+		With("service", service)
+	slog.SetDefault(logger)
+	return logger
+}
+
+// Fatal mencatat error lalu keluar, pengganti log.Fatal.
+func Fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+// WithRequestID menyimpan request id di context.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, ctxKey{}, id)
+}
+
+// RequestID mengambil request id dari context ("" kalau tidak ada).
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(ctxKey{}).(string)
+	return id
+}
+
+// FromContext: logger default yang sudah membawa request_id dan trace_id
+// dari ctx.
+func FromContext(ctx context.Context) *slog.Logger {
+	logger := slog.Default()
+	if id := RequestID(ctx); id != "" {
+		logger = logger.With("request_id", id)
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.HasTraceID() {
+		logger = logger.With("trace_id", sc.TraceID().String())
+	}
+	return logger
+}
+
+// Propagate menyalin request id dari context req ke header-nya, untuk
+// panggilan HTTP ke service lain.
+func Propagate(req *http.Request) {
+	if id := RequestID(req.Context()); id != "" {
+		req.Header.Set(HeaderRequestID, id)
+	}
+}
+
+// Middleware memakai X-Request-ID dari request (atau membuat baru), memasang
+// ke context & response, lalu mencatat satu baris log per request.
+// User id diambil dari gin context key "user_id" kalau ada.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		id := c.GetHeader(HeaderRequestID)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		c.Request.Header.Set(HeaderRequestID, id)
+		c.Request = c.Request.WithContext(WithRequestID(c.Request.Context(), id))
+		c.Header(HeaderRequestID, id)
+
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		status := c.Writer.Status()
+		attrs := []any{
+			"request_id", id,
+			"user_id", c.GetString("user_id"),
+			"method", c.Request.Method,
+			"route", route,
+			"path", c.Request.URL.Path,
+			"status", status,
+			"latency_ms", float64(time.Since(start).Microseconds()) / 1000,
+			"client_ip", c.ClientIP(),
+		}
+		if sc := trace.SpanContextFromContext(c.Request.Context()); sc.HasTraceID() {
+			attrs = append(attrs, "trace_id", sc.TraceID().String())
+		}
+		if len(c.Errors) > 0 {
+			attrs = append(attrs, "errors", c.Errors.String())
+		}
+
+		switch {
+		case status >= 500:
+			slog.Error("request", attrs...)
+		case status >= 400:
+			slog.Warn("request", attrs...)
+		default:
+			slog.Info("request", attrs...)
+		}
+	}
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// validRequestID: request id dari luar hanya diterima kalau pendek dan
+// karakternya aman, supaya tidak bisa dipakai menyisipkan isi log.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, r := range id {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+		default:
+			return false
+		}
+	}
+	return true
+}
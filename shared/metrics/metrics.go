@@ -0,0 +1,50 @@
+// Package metrics: metrik Prometheus HTTP yang sama untuk semua service.
+// Metrik domain (login, order, payment, ...) didefinisikan di masing-masing
+// service; semuanya terdaftar di registry default dan ikut keluar di /metrics.
+package metrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	requests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "Jumlah request HTTP per route, method, dan status.",
+	}, []string{"method", "route", "status"})
+
+	duration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "Latency request HTTP per route dan method.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route"})
+)
+
+// Middleware mencatat jumlah & latency setiap request. Label route memakai
+// pola route gin ("/order/:id"), bukan path asli, supaya jumlah seri tetap
+// terbatas.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		method := c.Request.Method
+		requests.WithLabelValues(method, route, strconv.Itoa(c.Writer.Status())).Inc()
+		duration.WithLabelValues(method, route).Observe(time.Since(start).Seconds())
+	}
+}
+
+// Handler: endpoint /metrics (format Prometheus).
+func Handler() gin.HandlerFunc {
+	return gin.WrapH(promhttp.Handler())
+}
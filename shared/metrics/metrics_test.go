@@ -0,0 +1,36 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMiddlewareUsesRoutePattern(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(Middleware())
+	r.GET("/order/:id", func(c *gin.Context) { c.Status(http.StatusOK) })
+	r.GET("/metrics", Handler())
+
+	for _, p := range []string{"/order/1", "/order/2", "/nope"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, p, nil))
+	}
+
+	if got := testutil.ToFloat64(requests.WithLabelValues("GET", "/order/:id", "200")); got != 2 {
+		t.Fatalf("request /order/:id = %v, want 2", got)
+	}
+	if got := testutil.ToFloat64(requests.WithLabelValues("GET", "unmatched", "404")); got != 1 {
+		t.Fatalf("request unmatched = %v, want 1", got)
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if !strings.Contains(w.Body.String(), `http_request_duration_seconds_count{method="GET",route="/order/:id"} 2`) {
+		t.Fatalf("histogram tidak ada di /metrics:\n%s", w.Body.String())
+	}
+}
@@ -0,0 +1,137 @@
+// Package server: menjalankan http.Server dengan graceful shutdown, plus probe
+// /healthz (liveness) dan /readyz (readiness) yang sama untuk semua service.
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultShutdownTimeout = 15 * time.Second
+	checkTimeout           = 2 * time.Second
+)
+
+var (
+	draining   atomic.Bool    // true sejak shutdown mulai, /readyz jadi 503
+	background sync.WaitGroup // goroutine dari Go, ditunggu saat shutdown
+)
+
+// Go menjalankan fn di goroutine yang ikut ditunggu saat shutdown, untuk kerja
+// yang berlanjut setelah response dikirim (mis. kirim email receipt).
+func Go(fn func()) {
+	background.Go(fn)
+}
+
+// Run menjalankan server sampai SIGINT/SIGTERM lalu shutdown dengan rapi:
+// /readyz langsung 503, listener ditutup, request yang sedang jalan dan
+// goroutine dari Go ditunggu sampai SHUTDOWN_TIMEOUT (default 15s). Sisanya
+// diputus paksa. Error hanya dikembalikan kalau ada server yang gagal listen.
+func Run(servers ...*http.Server) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	return run(ctx, shutdownTimeout(), servers...)
+}
+
+func run(ctx context.Context, timeout time.Duration, servers ...*http.Server) error {
+	errc := make(chan error, len(servers))
+	for _, srv := range servers {
+		go func() {
+			if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+				errc <- fmt.Errorf("listen %s: %w", srv.Addr, err)
+			}
+		}()
+	}
+
+	var err error
+	select {
+	case <-ctx.Done():
+		slog.Info("shutdown dimulai, menunggu request selesai", "timeout", timeout.String())
+	case err = <-errc:
+	}
+	draining.Store(true)
+
+	drainCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for _, srv := range servers {
+		wg.Go(func() {
+			if err := srv.Shutdown(drainCtx); err != nil {
+				slog.Warn("request belum selesai saat timeout, koneksi diputus", "addr", srv.Addr, "error", err)
+				srv.Close()
+			}
+		})
+	}
+	wg.Wait()
+
+	done := make(chan struct{})
+	go func() {
+		background.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-drainCtx.Done():
+		slog.Warn("goroutine background belum selesai saat timeout")
+	}
+	return err
+}
+
+func shutdownTimeout() time.Duration {
+	v := os.Getenv("SHUTDOWN_TIMEOUT")
+	if v == "" {
+		return defaultShutdownTimeout
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		slog.Warn("SHUTDOWN_TIMEOUT tidak valid, pakai default", "value", v, "default", defaultShutdownTimeout.String())
+		return defaultShutdownTimeout
+	}
+	return d
+}
+
+// Check memeriksa satu dependency untuk /readyz (mis. ping database).
+type Check func(ctx context.Context) error
+
+// Probes memasang /healthz (proses hidup, selalu 200) dan /readyz (semua check
+// lolos dan service belum shutdown). Setiap check dibatasi 2 detik.
+func Probes(r gin.IRoutes, checks map[string]Check) {
+	r.GET("/healthz", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"status": "ok"})
+	})
+
+	r.GET("/readyz", func(c *gin.Context) {
+		if draining.Load() {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"status": "shutting_down"})
+			return
+		}
+
+		status, code := "ok", http.StatusOK
+		results := make(map[string]string, len(checks))
+		for name, check := range checks {
+			ctx, cancel := context.WithTimeout(c.Request.Context(), checkTimeout)
+			err := check(ctx)
+			cancel()
+			if err != nil {
+				slog.Warn("readiness check gagal", "check", name, "error", err)
+				results[name] = err.Error()
+				status, code = "unavailable", http.StatusServiceUnavailable
+				continue
+			}
+			results[name] = "ok"
+		}
+		c.JSON(code, gin.H{"status": status, "checks": results})
+	})
+}
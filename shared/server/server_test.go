@@ -0,0 +1,76 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func probe(t *testing.T, checks map[string]Check, path string) int {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	Probes(r, checks)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+	return w.Code
+}
+
+func TestReadyz(t *testing.T) {
+	ok := func(context.Context) error { return nil }
+	down := func(context.Context) error { return errors.New("connection refused") }
+
+	if code := probe(t, map[string]Check{"db": ok}, "/readyz"); code != http.StatusOK {
+		t.Fatalf("readyz semua ok = %d, want 200", code)
+	}
+	if code := probe(t, map[string]Check{"db": ok, "redis": down}, "/readyz"); code != http.StatusServiceUnavailable {
+		t.Fatalf("readyz redis down = %d, want 503", code)
+	}
+	if code := probe(t, map[string]Check{"redis": down}, "/healthz"); code != http.StatusOK {
+		t.Fatalf("healthz tidak boleh ikut check dependency, got %d", code)
+	}
+
+	draining.Store(true)
+	t.Cleanup(func() { draining.Store(false) })
+	if code := probe(t, nil, "/readyz"); code != http.StatusServiceUnavailable {
+		t.Fatalf("readyz saat shutdown = %d, want 503", code)
+	}
+}
+
+func TestRunWaitsForBackground(t *testing.T) {
+	t.Cleanup(func() { draining.Store(false) })
+
+	var finished atomic.Bool
+	Go(func() {
+		time.Sleep(100 * time.Millisecond)
+		finished.Store(true)
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel() // anggap sinyal sudah diterima
+	srv := &http.Server{Addr: "127.0.0.1:0"}
+	if err := run(ctx, time.Second, srv); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if !finished.Load() {
+		t.Fatal("run selesai sebelum goroutine background selesai")
+	}
+	if !draining.Load() {
+		t.Fatal("draining harus true setelah shutdown")
+	}
+}
+
+func TestRunListenError(t *testing.T) {
+	t.Cleanup(func() { draining.Store(false) })
+
+	srv := &http.Server{Addr: "bukan-alamat"}
+	if err := run(context.Background(), time.Second, srv); err == nil {
+		t.Fatal("run harus mengembalikan error listen")
+	}
+}
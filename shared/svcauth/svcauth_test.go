@@ -0,0 +1,72 @@
+package svcauth
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var key = []byte("kunci-rahasia")
+
+func signedRequest(t *testing.T, body string) *http.Request {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/order/internal/update-status", bytes.NewBufferString(body))
+	if err := Sign(req, "payment-service", key); err != nil {
+		t.Fatal(err)
+	}
+	return req
+}
+
+func TestVerify(t *testing.T) {
+	req := signedRequest(t, `{"order_id":"1","status":"paid"}`)
+	service, err := Verify(req, key, time.Now())
+	if err != nil || service != "payment-service" {
+		t.Fatalf("Verify = %q, %v", service, err)
+	}
+
+	if _, err := Verify(req, []byte("kunci-lain"), time.Now()); !errors.Is(err, ErrBadSignature) {
+		t.Fatalf("kunci salah: err = %v", err)
+	}
+	if _, err := Verify(req, key, time.Now().Add(MaxSkew+time.Minute)); !errors.Is(err, ErrExpired) {
+		t.Fatalf("timestamp lama: err = %v", err)
+	}
+
+	tampered := signedRequest(t, `{"order_id":"1","status":"paid"}`)
+	tampered.Body = io.NopCloser(bytes.NewBufferString(`{"order_id":"2","status":"paid"}`))
+	if _, err := Verify(tampered, key, time.Now()); !errors.Is(err, ErrBadSignature) {
+		t.Fatalf("body diubah: err = %v", err)
+	}
+}
+
+func TestMiddlewareRejectsReplay(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/order/internal/update-status", Middleware(key), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	req := signedRequest(t, `{}`)
+	replay := httptest.NewRequest(req.Method, req.URL.String(), bytes.NewBufferString(`{}`))
+	replay.Header = req.Header.Clone()
+
+	for i, tc := range []struct {
+		req  *http.Request
+		want int
+	}{
+		{req, http.StatusOK},
+		{replay, http.StatusUnauthorized},
+		{httptest.NewRequest(http.MethodPost, "/order/internal/update-status", nil), http.StatusUnauthorized},
+	} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, tc.req)
+		if w.Code != tc.want {
+			t.Fatalf("request #%d: status = %d, want %d", i, w.Code, tc.want)
+		}
+	}
+}
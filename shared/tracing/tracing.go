@@ -0,0 +1,118 @@
+// Package tracing: setup OpenTelemetry yang sama untuk semua service.
+// Context trace dibawa antar service lewat header W3C traceparent.
+//
+// Exporter dipilih lewat env OTEL_TRACES_EXPORTER:
+//
+//	none   (default) span tetap dibuat & dipropagasi, tapi tidak dikirim
+//	stdout tulis span (JSON) ke stdout
+//	file   tulis span (JSON) ke OTEL_TRACES_FILE (default traces.jsonl)
+//	otlp   kirim ke collector via OTLP/HTTP, alamat dari
+//	       OTEL_EXPORTER_OTLP_ENDPOINT (default http://localhost:4318)
+//
+// Sampling mengikuti env standar OTEL_TRACES_SAMPLER / OTEL_TRACES_SAMPLER_ARG.
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// Setup memasang TracerProvider & propagator global. Fungsi shutdown yang
+// dikembalikan mengirim sisa span yang masih di buffer; panggil sebelum exit.
+func Setup(ctx context.Context, service string) (func(context.Context) error, error) {
+	res, err := resource.New(ctx,
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+		resource.WithAttributes(attribute.String("service.name", service)),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := []sdktrace.TracerProviderOption{sdktrace.WithResource(res)}
+	var closer io.Closer
+
+	switch exporter := os.Getenv("OTEL_TRACES_EXPORTER"); exporter {
+	case "", "none":
+	case "stdout", "console":
+		exp, err := stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, sdktrace.WithBatcher(exp))
+	case "file":
+		path := os.Getenv("OTEL_TRACES_FILE")
+		if path == "" {
+			path = "traces.jsonl"
+		}
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, err
+		}
+		exp, err := stdouttrace.New(stdouttrace.WithWriter(f))
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		opts = append(opts, sdktrace.WithBatcher(exp))
+		closer = f
+	case "otlp":
+		exp, err := otlptracehttp.New(ctx)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, sdktrace.WithBatcher(exp))
+	default:
+		return nil, fmt.Errorf("OTEL_TRACES_EXPORTER %q tidak dikenal (none, stdout, file, otlp)", exporter)
+	}
+
+	tp := sdktrace.NewTracerProvider(opts...)
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{}, propagation.Baggage{},
+	))
+
+	return func(ctx context.Context) error {
+		err := tp.Shutdown(ctx)
+		if closer != nil {
+			err = errors.Join(err, closer.Close())
+		}
+		return err
+	}, nil
+}
+
+// Middleware membuat server span per request ("GET /order/list"), melanjutkan
+// trace dari header traceparent kalau ada.
+func Middleware(service string) gin.HandlerFunc {
+	return otelgin.Middleware(service, otelgin.WithSpanNameFormatter(func(c *gin.Context) string {
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		return c.Request.Method + " " + route
+	}))
+}
+
+// Transport membungkus base (nil = http.DefaultTransport) supaya setiap
+// request keluar punya client span dan header traceparent.
+func Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return otelhttp.NewTransport(base)
+}